@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/djx30103/vpsub/internal/config"
 	"github.com/djx30103/vpsub/internal/server"
@@ -43,6 +44,14 @@ func newApp(
 
 // @BasePath	/
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == signCommand {
+		if err := runSign(os.Args[2:], os.Getenv, os.Stdout, time.Now()); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	configPath, err := config.ResolveConfigPath(flag.CommandLine, os.Args[1:], os.Getenv)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/pkg/pathutil"
+	"github.com/djx30103/vpsub/pkg/urlsign"
+)
+
+// signCommand 为生成签名订阅地址的子命令名称。
+const signCommand = "sign"
+
+// runSign 用于按配置中的第一个签名密钥为路由生成带过期时间的订阅地址，例如 vpsub sign --route /client-a --ttl 168h。
+// 参数含义：args 为子命令之后的参数；getenv 为环境变量读取函数；writer 为地址的输出目标；now 为当前时间。
+// 返回值：返回参数、配置或路由校验错误。
+func runSign(args []string, getenv func(string) string, writer io.Writer, now time.Time) error {
+	flagSet := flag.NewFlagSet(signCommand, flag.ContinueOnError)
+	route := flagSet.String("route", "", "route path to sign, eg: --route /client-a")
+	ttl := flagSet.Duration("ttl", 7*24*time.Hour, "link lifetime, eg: --ttl 168h")
+	baseURL := flagSet.String("base-url", "", "public address prepended to the link, defaults to server.public_url, eg: --base-url https://sub.example.com")
+
+	configPath, err := config.ResolveConfigPath(flagSet, args, getenv)
+	if err != nil {
+		return err
+	}
+	if *ttl <= 0 {
+		return errors.New("ttl must be > 0")
+	}
+
+	reqPath, err := pathutil.NormalizeRoutePath(*route)
+	if err != nil {
+		return fmt.Errorf("invalid route %q: %w", *route, err)
+	}
+
+	rootConfig, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	conf, err := config.BuildRuntime(rootConfig)
+	if err != nil {
+		return err
+	}
+
+	pathConf, ok := conf.PathToConfig[reqPath]
+	if !ok {
+		return fmt.Errorf("route not found: %s", reqPath)
+	}
+	// 未开启签名访问的路由不会校验签名，生成的地址无法起到限时作用。
+	if pathConf.AccessControl == nil || !pathConf.AccessControl.Signed {
+		return fmt.Errorf("route %s does not enable access_control.signed", reqPath)
+	}
+
+	query := urlsign.Query(conf.Signing.Secrets[0], reqPath, now.Add(*ttl))
+	// 未显式指定时沿用配置中的对外访问地址，两者都未配置时只输出路径与查询参数。
+	_, err = fmt.Fprintf(writer, "%s%s?%s\n", cmp.Or(strings.TrimRight(*baseURL, "/"), conf.Server.PublicURL), reqPath, query)
+
+	return err
+}
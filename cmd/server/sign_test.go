@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djx30103/vpsub/pkg/urlsign"
+)
+
+// TestRunSign_PrintsVerifiableLink 用于验证签名子命令输出的地址可以通过校验，且在有效期之后失效；未指定 --base-url 时沿用 server.public_url，未开启签名的路由会被拒绝。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestRunSign_PrintsVerifiableLink(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "config.yml")
+	content := `
+server:
+  public_url: "https://public.example.com/"
+signing:
+  secrets: ["current-secret-0001", "previous-secret-01"]
+providers:
+  hk-bwh:
+    type: bandwagonhost
+    api_id: "veid-1"
+    api_key: "key-1"
+routes:
+  - path: "/client-a"
+    file: "a.yaml"
+    provider_ref: "hk-bwh"
+    access_control:
+      signed: true
+  - path: "/client-b"
+    file: "b.yaml"
+    provider_ref: "hk-bwh"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	getenv := func(string) string { return "" }
+	now := time.Unix(1_700_000_000, 0)
+
+	var buffer bytes.Buffer
+	args := []string{"-conf", configPath, "--route", "/client-a/", "--ttl", "168h", "--base-url", "https://sub.example.com/"}
+	if err := runSign(args, getenv, &buffer, now); err != nil {
+		t.Fatalf("runSign returned error: %v", err)
+	}
+
+	link, err := url.Parse(strings.TrimSpace(buffer.String()))
+	if err != nil {
+		t.Fatalf("failed to parse link %q: %v", buffer.String(), err)
+	}
+	if link.Scheme != "https" || link.Host != "sub.example.com" || link.Path != "/client-a" {
+		t.Fatalf("unexpected link: %s", link)
+	}
+
+	secrets := []string{"current-secret-0001"}
+	exp, sig := link.Query().Get(urlsign.ExpiresParam), link.Query().Get(urlsign.SignatureParam)
+	if err := urlsign.Verify(secrets, "/client-a", exp, sig, now.Add(167*time.Hour)); err != nil {
+		t.Fatalf("expected link to be valid before ttl, got: %v", err)
+	}
+	if err := urlsign.Verify(secrets, "/client-a", exp, sig, now.Add(168*time.Hour)); err == nil {
+		t.Fatalf("expected link to expire after ttl")
+	}
+
+	buffer.Reset()
+	if err := runSign([]string{"-conf", configPath, "--route", "/client-a"}, getenv, &buffer, now); err != nil {
+		t.Fatalf("runSign returned error: %v", err)
+	}
+	if got := buffer.String(); !strings.HasPrefix(got, "https://public.example.com/client-a?") {
+		t.Fatalf("expected link to default to server.public_url, got %q", got)
+	}
+
+	if err := runSign([]string{"-conf", configPath, "--route", "/client-b"}, getenv, &buffer, now); err == nil {
+		t.Fatalf("expected route without signed access to be rejected")
+	}
+}
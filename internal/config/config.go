@@ -2,16 +2,76 @@ package config
 
 import (
 	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"net/netip"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"text/template/parse"
 	"time"
+	_ "time/tzdata"
 
+	"github.com/djx30103/vpsub/internal/convert"
 	"github.com/djx30103/vpsub/pkg/bytesize"
 	"github.com/djx30103/vpsub/pkg/pathutil"
+	"github.com/djx30103/vpsub/pkg/provider/base"
 )
 
+const (
+	// ExpireFieldResetAt 表示 Subscription-Userinfo 的 expire 使用下一次流量重置时间。
+	ExpireFieldResetAt = "reset_at"
+	// ExpireFieldServiceExpireAt 表示 Subscription-Userinfo 的 expire 使用服务到期时间。
+	ExpireFieldServiceExpireAt = "service_expire_at"
+)
+
+const (
+	// FormatClash、FormatSingBox 与 FormatURIList 为可互相转换的格式，取值以转换包中的定义为准。
+	FormatClash   = convert.FormatClash
+	FormatSingBox = convert.FormatSingBox
+	FormatURIList = convert.FormatURIList
+	// FormatSurge 表示 Surge 的 INI 配置。
+	FormatSurge = "surge"
+	// FormatLoon 表示 Loon 的 INI 配置，分段结构与 Surge 一致。
+	FormatLoon = "loon"
+	// FormatQuantumultX 表示 Quantumult X 的配置。
+	FormatQuantumultX = "quanx"
+)
+
+// TargetAuto 表示按客户端 User-Agent 自动选择转换目标格式。
+const TargetAuto = "auto"
+
+// defaultMergeConflictSuffix 为合并多个订阅文件时，重名节点与分组默认追加的后缀模板。
+const defaultMergeConflictSuffix = "-{{.index}}"
+
+const (
+	// UserAgentModeRegex 表示按正则匹配 User-Agent。
+	UserAgentModeRegex = "regex"
+	// UserAgentModePrefix 表示按不区分大小写的前缀匹配 User-Agent。
+	UserAgentModePrefix = "prefix"
+	// UserAgentModeContains 表示按不区分大小写的子串匹配 User-Agent。
+	UserAgentModeContains = "contains"
+)
+
+const (
+	// SortByName 表示按节点名称排序。
+	SortByName = "name"
+	// SortByRegion 表示按节点所在地区排序，无法识别地区的节点排在最后。
+	SortByRegion = "region"
+)
+
+// remoteBackupDir 为远程订阅源未指定 file 时，最近一次成功拉取内容在订阅目录下的默认存放目录。
+const remoteBackupDir = ".remote"
+
 // RootConfig 保存完整的配置结构。
 type RootConfig struct {
 	AppMode   string         `mapstructure:"app_mode"`
@@ -20,13 +80,19 @@ type RootConfig struct {
 	Global    GlobalConfig   `mapstructure:"global"`
 	Defaults  DefaultsConfig `mapstructure:"defaults"`
 	Providers ProviderMap    `mapstructure:"providers"`
+	Users     UserMap        `mapstructure:"users"`
+	Signing   SigningConfig  `mapstructure:"signing"`
 	Routes    []RouteItem    `mapstructure:"routes"`
 }
 
 // ServerConfig 表示 HTTP 服务配置。
+// TrustedProxies 为可信反向代理的 IP 或 CIDR，只有来自这些地址的请求才会按 X-Forwarded-For、X-Forwarded-Proto 等请求头识别客户端 IP 与协议，为空时直接使用连接信息。
+// PublicURL 为对外访问的地址（协议、主机与可选的路径前缀），生成订阅地址时优先使用，不再依赖客户端提供的 Host 请求头。
 type ServerConfig struct {
-	ListenAddr string        `mapstructure:"listen_addr"`
-	Timeout    time.Duration `mapstructure:"timeout"`
+	ListenAddr     string        `mapstructure:"listen_addr"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+	TrustedProxies []string      `mapstructure:"trusted_proxies"`
+	PublicURL      string        `mapstructure:"public_url"`
 }
 
 // LogConfig 表示日志配置。
@@ -40,9 +106,11 @@ type GlobalConfig struct {
 }
 
 // DefaultsConfig 表示全局默认配置。
+// AccessControl 为各路由默认的 IP 访问约束，路由中配置了 ip_allow 或 ip_deny 时按字段覆盖。
 type DefaultsConfig struct {
-	Provider     *ProviderConfigOverride `mapstructure:"provider"`
-	UsageDisplay *UsageDisplayConfig     `mapstructure:"usage_display"`
+	Provider      *ProviderConfigOverride `mapstructure:"provider"`
+	UsageDisplay  *UsageDisplayConfig     `mapstructure:"usage_display"`
+	AccessControl *IPAccessRule           `mapstructure:"access_control"`
 }
 
 // ProviderConfig 是解析合并后的运行时服务商配置，字段均为值类型，调用方可直接使用。
@@ -50,31 +118,73 @@ type ProviderConfig struct {
 	APITTL         time.Duration
 	RequestTimeout time.Duration
 	UpdateInterval time.Duration
+	Transport      base.TransportConfig
+	CountingMode   base.CountingMode
+	DataMultiplier float64
+	ResetDay       int
+	ResetLocation  *time.Location
+	// ServiceExpireAt 为手动指定的服务到期时间（Unix 时间戳），0 表示未配置。
+	ServiceExpireAt int64
+	// Total 为手动指定的流量总量（字节），0 表示沿用服务商接口返回的值。
+	Total int64
 }
 
 // ProviderConfigOverride 对应配置文件中的服务商参数，指针字段表示"未配置"，用于与默认值合并。
 type ProviderConfigOverride struct {
-	APITTL         *time.Duration `mapstructure:"api_ttl"`
-	RequestTimeout *time.Duration `mapstructure:"request_timeout"`
-	UpdateInterval *time.Duration `mapstructure:"update_interval"`
+	APITTL             *time.Duration `mapstructure:"api_ttl"`
+	RequestTimeout     *time.Duration `mapstructure:"request_timeout"`
+	UpdateInterval     *time.Duration `mapstructure:"update_interval"`
+	ProxyURL           *string        `mapstructure:"proxy_url"`
+	DNSServer          *string        `mapstructure:"dns_server"`
+	LocalAddr          *string        `mapstructure:"local_addr"`
+	CAFile             *string        `mapstructure:"ca_file"`
+	InsecureSkipVerify *bool          `mapstructure:"insecure_skip_verify"`
+	CountingMode       *string        `mapstructure:"counting_mode"`
+	DataMultiplier     *float64       `mapstructure:"data_multiplier"`
+	Reset              *ResetRule     `mapstructure:"reset"`
+	ServiceExpireAt    *string        `mapstructure:"service_expire_at"`
+	Total              *string        `mapstructure:"total"`
+}
+
+// ResetRule 表示手动指定的每月流量重置规则，优先级高于服务商接口返回或内置推算的重置时间。
+// Timezone 为空时使用服务本地时区。
+type ResetRule struct {
+	Day      int    `mapstructure:"day"`
+	Timezone string `mapstructure:"timezone"`
 }
 
 // UsageDisplayConfig 表示订阅中追加的流量展示配置。
+// Lines 非空时按其中的模板逐行展示，TrafficFormat 与 ResetTimeFormat 不再使用。
+// TrafficPrecision 为流量保留的小数位数；TrafficBase 为单位换算的进制标准，为空时按 iec（1024 进制）换算。
 type UsageDisplayConfig struct {
-	Enable          bool   `mapstructure:"enable"`
-	Prepend         bool   `mapstructure:"prepend"`
-	TrafficFormat   string `mapstructure:"traffic_format"`
-	ResetTimeFormat string `mapstructure:"reset_time_format"`
-	TrafficUnit     string `mapstructure:"traffic_unit"`
+	Enable           bool        `mapstructure:"enable"`
+	Prepend          bool        `mapstructure:"prepend"`
+	TrafficFormat    string      `mapstructure:"traffic_format"`
+	ResetTimeFormat  string      `mapstructure:"reset_time_format"`
+	TrafficUnit      string      `mapstructure:"traffic_unit"`
+	TrafficPrecision int         `mapstructure:"traffic_precision"`
+	TrafficBase      string      `mapstructure:"traffic_base"`
+	Lines            []UsageLine `mapstructure:"lines"`
 }
 
 // UsageDisplayOverride 用于表达路由级的显式覆盖，支持将布尔值覆盖为 false。
+// Lines 非 nil 时整体替换默认配置中的展示行。
 type UsageDisplayOverride struct {
-	Enable          *bool   `mapstructure:"enable"`
-	Prepend         *bool   `mapstructure:"prepend"`
-	TrafficFormat   *string `mapstructure:"traffic_format"`
-	ResetTimeFormat *string `mapstructure:"reset_time_format"`
-	TrafficUnit     *string `mapstructure:"traffic_unit"`
+	Enable           *bool       `mapstructure:"enable"`
+	Prepend          *bool       `mapstructure:"prepend"`
+	TrafficFormat    *string     `mapstructure:"traffic_format"`
+	ResetTimeFormat  *string     `mapstructure:"reset_time_format"`
+	TrafficUnit      *string     `mapstructure:"traffic_unit"`
+	TrafficPrecision *int        `mapstructure:"traffic_precision"`
+	TrafficBase      *string     `mapstructure:"traffic_base"`
+	Lines            []UsageLine `mapstructure:"lines"`
+}
+
+// UsageLine 表示一行流量展示文案。
+// 字段含义：Template 为展示模板；When 为展示条件，如 "percent > 80"，为空时总是展示。
+type UsageLine struct {
+	Template string `mapstructure:"template"`
+	When     string `mapstructure:"when"`
 }
 
 // StorageConfig 表示文件存储配置。
@@ -85,6 +195,25 @@ type StorageConfig struct {
 // ProviderMap 表示账号名到服务商账号配置的映射。
 type ProviderMap map[string]ProviderItem
 
+// SigningConfig 表示签名订阅地址的密钥配置。
+// 字段含义：Secrets 为有效的签名密钥，第一个用于生成新地址，全部密钥都可用于校验，便于轮换时新旧地址同时可用。
+type SigningConfig struct {
+	Secrets []string `mapstructure:"secrets"`
+}
+
+// minSigningSecretLength 为签名密钥的最小长度，避免过短的密钥被暴力猜解。
+const minSigningSecretLength = 16
+
+// UserMap 表示用户名到访问令牌配置的映射，用户名由配置解析统一转为小写。
+type UserMap map[string]UserItem
+
+// UserItem 表示单个订阅用户的访问令牌，Token 与 TokenSHA256 二选一。
+// 字段含义：Token 为明文令牌；TokenSHA256 为令牌的 SHA-256 十六进制摘要，避免配置文件中出现明文。
+type UserItem struct {
+	Token       string `mapstructure:"token"`
+	TokenSHA256 string `mapstructure:"token_sha256"`
+}
+
 // ProviderItem 表示单个服务商账号配置。
 // Overrides 直接映射配置文件中的 overrides 字段，nil 表示无覆盖。
 type ProviderItem struct {
@@ -95,17 +224,138 @@ type ProviderItem struct {
 }
 
 // RouteItem 表示对外暴露的订阅路由配置。
+// URL 非空时订阅内容从上游拉取，File 改为最近一次成功拉取内容的落盘位置。
+// Files 非空时合并多个订阅文件，File 改为提供 rules 等其余字段的基准文件，未配置时取 Files 的第一项。
+// Template 为 true 时订阅内容先按 Go 模板渲染，Vars 为模板可引用的路由变量。
 type RouteItem struct {
-	Path          string                `mapstructure:"path"`
-	File          string                `mapstructure:"file"`
-	ProviderRef   string                `mapstructure:"provider_ref"`
-	AccessControl *AccessControlConfig  `mapstructure:"access_control"`
-	UsageDisplay  *UsageDisplayOverride `mapstructure:"usage_display"`
+	Path           string                `mapstructure:"path"`
+	File           string                `mapstructure:"file"`
+	Files          []string              `mapstructure:"files"`
+	Merge          *MergeConfig          `mapstructure:"merge"`
+	URL            string                `mapstructure:"url"`
+	Remote         *RemoteSourceConfig   `mapstructure:"remote"`
+	Template       bool                  `mapstructure:"template"`
+	Vars           map[string]any        `mapstructure:"vars"`
+	Transform      []TransformStep       `mapstructure:"transform"`
+	Credentials    []CredentialRule      `mapstructure:"credentials"`
+	RuleProviders  []ClashProvider       `mapstructure:"rule_providers"`
+	ProxyProviders []ClashProvider       `mapstructure:"proxy_providers"`
+	Rules          []string              `mapstructure:"rules"`
+	ProviderRef    string                `mapstructure:"provider_ref"`
+	Format         string                `mapstructure:"format"`
+	Target         string                `mapstructure:"target"`
+	ExpireField    string                `mapstructure:"expire_field"`
+	AccessControl  *AccessControlConfig  `mapstructure:"access_control"`
+	UsageDisplay   *UsageDisplayOverride `mapstructure:"usage_display"`
+	Variants       []RouteVariant        `mapstructure:"variants"`
 }
 
-// AccessControlConfig 表示路由级访问约束配置。
-type AccessControlConfig struct {
+// CredentialRule 表示替换到节点中的凭据，按配置顺序依次生效，后面的规则覆盖前面的规则。
+// 字段含义：Match 为节点名称正则，为空时匹配全部节点；UUID、Password 与 AuthStr 分别替换节点已有的 uuid、password 与 auth-str 字段。
+type CredentialRule struct {
+	Match    string `mapstructure:"match"`
+	UUID     string `mapstructure:"uuid"`
+	Password string `mapstructure:"password"`
+	AuthStr  string `mapstructure:"auth_str"`
+}
+
+// ClashProvider 表示注入 Clash 配置的 rule-provider 或 proxy-provider。
+// 字段含义：Name 为提供者名称；Route 为本服务另一条路由的路径，注入时按 server.public_url 或本次请求的协议与主机展开为 url；
+// Options 为原样写入的其余字段，如 type、behavior、url、path、interval。
+type ClashProvider struct {
+	Name    string         `mapstructure:"name"`
+	Route   string         `mapstructure:"route"`
+	Options map[string]any `mapstructure:",remain"`
+}
+
+// TransformStep 表示节点处理流水线中的一步，每一步只能配置一种操作，按配置顺序依次执行。
+// 字段含义：Include 与 Exclude 为按名称正则与节点类型保留或移除节点；Rename 为按正则替换节点名称；
+// Prefix 与 Suffix 为节点名称前后追加的模板，支持 {{.flag}}、{{.region}} 与 {{.type}}；Sort 为排序方式，可选 name 或 region。
+type TransformStep struct {
+	Include *NodeFilter `mapstructure:"include"`
+	Exclude *NodeFilter `mapstructure:"exclude"`
+	Rename  *RenameRule `mapstructure:"rename"`
+	Prefix  string      `mapstructure:"prefix"`
+	Suffix  string      `mapstructure:"suffix"`
+	Sort    string      `mapstructure:"sort"`
+}
+
+// NodeFilter 表示节点匹配条件，同时配置时需全部满足。
+// 字段含义：Name 为节点名称正则；Type 为节点类型列表，如 ss、vless。
+type NodeFilter struct {
+	Name string   `mapstructure:"name"`
+	Type []string `mapstructure:"type"`
+}
+
+// RenameRule 表示节点重命名规则，Replace 中可用 $1、${name} 引用捕获组。
+type RenameRule struct {
+	Pattern string `mapstructure:"pattern"`
+	Replace string `mapstructure:"replace"`
+}
+
+// MergeConfig 表示合并多个订阅文件时的参数，仅在路由配置了 files 时生效。
+// ConflictSuffix 为重名节点与分组追加的后缀模板，支持 {{.index}} 序号与 {{.file}} 来源文件名，必须包含 {{.index}}。
+type MergeConfig struct {
+	ConflictSuffix string `mapstructure:"conflict_suffix"`
+}
+
+// RemoteSourceConfig 表示远程订阅源的拉取参数，仅在路由配置了 url 时生效。
+// CacheTTL 为 nil 表示未配置，0 表示每次请求都重新拉取。
+type RemoteSourceConfig struct {
+	UserAgent string            `mapstructure:"user_agent"`
+	Headers   map[string]string `mapstructure:"headers"`
+	Timeout   time.Duration     `mapstructure:"timeout"`
+	CacheTTL  *time.Duration    `mapstructure:"cache_ttl"`
+}
+
+// RouteVariant 表示按客户端 User-Agent 切换的路由变体，未配置的字段沿用路由本身的设置。
+// 字段含义：UserAgent 为不区分大小写的 UA 子串；File 为变体使用的订阅文件；Format 为该文件的订阅格式；Target 为转换目标格式。
+type RouteVariant struct {
 	UserAgent string `mapstructure:"user_agent"`
+	File      string `mapstructure:"file"`
+	Format    string `mapstructure:"format"`
+	Target    string `mapstructure:"target"`
+}
+
+// AccessControlConfig 表示路由级访问约束配置，同时配置时需全部满足。
+// 字段含义：UserAgent 为 User-Agent 规则，写成字符串时需完全一致；Users 为允许访问的用户名，请求需携带其中任一用户的令牌；
+// Signed 为 true 时接受带过期时间的签名地址，同时配置了 Users 时有效签名与用户令牌满足其一即可；IPAccessRule 为客户端 IP 约束。
+type AccessControlConfig struct {
+	UserAgent    UserAgentRule `mapstructure:"user_agent"`
+	Users        []string      `mapstructure:"users"`
+	Signed       bool          `mapstructure:"signed"`
+	IPAccessRule `mapstructure:",squash"`
+}
+
+// IPAccessRule 表示客户端 IP 访问约束，条目可以是单个 IP 或 CIDR。
+// 字段含义：IPAllow 为允许的地址，配置后需命中其一；IPDeny 为拒绝的地址，命中任一即拒绝。
+type IPAccessRule struct {
+	IPAllow []string `mapstructure:"ip_allow"`
+	IPDeny  []string `mapstructure:"ip_deny"`
+}
+
+// UserAgentRule 表示 User-Agent 访问规则，配置文件中写成字符串时等价于只配置 Exact。
+// 字段含义：Exact 为需完全一致的 User-Agent；Allow 为允许的模式，配置后需命中其一；Deny 为拒绝的模式，命中任一即拒绝；
+// Mode 为 Allow 与 Deny 的匹配方式，可选 regex（默认）、prefix 或 contains，后两者不区分大小写。
+type UserAgentRule struct {
+	Exact string   `mapstructure:"exact"`
+	Allow []string `mapstructure:"allow"`
+	Deny  []string `mapstructure:"deny"`
+	Mode  string   `mapstructure:"mode"`
+}
+
+// IsZero 用于判断 User-Agent 规则是否未配置任何约束。
+func (r UserAgentRule) IsZero() bool {
+	return strings.TrimSpace(r.Exact) == "" && len(r.Allow) == 0 && len(r.Deny) == 0
+}
+
+// userAgentRuleHook 用于把配置文件中字符串形式的 user_agent 解码为只包含精确匹配的规则，兼容旧写法。
+func userAgentRuleHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(UserAgentRule{}) {
+		return data, nil
+	}
+
+	return UserAgentRule{Exact: data.(string)}, nil
 }
 
 // initDefault 用于补齐根配置中的默认值。
@@ -195,8 +445,38 @@ func (r *UsageDisplayConfig) initDefault() {
 	}
 }
 
+// normalizePublicURL 用于校验对外访问地址，并去掉末尾的斜杠，便于直接拼接请求路径。
+// 参数含义：raw 为配置中的地址，空字符串表示未配置。
+// 返回值：返回标准化后的地址；不是 http 或 https 地址，或带有查询参数、片段与用户信息时返回错误。
+func normalizePublicURL(raw string) (string, error) {
+	raw = strings.TrimRight(strings.TrimSpace(raw), "/")
+	if raw == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("must be an http or https url")
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", errors.New("must not contain query, fragment or user info")
+	}
+
+	return raw, nil
+}
+
 // validate 用于校验根配置的静态合法性，避免明显错误拖到请求期。
 func (r *RootConfig) validate() error {
+	if _, err := parseIPPrefixes(r.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("server.trusted_proxies: %w", err)
+	}
+
+	publicURL, err := normalizePublicURL(r.Server.PublicURL)
+	if err != nil {
+		return fmt.Errorf("server.public_url: %w", err)
+	}
+	r.Server.PublicURL = publicURL
+
 	if err := r.Defaults.validate(); err != nil {
 		return err
 	}
@@ -207,11 +487,24 @@ func (r *RootConfig) validate() error {
 		}
 	}
 
+	for name, userItem := range r.Users {
+		if err := userItem.validate(); err != nil {
+			return fmt.Errorf("user %q: %w", name, err)
+		}
+	}
+
+	if err := r.Signing.validate(); err != nil {
+		return fmt.Errorf("signing: %w", err)
+	}
+
 	for i := range r.Routes {
 		originalPath := r.Routes[i].Path
 		if err := r.Routes[i].validate(); err != nil {
 			return fmt.Errorf("route[%d] %q: %w", i, originalPath, err)
 		}
+		if accessControl := r.Routes[i].AccessControl; accessControl != nil && accessControl.Signed && len(r.Signing.Secrets) == 0 {
+			return fmt.Errorf("route[%d] %q: access_control.signed requires signing.secrets", i, originalPath)
+		}
 	}
 
 	return nil
@@ -231,6 +524,12 @@ func (r *DefaultsConfig) validate() error {
 		}
 	}
 
+	if r.AccessControl != nil {
+		if err := r.AccessControl.validate(); err != nil {
+			return fmt.Errorf("defaults.access_control: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -248,6 +547,188 @@ func (r *ProviderConfigOverride) validate() error {
 		return errors.New("update_interval must be >= 0")
 	}
 
+	if r.CountingMode != nil && !base.IsValidCountingMode(normalizeCountingMode(*r.CountingMode)) {
+		return errors.New("counting_mode must be both, egress_only, ingress_only or max")
+	}
+
+	if r.DataMultiplier != nil && *r.DataMultiplier <= 0 {
+		return errors.New("data_multiplier must be > 0")
+	}
+
+	if r.Reset != nil {
+		if err := r.Reset.validate(); err != nil {
+			return fmt.Errorf("reset: %w", err)
+		}
+	}
+
+	if r.ServiceExpireAt != nil {
+		if _, err := parseServiceExpireAt(*r.ServiceExpireAt); err != nil {
+			return err
+		}
+	}
+
+	if r.Total != nil {
+		if _, err := parseTotal(*r.Total); err != nil {
+			return err
+		}
+	}
+
+	// 出站网络字段只校验已配置的部分，未配置的字段会在合并阶段继承默认值。
+	var transport base.TransportConfig
+	r.applyTransport(&transport)
+	if err := transport.Validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// applyBilling 用于将已配置的计费方向、计费系数、重置规则、服务到期时间与流量总量合并到目标配置中，nil 字段保持目标原值。
+// 参数含义：dst 为目标运行时服务商配置。
+// 返回值：无。
+func (r *ProviderConfigOverride) applyBilling(dst *ProviderConfig) {
+	if r.CountingMode != nil {
+		dst.CountingMode = base.CountingMode(normalizeCountingMode(*r.CountingMode))
+	}
+
+	if r.DataMultiplier != nil {
+		dst.DataMultiplier = *r.DataMultiplier
+	}
+
+	// 重置规则的日期与时区必须成对生效，因此整体覆盖而不是逐字段合并。
+	if r.Reset != nil {
+		dst.ResetDay = r.Reset.Day
+		dst.ResetLocation = r.Reset.location()
+	}
+
+	// 空字符串表示显式清除默认配置中的到期时间，格式已在 validate 阶段校验。
+	if r.ServiceExpireAt != nil {
+		dst.ServiceExpireAt, _ = parseServiceExpireAt(*r.ServiceExpireAt)
+	}
+
+	// 与到期时间相同，空字符串表示清除默认配置中的流量总量。
+	if r.Total != nil {
+		dst.Total, _ = parseTotal(*r.Total)
+	}
+}
+
+// serviceExpireAtLayouts 为 service_expire_at 支持的时间格式，仅写日期时按当天 00:00（服务本地时区）计算。
+var serviceExpireAtLayouts = []string{time.RFC3339, time.DateTime, time.DateOnly}
+
+// parseServiceExpireAt 用于解析手动指定的服务到期时间。
+// 参数含义：value 为配置中的到期时间，支持 RFC3339、"2006-01-02 15:04:05" 与 "2006-01-02"，空字符串表示未配置。
+// 返回值：返回 Unix 时间戳，空字符串返回 0；格式非法时返回错误。
+func parseServiceExpireAt(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	for _, layout := range serviceExpireAtLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+
+	return 0, errors.New("service_expire_at must be a date like 2006-01-02 or an RFC3339 time")
+}
+
+// parseTotal 用于解析手动指定的流量总量，单位均按 1024 进制换算，与服务商面板的展示保持一致。
+// 参数含义：value 为配置中的流量总量，如 "1T"、"500GB"、"1.5TiB"，空字符串表示未配置。
+// 返回值：返回字节数，空字符串返回 0；格式非法时返回错误。
+func parseTotal(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	size, err := bytesize.Parse(value, bytesize.BaseIEC)
+	if err != nil || size == 0 {
+		return 0, errors.New("total must be a positive size like 500G or 1.5T")
+	}
+
+	return size, nil
+}
+
+// applyTransport 用于将已配置的出站网络字段合并到目标配置中，nil 字段保持目标原值。
+// 参数含义：dst 为目标出站网络配置。
+// 返回值：无。
+func (r *ProviderConfigOverride) applyTransport(dst *base.TransportConfig) {
+	if r.ProxyURL != nil {
+		dst.ProxyURL = strings.TrimSpace(*r.ProxyURL)
+	}
+
+	if r.DNSServer != nil {
+		dst.DNSServer = strings.TrimSpace(*r.DNSServer)
+	}
+
+	if r.LocalAddr != nil {
+		dst.LocalAddr = strings.TrimSpace(*r.LocalAddr)
+	}
+
+	if r.CAFile != nil {
+		dst.CAFile = strings.TrimSpace(*r.CAFile)
+	}
+
+	if r.InsecureSkipVerify != nil {
+		dst.InsecureSkipVerify = *r.InsecureSkipVerify
+	}
+}
+
+// validate 用于校验重置规则是否合法。
+func (r *ResetRule) validate() error {
+	if r.Day < 1 || r.Day > 31 {
+		return errors.New("day must be between 1 and 31")
+	}
+
+	if _, err := time.LoadLocation(strings.TrimSpace(r.Timezone)); err != nil {
+		return errors.New("timezone is invalid")
+	}
+
+	return nil
+}
+
+// location 用于解析重置规则所依据的时区，空值对应服务本地时区。
+// 时区已在 validate 阶段校验，这里解析失败时同样回退到本地时区，保证运行期不会拿到 nil。
+func (r *ResetRule) location() *time.Location {
+	timezone := strings.TrimSpace(r.Timezone)
+	if timezone == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Local
+	}
+
+	return loc
+}
+
+// normalizeCountingMode 用于统一计费方向的大小写和首尾空白，避免配置书写差异影响匹配。
+func normalizeCountingMode(mode string) string {
+	return strings.ToLower(strings.TrimSpace(mode))
+}
+
+// maxTrafficPrecision 为流量展示允许保留的最大小数位数。
+const maxTrafficPrecision = 6
+
+// FormatBytes 用于按展示配置中的单位、小数位数与进制标准格式化字节数。
+// 参数含义：size 为原始字节数。
+// 返回值：返回带单位的展示值。
+func (r *UsageDisplayConfig) FormatBytes(size int64) string {
+	return bytesize.FormatWith(size, r.TrafficUnit, r.TrafficPrecision, bytesize.Base(r.TrafficBase))
+}
+
+// validateTrafficNumberFormat 用于校验流量数值的小数位数与进制标准。
+func validateTrafficNumberFormat(precision int, base string) error {
+	if precision < 0 || precision > maxTrafficPrecision {
+		return fmt.Errorf("traffic_precision must be between 0 and %d", maxTrafficPrecision)
+	}
+
+	if base != "" && !bytesize.IsValidBase(base) {
+		return errors.New("traffic_base must be iec or si")
+	}
+
 	return nil
 }
 
@@ -257,6 +738,10 @@ func (r *UsageDisplayConfig) validate() error {
 		return errors.New("traffic_unit is invalid")
 	}
 
+	if err := validateTrafficNumberFormat(r.TrafficPrecision, r.TrafficBase); err != nil {
+		return err
+	}
+
 	if err := validateTrafficTemplate(r.TrafficFormat); err != nil {
 		return err
 	}
@@ -265,7 +750,7 @@ func (r *UsageDisplayConfig) validate() error {
 		return err
 	}
 
-	return nil
+	return validateUsageLines(r.Lines)
 }
 
 // validate 用于校验路由级覆盖配置是否合法。
@@ -274,6 +759,19 @@ func (r *UsageDisplayOverride) validate() error {
 		return errors.New("traffic_unit is invalid")
 	}
 
+	// 未覆盖的字段在合并时继承默认配置，这里只校验显式配置的值。
+	if r.TrafficPrecision != nil {
+		if err := validateTrafficNumberFormat(*r.TrafficPrecision, ""); err != nil {
+			return err
+		}
+	}
+
+	if r.TrafficBase != nil {
+		if err := validateTrafficNumberFormat(0, *r.TrafficBase); err != nil {
+			return err
+		}
+	}
+
 	if r.TrafficFormat != nil {
 		if err := validateTrafficTemplate(*r.TrafficFormat); err != nil {
 			return err
@@ -286,7 +784,7 @@ func (r *UsageDisplayOverride) validate() error {
 		}
 	}
 
-	return nil
+	return validateUsageLines(r.Lines)
 }
 
 // validate 用于校验账号配置是否合法。
@@ -321,8 +819,48 @@ func (r *RouteItem) validate() error {
 		return errors.New("path is required")
 	}
 
+	r.URL = strings.TrimSpace(r.URL)
+	if r.URL != "" {
+		if err := validateRemoteURL(r.URL); err != nil {
+			return err
+		}
+
+		// 未指定落盘位置时按拉取参数生成固定文件名，沿用 URL 的扩展名以便继续按扩展名推断格式。
+		if strings.TrimSpace(r.File) == "" {
+			r.File = remoteBackupFile(r.URL, r.Remote)
+		}
+	}
+
+	if len(r.Files) > 0 {
+		if err := r.normalizeFiles(); err != nil {
+			return err
+		}
+	}
+
+	if r.Merge != nil {
+		if len(r.Files) == 0 {
+			return errors.New("merge requires files")
+		}
+		if err := r.Merge.validate(); err != nil {
+			return fmt.Errorf("merge: %w", err)
+		}
+	}
+
 	if strings.TrimSpace(r.File) == "" {
-		return errors.New("file is required")
+		return errors.New("file, files or url is required")
+	}
+
+	if len(r.Vars) > 0 && !r.Template {
+		return errors.New("vars requires template: true")
+	}
+
+	if r.Remote != nil {
+		if r.URL == "" {
+			return errors.New("remote requires url")
+		}
+		if err := r.Remote.validate(); err != nil {
+			return fmt.Errorf("remote: %w", err)
+		}
 	}
 
 	if strings.TrimSpace(r.ProviderRef) == "" {
@@ -344,6 +882,60 @@ func (r *RouteItem) validate() error {
 	}
 	r.File = normalizedFile
 
+	r.Format = strings.ToLower(strings.TrimSpace(r.Format))
+	if r.Format != "" && !IsValidFormat(r.Format) {
+		return errors.New("format is invalid")
+	}
+
+	// 合并按 Clash 的 proxies 与 proxy-groups 结构进行，其他格式没有可合并的统一结构。
+	if len(r.Files) > 0 && ResolveFormat(r.Format, r.File) != FormatClash {
+		return errors.New("files requires clash format")
+	}
+
+	if len(r.Transform) > 0 && ResolveFormat(r.Format, r.File) != FormatClash {
+		return errors.New("transform requires clash format")
+	}
+	for i := range r.Transform {
+		if err := r.Transform[i].validate(); err != nil {
+			return fmt.Errorf("transform[%d]: %w", i, err)
+		}
+	}
+
+	if len(r.Credentials) > 0 && ResolveFormat(r.Format, r.File) != FormatClash {
+		return errors.New("credentials requires clash format")
+	}
+	for i := range r.Credentials {
+		if err := r.Credentials[i].validate(); err != nil {
+			return fmt.Errorf("credentials[%d]: %w", i, err)
+		}
+	}
+
+	if err := validateClashProviders(r.RuleProviders); err != nil {
+		return fmt.Errorf("rule_providers: %w", err)
+	}
+	if err := validateClashProviders(r.ProxyProviders); err != nil {
+		return fmt.Errorf("proxy_providers: %w", err)
+	}
+	for i, rule := range r.Rules {
+		r.Rules[i] = strings.TrimSpace(rule)
+		if r.Rules[i] == "" {
+			return fmt.Errorf("rules[%d] is empty", i)
+		}
+	}
+
+	r.Target = strings.ToLower(strings.TrimSpace(r.Target))
+	if err := validateTarget(r.Target, ResolveFormat(r.Format, r.File)); err != nil {
+		return err
+	}
+
+	r.ExpireField = strings.TrimSpace(r.ExpireField)
+	if r.ExpireField == "" {
+		r.ExpireField = ExpireFieldResetAt
+	}
+	if r.ExpireField != ExpireFieldResetAt && r.ExpireField != ExpireFieldServiceExpireAt {
+		return errors.New("expire_field must be reset_at or service_expire_at")
+	}
+
 	if r.AccessControl != nil {
 		if err := r.AccessControl.validate(); err != nil {
 			return fmt.Errorf("access_control: %w", err)
@@ -356,73 +948,831 @@ func (r *RouteItem) validate() error {
 		}
 	}
 
+	for i := range r.Variants {
+		if err := r.Variants[i].validate(r); err != nil {
+			return fmt.Errorf("variants[%d]: %w", i, err)
+		}
+	}
+
 	return nil
 }
 
-// validate 用于校验访问约束配置是否合法。
-func (r *AccessControlConfig) validate() error {
-	if strings.TrimSpace(r.UserAgent) == "" {
-		return errors.New("user_agent must not be empty")
+// validate 用于校验凭据规则，至少需要配置一个待替换的字段。
+func (r *CredentialRule) validate() error {
+	if r.UUID == "" && r.Password == "" && r.AuthStr == "" {
+		return errors.New("uuid, password or auth_str is required")
+	}
+
+	if _, err := regexp.Compile(r.Match); err != nil {
+		return errors.New("match is invalid")
 	}
 
 	return nil
 }
 
-// validateTrafficTemplate 用于校验流量模板是否语法正确且同时引用 used 与 total。
-func validateTrafficTemplate(format string) error {
-	// 使用不会出现在正常文本中的哨兵值，避免将模板外的字面量误判为占位符。
-	const (
-		usedSentinel  = "TRAFFIC_USED_SENTINEL"
-		totalSentinel = "TRAFFIC_TOTAL_SENTINEL"
-	)
-
-	result, err := ExecuteTemplate(format, map[string]string{
-		"used":  usedSentinel,
-		"total": totalSentinel,
+// validateClashProviders 用于校验注入的提供者列表，名称必须唯一，route 与 url 只能二选一。
+// 指向本服务路由的提供者未配置 type 时默认为 http。
+// 参数含义：providers 为 rule_providers 或 proxy_providers 列表。
+// 返回值：返回校验错误。
+func validateClashProviders(providers []ClashProvider) error {
+	names := make(map[string]struct{}, len(providers))
+	for i := range providers {
+		item := &providers[i]
+		item.Name = strings.TrimSpace(item.Name)
+		if item.Name == "" {
+			return fmt.Errorf("[%d] name is required", i)
+		}
+		if _, exist := names[item.Name]; exist {
+			return fmt.Errorf("duplicate name: %s", item.Name)
+		}
+		names[item.Name] = struct{}{}
+
+		if item.Options == nil {
+			item.Options = map[string]any{}
+		}
+
+		item.Route = strings.TrimSpace(item.Route)
+		if item.Route == "" {
+			if _, ok := item.Options["type"]; !ok {
+				return fmt.Errorf("%s: type is required", item.Name)
+			}
+			continue
+		}
+
+		if _, ok := item.Options["url"]; ok {
+			return fmt.Errorf("%s: route and url are mutually exclusive", item.Name)
+		}
+		route, err := pathutil.NormalizeRoutePath(item.Route)
+		if err != nil {
+			return fmt.Errorf("%s: route is invalid", item.Name)
+		}
+		item.Route = route
+		if _, ok := item.Options["type"]; !ok {
+			item.Options["type"] = "http"
+		}
+	}
+
+	return nil
+}
+
+// validate 用于校验节点处理步骤是否合法，并标准化节点类型与排序方式。
+func (r *TransformStep) validate() error {
+	configured := 0
+	if r.Include != nil {
+		configured++
+		if err := r.Include.validate(); err != nil {
+			return fmt.Errorf("include: %w", err)
+		}
+	}
+	if r.Exclude != nil {
+		configured++
+		if err := r.Exclude.validate(); err != nil {
+			return fmt.Errorf("exclude: %w", err)
+		}
+	}
+	if r.Rename != nil {
+		configured++
+		if _, err := regexp.Compile(r.Rename.Pattern); err != nil || r.Rename.Pattern == "" {
+			return errors.New("rename: pattern is invalid")
+		}
+	}
+	if r.Prefix != "" {
+		configured++
+		if err := validateAffixTemplate(r.Prefix); err != nil {
+			return fmt.Errorf("prefix: %w", err)
+		}
+	}
+	if r.Suffix != "" {
+		configured++
+		if err := validateAffixTemplate(r.Suffix); err != nil {
+			return fmt.Errorf("suffix: %w", err)
+		}
+	}
+	if r.Sort != "" {
+		configured++
+		r.Sort = strings.ToLower(strings.TrimSpace(r.Sort))
+		if r.Sort != SortByName && r.Sort != SortByRegion {
+			return errors.New("sort must be name or region")
+		}
+	}
+
+	if configured != 1 {
+		return errors.New("exactly one of include, exclude, rename, prefix, suffix or sort is required")
+	}
+
+	return nil
+}
+
+// validate 用于校验节点匹配条件是否合法，并把节点类型统一为小写。
+func (r *NodeFilter) validate() error {
+	if r.Name == "" && len(r.Type) == 0 {
+		return errors.New("name or type is required")
+	}
+
+	if _, err := regexp.Compile(r.Name); err != nil {
+		return errors.New("name is invalid")
+	}
+
+	for i, nodeType := range r.Type {
+		r.Type[i] = strings.ToLower(strings.TrimSpace(nodeType))
+	}
+
+	return nil
+}
+
+// validateAffixTemplate 用于校验节点名称前后缀模板的语法，且只能引用 flag、region 与 type。
+func validateAffixTemplate(format string) error {
+	if _, err := ExecuteTemplate(format, map[string]string{"flag": "", "region": "", "type": ""}); err != nil {
+		return errors.New("template is invalid")
+	}
+
+	return nil
+}
+
+// normalizeFiles 用于标准化待合并的订阅文件列表，并确定基准文件。
+// 参数含义：无。
+// 返回值：返回校验错误。
+func (r *RouteItem) normalizeFiles() error {
+	if r.URL != "" {
+		return errors.New("files and url are mutually exclusive")
+	}
+
+	seen := make(map[string]struct{}, len(r.Files))
+	for i, file := range r.Files {
+		normalizedFile, err := pathutil.NormalizeSubscriptionFilePath(file)
+		if err != nil {
+			return fmt.Errorf("files[%d] is invalid", i)
+		}
+		if _, ok := seen[normalizedFile]; ok {
+			return fmt.Errorf("files[%d] is duplicated", i)
+		}
+		seen[normalizedFile] = struct{}{}
+		r.Files[i] = normalizedFile
+	}
+
+	if strings.TrimSpace(r.File) == "" {
+		r.File = r.Files[0]
+		return nil
+	}
+
+	normalizedFile, err := pathutil.NormalizeSubscriptionFilePath(r.File)
+	if err != nil {
+		return errors.New("file is invalid")
+	}
+	if _, ok := seen[normalizedFile]; !ok {
+		return errors.New("file must be one of files")
+	}
+
+	return nil
+}
+
+// validate 用于校验合并参数是否合法，并补齐默认后缀模板。
+func (r *MergeConfig) validate() error {
+	if r.ConflictSuffix == "" {
+		r.ConflictSuffix = defaultMergeConflictSuffix
+	}
+
+	// 使用不会出现在正常文本中的哨兵值，确认模板确实引用了序号，保证重命名总能得到不同的名称。
+	const indexSentinel = "MERGE_INDEX_SENTINEL"
+	result, err := ExecuteTemplate(r.ConflictSuffix, map[string]string{
+		"index": indexSentinel,
+		"file":  "",
 	})
 	if err != nil {
-		return errors.New("traffic_format is invalid")
+		return errors.New("conflict_suffix is invalid")
 	}
 
-	if !strings.Contains(result, usedSentinel) || !strings.Contains(result, totalSentinel) {
-		return errors.New("traffic_format must contain {{.used}} and {{.total}}")
+	if !strings.Contains(result, indexSentinel) {
+		return errors.New("conflict_suffix must contain {{.index}}")
 	}
 
 	return nil
 }
 
-// validateResetTimeTemplate 用于校验重置时间模板是否语法正确，且只能引用年月日占位符。
-func validateResetTimeTemplate(format string) error {
-	// 使用不会出现在正常文本中的哨兵值，避免将模板外的字面量误判为占位符。
-	const (
-		yearSentinel  = "RESET_TIME_YEAR_SENTINEL"
-		monthSentinel = "RESET_TIME_MONTH_SENTINEL"
-		daySentinel   = "RESET_TIME_DAY_SENTINEL"
-	)
-
-	result, err := ExecuteTemplate(format, map[string]string{
-		"year":  yearSentinel,
-		"month": monthSentinel,
-		"day":   daySentinel,
-	})
+// validate 用于校验远程订阅源的拉取参数是否合法。
+func (r *RemoteSourceConfig) validate() error {
+	if r.Timeout < 0 {
+		return errors.New("timeout must be >= 0")
+	}
+
+	if r.CacheTTL != nil && *r.CacheTTL < 0 {
+		return errors.New("cache_ttl must be >= 0")
+	}
+
+	for name := range r.Headers {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("header name must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// validateRemoteURL 用于校验远程订阅地址，仅支持带主机名的 http 与 https 地址。
+// 参数含义：raw 为已去除首尾空白的订阅地址。
+// 返回值：返回校验错误。
+func validateRemoteURL(raw string) error {
+	u, err := url.Parse(raw)
 	if err != nil {
-		return errors.New("reset_time_format is invalid")
+		return errors.New("url is invalid")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("url scheme must be http or https")
+	}
+
+	if u.Host == "" {
+		return errors.New("url host is required")
+	}
+
+	return nil
+}
+
+// remoteBackupFile 用于为远程订阅源生成默认的落盘文件路径。
+// 文件名取自地址与请求头的摘要，拉取参数相同的路由共用同一份副本，参数不同则互不覆盖。
+// 参数含义：rawURL 为已校验的订阅地址；remote 为可选的拉取参数。
+// 返回值：返回订阅目录下的相对文件路径。
+func remoteBackupFile(rawURL string, remote *RemoteSourceConfig) string {
+	hash := sha256.New()
+	hash.Write([]byte(rawURL))
+	if remote != nil {
+		hash.Write([]byte("\nuser-agent:" + strings.TrimSpace(remote.UserAgent)))
+
+		names := make([]string, 0, len(remote.Headers))
+		for name := range remote.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			hash.Write([]byte("\n" + strings.ToLower(strings.TrimSpace(name)) + ":" + remote.Headers[name]))
+		}
+	}
+
+	// url 已通过校验，这里解析只为取扩展名。
+	var ext string
+	if u, err := url.Parse(rawURL); err == nil {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+
+	return remoteBackupDir + "/" + hex.EncodeToString(hash.Sum(nil))[:16] + ext
+}
+
+// validate 用于校验路由变体是否合法，并标准化 UA、文件路径与格式。
+// 参数含义：route 为变体所属的路由，要求已完成自身字段的标准化。
+// 返回值：返回校验错误。
+func (v *RouteVariant) validate(route *RouteItem) error {
+	v.UserAgent = strings.TrimSpace(v.UserAgent)
+	if v.UserAgent == "" {
+		return errors.New("user_agent is required")
+	}
+
+	if strings.TrimSpace(v.File) != "" {
+		normalizedFile, err := pathutil.NormalizeSubscriptionFilePath(v.File)
+		if err != nil {
+			return errors.New("file is invalid")
+		}
+		v.File = normalizedFile
+	}
+
+	v.Format = strings.ToLower(strings.TrimSpace(v.Format))
+	if v.Format != "" && !IsValidFormat(v.Format) {
+		return errors.New("format is invalid")
+	}
+
+	// 节点处理与凭据替换作用于变体选中的文件，变体改用其他格式时这些配置无法生效。
+	format := v.resolveFormat(route)
+	if len(route.Transform) > 0 && format != FormatClash {
+		return errors.New("transform requires clash format")
+	}
+	if len(route.Credentials) > 0 && format != FormatClash {
+		return errors.New("credentials requires clash format")
+	}
+
+	v.Target = strings.ToLower(strings.TrimSpace(v.Target))
+	return validateTarget(cmp.Or(v.Target, route.Target), format)
+}
+
+// resolveFormat 用于确定变体的订阅格式：显式配置优先，其次按变体文件扩展名推断，未更换文件时沿用路由格式。
+// 参数含义：route 为变体所属的路由。
+// 返回值：返回变体最终使用的订阅格式。
+func (v *RouteVariant) resolveFormat(route *RouteItem) string {
+	if v.File != "" {
+		return ResolveFormat(v.Format, v.File)
+	}
+
+	return ResolveFormat(cmp.Or(v.Format, route.Format), route.File)
+}
+
+// validateTarget 用于校验转换目标格式，以及源格式是否支持转换。
+// 参数含义：target 为已标准化的目标格式，空串表示不转换；format 为源订阅格式。
+// 返回值：返回校验错误。
+func validateTarget(target string, format string) error {
+	if target == "" {
+		return nil
+	}
+
+	if target != TargetAuto && !convert.IsSupportedFormat(target) {
+		return errors.New("target must be auto, clash, singbox or uri-list")
+	}
+
+	if !convert.IsSupportedFormat(format) {
+		return errors.New("target requires a clash, singbox or uri-list source")
+	}
+
+	return nil
+}
+
+// IsValidFormat 用于校验订阅格式是否属于支持的范围。
+// 参数含义：format 为已标准化的订阅格式。
+// 返回值：支持时返回 true。
+func IsValidFormat(format string) bool {
+	switch format {
+	case FormatClash, FormatSingBox, FormatURIList, FormatSurge, FormatLoon, FormatQuantumultX:
+		return true
+	default:
+		return false
+	}
+}
+
+// ResolveFormat 用于确定路由的订阅格式，显式配置优先，未配置时按订阅文件扩展名推断。
+// 参数含义：format 为路由配置的格式；file 为订阅文件相对路径。
+// 返回值：返回最终使用的订阅格式，无法推断时默认按 Clash 处理。
+func ResolveFormat(format string, file string) string {
+	if format != "" {
+		return format
+	}
+
+	switch strings.ToLower(path.Ext(file)) {
+	case ".json":
+		return FormatSingBox
+	default:
+		return FormatClash
+	}
+}
+
+// validate 用于校验访问约束配置是否合法，并把用户名统一为小写，与 users 中的用户名保持一致。
+func (r *AccessControlConfig) validate() error {
+	if r.UserAgent.IsZero() && len(r.Users) == 0 && !r.Signed && r.IPAllow == nil && r.IPDeny == nil {
+		return errors.New("user_agent, users, signed, ip_allow or ip_deny is required")
+	}
+
+	if err := r.IPAccessRule.validate(); err != nil {
+		return err
+	}
+
+	if err := r.UserAgent.validate(); err != nil {
+		return fmt.Errorf("user_agent: %w", err)
+	}
+
+	for i, name := range r.Users {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return fmt.Errorf("users[%d] must not be empty", i)
+		}
+		r.Users[i] = name
+	}
+
+	return nil
+}
+
+// validate 用于校验 User-Agent 规则的匹配方式与模式是否合法，并统一匹配方式的大小写。
+func (r *UserAgentRule) validate() error {
+	r.Mode = strings.ToLower(strings.TrimSpace(r.Mode))
+	if r.Mode == "" {
+		r.Mode = UserAgentModeRegex
+	}
+
+	_, err := NewUserAgentMatcher(*r)
+	return err
+}
+
+// validate 用于校验 IP 访问约束中的条目是否为合法的 IP 或 CIDR。
+func (r *IPAccessRule) validate() error {
+	if _, err := parseIPPrefixes(r.IPAllow); err != nil {
+		return fmt.Errorf("ip_allow: %w", err)
+	}
+
+	if _, err := parseIPPrefixes(r.IPDeny); err != nil {
+		return fmt.Errorf("ip_deny: %w", err)
+	}
+
+	return nil
+}
+
+// parseIPPrefixes 用于把 IP 或 CIDR 列表解析为网段，单个 IP 视为只包含自身的网段。
+// 参数含义：entries 为配置中的地址列表。
+// 返回值：返回解析后的网段和第一个非法条目的错误。
+func parseIPPrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("[%d] %q is not a valid ip or cidr", i, entry)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+// validate 用于校验签名密钥是否足够长。
+func (r *SigningConfig) validate() error {
+	for i, secret := range r.Secrets {
+		if len(secret) < minSigningSecretLength {
+			return fmt.Errorf("secrets[%d] must be at least %d characters", i, minSigningSecretLength)
+		}
 	}
 
-	if !strings.Contains(result, yearSentinel) &&
-		!strings.Contains(result, monthSentinel) &&
-		!strings.Contains(result, daySentinel) {
-		return errors.New("reset_time_format must contain at least one date placeholder")
+	return nil
+}
+
+// validate 用于校验用户令牌配置是否合法，摘要统一为小写十六进制。
+func (r *UserItem) validate() error {
+	r.TokenSHA256 = strings.ToLower(strings.TrimSpace(r.TokenSHA256))
+	if (r.Token == "") == (r.TokenSHA256 == "") {
+		return errors.New("exactly one of token or token_sha256 is required")
+	}
+
+	if r.TokenSHA256 != "" {
+		if decoded, err := hex.DecodeString(r.TokenSHA256); err != nil || len(decoded) != sha256.Size {
+			return errors.New("token_sha256 must be a hex encoded sha256 digest")
+		}
+	}
+
+	return nil
+}
+
+// tokenHash 用于返回用户令牌的 SHA-256 摘要，明文令牌在这里计算摘要。
+// 参数含义：无。
+// 返回值：返回令牌摘要；摘要配置非法时返回错误。
+func (r UserItem) tokenHash() ([sha256.Size]byte, error) {
+	if r.Token != "" {
+		return HashToken(r.Token), nil
+	}
+
+	var digest [sha256.Size]byte
+	decoded, err := hex.DecodeString(strings.TrimSpace(r.TokenSHA256))
+	if err != nil || len(decoded) != sha256.Size {
+		return digest, errors.New("token_sha256 must be a hex encoded sha256 digest")
+	}
+	copy(digest[:], decoded)
+
+	return digest, nil
+}
+
+// HashToken 用于计算访问令牌的 SHA-256 摘要，校验时只比较摘要，保证比较耗时与令牌内容无关。
+// 参数含义：token 为明文令牌。
+// 返回值：返回令牌摘要。
+func HashToken(token string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(token))
+}
+
+// usageTemplateSample 为校验流量展示模板时使用的示例数据，字段与类型需与运行时的展示数据保持一致。
+// 流量与重置时间两个模板共用同一份数据，任一模板都可以引用全部字段。
+var usageTemplateSample = map[string]any{
+	"used":              "1G",
+	"total":             "100G",
+	"remaining":         "99G",
+	"upload":            "0G",
+	"download":          "1G",
+	"used_bytes":        int64(1 << 30),
+	"total_bytes":       int64(100 << 30),
+	"remaining_bytes":   int64(99 << 30),
+	"upload_bytes":      int64(0),
+	"download_bytes":    int64(1 << 30),
+	"percent":           1.0,
+	"days_left":         30,
+	"reset_at":          int64(1767225600),
+	"reset_date":        "2026-01-01",
+	"year":              "2026",
+	"month":             "01",
+	"day":               "01",
+	"hour":              "08",
+	"minute":            "00",
+	"weekday":           "周四",
+	"service_expire_at": int64(0),
+	"service_year":      "",
+	"service_month":     "",
+	"service_day":       "",
+}
+
+// validateTrafficTemplate 用于校验流量模板是否语法正确，且至少引用一个展示变量。
+func validateTrafficTemplate(format string) error {
+	return validateUsageTemplate("traffic_format", format)
+}
+
+// validateResetTimeTemplate 用于校验重置时间模板是否语法正确，且至少引用一个展示变量。
+func validateResetTimeTemplate(format string) error {
+	return validateUsageTemplate("reset_time_format", format)
+}
+
+// validateUsageLines 用于校验展示行的模板与条件，展示行允许不引用任何变量，例如固定的提醒文案。
+// 参数含义：lines 为展示行列表。
+// 返回值：返回校验错误。
+func validateUsageLines(lines []UsageLine) error {
+	for i, line := range lines {
+		if strings.TrimSpace(line.Template) == "" {
+			return fmt.Errorf("lines[%d]: template is required", i)
+		}
+		if _, err := RenderTemplate(line.Template, usageTemplateSample); err != nil {
+			return fmt.Errorf("lines[%d]: template is invalid", i)
+		}
+		if _, err := MatchUsageCondition(line.When, usageTemplateSample); err != nil {
+			return fmt.Errorf("lines[%d]: when is invalid: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// MatchUsageCondition 用于判断展示数据是否满足展示行的条件。
+// 条件由 && 连接的若干子条件组成，子条件为单独的变量名（非空、非零即满足）或“变量 比较符 值”，
+// 比较符支持 >、>=、<、<=、==、!=，数值变量按数字比较，其余变量按字符串比较且只支持 == 与 !=。
+// 参数含义：when 为条件表达式，为空时总是满足；data 为展示数据。
+// 返回值：返回是否满足条件，表达式非法或引用未知变量时返回错误。
+func MatchUsageCondition(when string, data map[string]any) (bool, error) {
+	if strings.TrimSpace(when) == "" {
+		return true, nil
+	}
+
+	matched := true
+	for _, clause := range strings.Split(when, "&&") {
+		ok, err := matchUsageClause(strings.TrimSpace(clause), data)
+		if err != nil {
+			return false, err
+		}
+		// 继续检查剩余子条件，保证配置校验能发现后面的语法错误。
+		matched = matched && ok
+	}
+
+	return matched, nil
+}
+
+// usageConditionPattern 用于拆分“变量 比较符 值”形式的子条件，值可以带引号。
+var usageConditionPattern = regexp.MustCompile(`^([a-z_]+)\s*(>=|<=|==|!=|>|<)\s*(.+)$`)
+
+// matchUsageClause 用于判断单个子条件是否满足。
+// 参数含义：clause 为子条件；data 为展示数据。
+// 返回值：返回是否满足和语法错误。
+func matchUsageClause(clause string, data map[string]any) (bool, error) {
+	groups := usageConditionPattern.FindStringSubmatch(clause)
+	if groups == nil {
+		value, ok := data[clause]
+		if !ok {
+			return false, fmt.Errorf("unknown variable %q", clause)
+		}
+		return value != "" && value != 0 && value != 0.0 && value != int64(0), nil
+	}
+
+	name, op, literal := groups[1], groups[2], strings.TrimSpace(groups[3])
+	value, ok := data[name]
+	if !ok {
+		return false, fmt.Errorf("unknown variable %q", name)
+	}
+
+	if number, ok := usageConditionNumber(value); ok {
+		expected, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s must be compared with a number", name)
+		}
+		switch op {
+		case ">":
+			return number > expected, nil
+		case ">=":
+			return number >= expected, nil
+		case "<":
+			return number < expected, nil
+		case "<=":
+			return number <= expected, nil
+		case "==":
+			return number == expected, nil
+		default:
+			return number != expected, nil
+		}
+	}
+
+	if unquoted, err := strconv.Unquote(literal); err == nil {
+		literal = unquoted
+	} else {
+		literal = strings.Trim(literal, "'")
+	}
+	switch op {
+	case "==":
+		return fmt.Sprint(value) == literal, nil
+	case "!=":
+		return fmt.Sprint(value) != literal, nil
+	default:
+		return false, fmt.Errorf("%s only supports == and !=", name)
+	}
+}
+
+// usageConditionNumber 用于把数值类型的展示变量统一转换为浮点数。
+// 参数含义：value 为展示变量的值。
+// 返回值：返回浮点数与是否为数值类型。
+func usageConditionNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// validateUsageTemplate 用于按示例数据试渲染流量展示模板，拦截语法错误、未知变量与类型不匹配，并要求至少引用一个展示变量。
+// 参数含义：name 为配置项名称，用于错误信息；format 为待校验模板。
+// 返回值：返回校验错误。
+func validateUsageTemplate(name string, format string) error {
+	if _, err := RenderTemplate(format, usageTemplateSample); err != nil {
+		return fmt.Errorf("%s is invalid", name)
+	}
+
+	tpl, err := newTemplate(nil).Parse(format)
+	if err != nil {
+		return fmt.Errorf("%s is invalid", name)
+	}
+	if !referencesField(tpl.Tree.Root, usageTemplateSample) {
+		return fmt.Errorf("%s must contain at least one usage placeholder", name)
 	}
 
 	return nil
 }
 
+// referencesField 用于在模板语法树中查找对顶层数据字段的引用，条件分支与函数参数中的引用同样计入。
+// 参数含义：node 为语法树节点；fields 为顶层字段集合。
+// 返回值：引用了任一字段时返回 true。
+func referencesField(node parse.Node, fields map[string]any) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if referencesField(child, fields) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return referencesField(n.Pipe, fields)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, command := range n.Cmds {
+			if referencesField(command, fields) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if referencesField(arg, fields) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		_, ok := fields[n.Ident[0]]
+		return ok
+	case *parse.VariableNode:
+		// $ 始终指向顶层数据，$.used 与 .used 等价。
+		if len(n.Ident) < 2 || n.Ident[0] != "$" {
+			return false
+		}
+		_, ok := fields[n.Ident[1]]
+		return ok
+	case *parse.IfNode:
+		return referencesField(n.Pipe, fields) || referencesField(n.List, fields) || referencesField(n.ElseList, fields)
+	case *parse.WithNode:
+		return referencesField(n.Pipe, fields) || referencesField(n.List, fields) || referencesField(n.ElseList, fields)
+	case *parse.RangeNode:
+		return referencesField(n.Pipe, fields) || referencesField(n.List, fields) || referencesField(n.ElseList, fields)
+	}
+
+	return false
+}
+
+// progressBar 用于按百分比生成固定宽度的进度条，百分比超出 0 到 100 时按边界处理。
+// 参数含义：percent 为百分比，支持整数与浮点数；width 为进度条字符数。
+// 返回值：返回由实心与空心方块组成的进度条。
+func progressBar(percent any, width int) (string, error) {
+	var value float64
+	switch v := percent.(type) {
+	case float64:
+		value = v
+	case int:
+		value = float64(v)
+	case int64:
+		value = float64(v)
+	default:
+		return "", fmt.Errorf("progress_bar: unsupported percent type %T", percent)
+	}
+
+	if width <= 0 {
+		return "", errors.New("progress_bar: width must be positive")
+	}
+
+	filled := int(math.Round(min(max(value, 0), 100) / 100 * float64(width)))
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled), nil
+}
+
+// humanBytesPrecision 为 humanBytes 自动选择单位时保留的小数位数。
+const humanBytesPrecision = 2
+
+// bytesFuncs 用于生成按指定小数位数与进制标准格式化流量的模板函数，bytes 使用固定单位，humanBytes 自动选择单位并保留两位小数。
+// 参数含义：precision 为 bytes 保留的小数位数；base 为进制标准。
+// 返回值：返回 bytes 与 humanBytes 两个模板函数。
+func bytesFuncs(precision int, base bytesize.Base) template.FuncMap {
+	return template.FuncMap{
+		"bytes": func(unit string, size int64) string { return bytesize.FormatWith(size, unit, precision, base) },
+		"humanBytes": func(size int64) string {
+			return bytesize.FormatWith(size, string(bytesize.UnitAuto), humanBytesPrecision, base)
+		},
+	}
+}
+
+// TemplateFuncs 用于返回按当前展示配置格式化流量的模板函数，覆盖默认函数表中按 1024 进制取整的 bytes 与 humanBytes，
+// 并让 date 按重置规则所在时区格式化时间戳，与 .reset_date 等字段保持一致。
+// 参数含义：loc 为重置规则所依据的时区，nil 表示使用服务本地时区。
+// 返回值：返回与 traffic_precision、traffic_base 一致的流量格式化函数与按 loc 格式化的 date 函数。
+func (r *UsageDisplayConfig) TemplateFuncs(loc *time.Location) template.FuncMap {
+	funcs := bytesFuncs(r.TrafficPrecision, bytesize.Base(r.TrafficBase))
+	funcs["date"] = dateFunc(cmp.Or(loc, time.Local))
+	return funcs
+}
+
+// dateFunc 用于生成按指定时区格式化 Unix 时间戳的 date 模板函数。
+// 参数含义：loc 为格式化使用的时区。
+// 返回值：返回接收 Go 时间布局与 Unix 时间戳的格式化函数。
+func dateFunc(loc *time.Location) func(string, int64) string {
+	return func(layout string, unix int64) string { return time.Unix(unix, 0).In(loc).Format(layout) }
+}
+
+// templateFuncs 为所有配置模板共用的函数表，只包含无副作用的字符串与格式化函数。
+var templateFuncs = template.FuncMap{
+	"default": func(fallback any, value any) any {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+	"upper":        strings.ToUpper,
+	"lower":        strings.ToLower,
+	"trim":         strings.TrimSpace,
+	"replace":      func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+	"contains":     func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":    func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":    func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":        func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":         func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"date":         dateFunc(time.Local),
+	"progress_bar": progressBar,
+	"progressBar":  progressBar, // progress_bar 的驼峰别名，与 hasPrefix、humanBytes 等函数的命名保持一致
+}
+
 // ExecuteTemplate 用于按统一的 Go 模板规则渲染字符串，借助 missingkey=error 拦截未知字段。
 // 参数含义：format 为待渲染模板；data 为模板可用字段。
 // 返回值：返回渲染后的字符串和模板执行错误。
 func ExecuteTemplate(format string, data map[string]string) (string, error) {
-	tpl, err := template.New("config").Option("missingkey=error").Parse(format)
+	return RenderTemplate(format, data)
+}
+
+// RenderTemplate 用于按与 ExecuteTemplate 相同的规则渲染任意结构的数据，供订阅文件模板等需要嵌套字段的场景使用。
+// 参数含义：format 为待渲染模板；data 为模板可用数据。
+// 返回值：返回渲染后的字符串和模板解析或执行错误。
+func RenderTemplate(format string, data any) (string, error) {
+	return renderTemplate(format, data, nil)
+}
+
+// RenderTemplate 用于渲染流量展示模板，bytes 与 humanBytes 按当前展示配置的小数位数与进制标准格式化，date 按 loc 格式化。
+// 参数含义：format 为待渲染模板；data 为模板可用数据；loc 为重置规则所依据的时区，nil 表示使用服务本地时区。
+// 返回值：返回渲染后的字符串和模板解析或执行错误。
+func (r *UsageDisplayConfig) RenderTemplate(format string, data any, loc *time.Location) (string, error) {
+	return renderTemplate(format, data, r.TemplateFuncs(loc))
+}
+
+// newTemplate 用于创建带统一函数表的模板，funcs 中的同名函数覆盖默认实现。
+// 参数含义：funcs 为额外的模板函数，可为 nil。
+// 返回值：返回尚未解析内容的模板。
+func newTemplate(funcs template.FuncMap) *template.Template {
+	return template.New("config").Option("missingkey=error").
+		Funcs(templateFuncs).
+		Funcs(bytesFuncs(0, bytesize.BaseIEC)).
+		Funcs(funcs)
+}
+
+// renderTemplate 用于解析并执行模板。
+// 参数含义：format 为待渲染模板；data 为模板可用数据；funcs 为覆盖默认实现的模板函数，可为 nil。
+// 返回值：返回渲染后的字符串和模板解析或执行错误。
+func renderTemplate(format string, data any, funcs template.FuncMap) (string, error) {
+	tpl, err := newTemplate(funcs).Parse(format)
 	if err != nil {
 		return "", err
 	}
@@ -434,3 +1784,87 @@ func ExecuteTemplate(format string, data map[string]string) (string, error) {
 
 	return buffer.String(), nil
 }
+
+// RenderVarsTemplate 用于渲染引用路由变量的模板，渲染规则与 RenderTemplate 相同。
+// 配置解析会把 vars 的键统一转为小写，这里同样把 .vars 与 $.vars 之后的字段名转为小写，使 {{.vars.MyKey}} 也能命中。
+// 参数含义：format 为待渲染模板；data 为模板可用数据，其中 vars 为路由变量；funcs 为覆盖默认实现的模板函数，可为 nil。
+// 返回值：返回渲染后的字符串和模板解析或执行错误。
+func RenderVarsTemplate(format string, data any, funcs template.FuncMap) (string, error) {
+	tpl, err := newTemplate(funcs).Parse(format)
+	if err != nil {
+		return "", err
+	}
+
+	// define 定义的子模板同样可能引用变量，需要逐个处理。
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil {
+			lowercaseVarsFields(t.Tree.Root)
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := tpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+// lowercaseVarsFields 用于把模板语法树中 .vars 与 $.vars 之后的字段名转为小写，与配置解析后的变量键保持一致。
+// 参数含义：node 为语法树节点。
+// 返回值：无。
+func lowercaseVarsFields(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			lowercaseVarsFields(child)
+		}
+	case *parse.ActionNode:
+		lowercaseVarsFields(n.Pipe)
+	case *parse.TemplateNode:
+		lowercaseVarsFields(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, command := range n.Cmds {
+			lowercaseVarsFields(command)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			lowercaseVarsFields(arg)
+		}
+	case *parse.ChainNode:
+		lowercaseVarsFields(n.Node)
+	case *parse.FieldNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "vars" {
+			lowercaseIdents(n.Ident[1:])
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 2 && n.Ident[0] == "$" && n.Ident[1] == "vars" {
+			lowercaseIdents(n.Ident[2:])
+		}
+	case *parse.IfNode:
+		lowercaseVarsFields(n.Pipe)
+		lowercaseVarsFields(n.List)
+		lowercaseVarsFields(n.ElseList)
+	case *parse.WithNode:
+		lowercaseVarsFields(n.Pipe)
+		lowercaseVarsFields(n.List)
+		lowercaseVarsFields(n.ElseList)
+	case *parse.RangeNode:
+		lowercaseVarsFields(n.Pipe)
+		lowercaseVarsFields(n.List)
+		lowercaseVarsFields(n.ElseList)
+	}
+}
+
+// lowercaseIdents 用于原地把字段名列表转为小写。
+func lowercaseIdents(idents []string) {
+	for i, ident := range idents {
+		idents[i] = strings.ToLower(ident)
+	}
+}
@@ -5,20 +5,165 @@ import (
 	"testing"
 )
 
-// TestUsageDisplayConfig_RejectsResetTimeTemplateWithHour 用于验证重置时间模板不允许引用时分秒占位符。
+// TestUsageDisplayConfig_ValidatesUsageTemplateVariables 用于验证展示模板可通过 . 或 $ 引用任意已知变量，但拒绝未知变量与不含任何变量的模板。
 // 参数含义：t 为测试上下文。
 // 返回值：无。
-func TestUsageDisplayConfig_RejectsResetTimeTemplateWithHour(t *testing.T) {
+func TestUsageDisplayConfig_ValidatesUsageTemplateVariables(t *testing.T) {
+	t.Parallel()
+
+	conf := &UsageDisplayConfig{
+		TrafficFormat:   "剩余 {{.remaining}}（{{.percent}}%）{{progress_bar .percent 10}}{{if ge .percent 80.0}} 即将用尽{{end}}",
+		TrafficUnit:     "G",
+		ResetTimeFormat: "重置 {{.month}}-{{.day}} {{.hour}}:{{.minute}} {{.weekday}}，还有 {{.days_left}} 天",
+	}
+	if err := conf.validate(); err != nil {
+		t.Fatalf("expected usage templates to be valid, got: %v", err)
+	}
+
+	conf.ResetTimeFormat = "重置 {{.year}}-{{.month}}-{{.day}} {{.second}}"
+	if err := conf.validate(); err == nil || !strings.Contains(err.Error(), "reset_time_format") {
+		t.Fatalf("expected reset_time_format validation error for unknown variable, got: %v", err)
+	}
+
+	conf.ResetTimeFormat = "📅 重置日期 {{.year}}-{{.month}}-{{.day}}"
+	conf.TrafficFormat = "{{progressBar .percent 10}} {{.used}}"
+	if err := conf.validate(); err != nil {
+		t.Fatalf("expected progressBar alias to be valid, got: %v", err)
+	}
+
+	conf.TrafficFormat = "{{range $i, $unit := split \",\" \"G\"}}{{$.used}}{{end}}"
+	if err := conf.validate(); err != nil {
+		t.Fatalf("expected $.used to count as a usage placeholder, got: %v", err)
+	}
+
+	conf.TrafficFormat = "{{if true}}固定文案{{end}}"
+	if err := conf.validate(); err == nil || !strings.Contains(err.Error(), "traffic_format must contain") {
+		t.Fatalf("expected traffic_format placeholder error, got: %v", err)
+	}
+}
+
+// TestProgressBar_ClampsPercent 用于验证进度条按宽度取整，且百分比超出范围时按边界处理。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestProgressBar_ClampsPercent(t *testing.T) {
+	t.Parallel()
+
+	cases := map[any]string{
+		42.5: "████░░░░░░",
+		150:  "██████████",
+		-3.0: "░░░░░░░░░░",
+	}
+	for percent, want := range cases {
+		got, err := progressBar(percent, 10)
+		if err != nil || got != want {
+			t.Fatalf("progressBar(%v, 10) = %q, %v; want %q", percent, got, err, want)
+		}
+	}
+
+	if _, err := progressBar("42", 10); err == nil {
+		t.Fatalf("expected progressBar to reject string percent")
+	}
+}
+
+// TestMatchUsageCondition_EvaluatesComparisons 用于验证展示条件支持数值比较、字符串比较、变量真值与 && 组合，并拒绝未知变量。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestMatchUsageCondition_EvaluatesComparisons(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{"percent": 85.5, "days_left": 3, "service_year": "", "weekday": "周一"}
+	cases := map[string]bool{
+		"":                               true,
+		"percent > 80":                   true,
+		"percent >= 90":                  false,
+		"days_left <= 3 && percent > 80": true,
+		"days_left < 3 && percent > 80":  false,
+		"service_year":                   false,
+		"weekday == '周一'":                true,
+		`weekday != "周一"`:                false,
+	}
+	for when, want := range cases {
+		got, err := MatchUsageCondition(when, data)
+		if err != nil || got != want {
+			t.Fatalf("MatchUsageCondition(%q) = %v, %v; want %v", when, got, err, want)
+		}
+	}
+
+	for _, when := range []string{"unknown > 1", "percent > high", "weekday > 1"} {
+		if _, err := MatchUsageCondition(when, data); err == nil {
+			t.Fatalf("expected MatchUsageCondition(%q) to return error", when)
+		}
+	}
+}
+
+// TestUsageDisplayConfig_ValidatesLines 用于验证展示行允许固定文案，但拒绝空模板与非法条件。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestUsageDisplayConfig_ValidatesLines(t *testing.T) {
 	t.Parallel()
 
 	conf := &UsageDisplayConfig{
 		TrafficFormat:   "已用 {{.used}} / {{.total}}",
 		TrafficUnit:     "G",
-		ResetTimeFormat: "重置 {{.year}}-{{.month}}-{{.day}} {{.hour}}",
+		ResetTimeFormat: "重置 {{.year}}-{{.month}}-{{.day}}",
+		Lines: []UsageLine{
+			{Template: "⚠️ 流量即将用尽", When: "percent > 80"},
+			{Template: "剩余 {{humanBytes .remaining_bytes}}，{{date \"2006-01-02\" .reset_at}} 重置"},
+		},
+	}
+	if err := conf.validate(); err != nil {
+		t.Fatalf("expected lines to be valid, got: %v", err)
+	}
+
+	conf.Lines = []UsageLine{{Template: "到期 {{.service_year}}", When: "service_year >"}}
+	if err := conf.validate(); err == nil || !strings.Contains(err.Error(), "lines[0]: when is invalid") {
+		t.Fatalf("expected invalid when error, got: %v", err)
+	}
+}
+
+// TestUsageDisplayConfig_FormatsTrafficWithPrecisionAndBase 用于验证自动单位、小数位数与进制标准的校验和格式化结果，模板中的 bytes 与 humanBytes 同样遵循该配置。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestUsageDisplayConfig_FormatsTrafficWithPrecisionAndBase(t *testing.T) {
+	t.Parallel()
+
+	conf := &UsageDisplayConfig{
+		TrafficFormat:    "⛽ {{.used}}",
+		ResetTimeFormat:  "📅 {{.day}}",
+		TrafficUnit:      "auto",
+		TrafficPrecision: 2,
+		TrafficBase:      "si",
+	}
+	if err := conf.validate(); err != nil {
+		t.Fatalf("expected auto unit with si base to be valid, got: %v", err)
+	}
+	if got := conf.FormatBytes(1_250_000_000); got != "1.25G" {
+		t.Fatalf("unexpected formatted traffic: %q", got)
+	}
+
+	// 模板函数与 FormatBytes 使用同一套小数位数与进制标准。
+	got, err := conf.RenderTemplate(`{{bytes "G" .size}} {{humanBytes .size}}`, map[string]any{"size": int64(1_250_000_000)}, nil)
+	if err != nil || got != "1.25G 1.25G" {
+		t.Fatalf("unexpected template traffic: %q, %v", got, err)
+	}
+	if got, _ := RenderTemplate(`{{bytes "G" .size}}`, map[string]any{"size": int64(1_250_000_000)}); got != "1G" {
+		t.Fatalf("unexpected default template traffic: %q", got)
+	}
+
+	conf.TrafficBase = "binary"
+	if err := conf.validate(); err == nil || !strings.Contains(err.Error(), "traffic_base") {
+		t.Fatalf("expected traffic_base validation error, got: %v", err)
+	}
+
+	conf.TrafficBase = ""
+	conf.TrafficPrecision = 7
+	if err := conf.validate(); err == nil || !strings.Contains(err.Error(), "traffic_precision") {
+		t.Fatalf("expected traffic_precision validation error, got: %v", err)
 	}
 
-	err := conf.validate()
-	if err == nil || !strings.Contains(err.Error(), "reset_time_format") {
-		t.Fatalf("expected reset_time_format validation error, got: %v", err)
+	negative := -1
+	override := &UsageDisplayOverride{TrafficPrecision: &negative}
+	if err := override.validate(); err == nil || !strings.Contains(err.Error(), "traffic_precision") {
+		t.Fatalf("expected override traffic_precision validation error, got: %v", err)
 	}
 }
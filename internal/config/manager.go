@@ -1,9 +1,18 @@
 package config
 
 import (
+	"cmp"
+	"crypto/subtle"
+	"errors"
 	"flag"
 	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 
 	"github.com/djx30103/vpsub/pkg/pathutil"
@@ -12,25 +21,348 @@ import (
 
 const defaultConfigPath = "config/config.yml"
 
+const (
+	// defaultRemoteUserAgent 为拉取远程订阅时未配置 user_agent 使用的默认值。
+	defaultRemoteUserAgent = "vpsub"
+	defaultRemoteTimeout   = 10 * time.Second
+	defaultRemoteCacheTTL  = 5 * time.Minute
+)
+
 // AppConfig 保存应用配置和预处理后的路由映射。
 type AppConfig struct {
 	RootConfig
-	PathToConfig map[string]PathConfig // 完整请求路径到配置的映射
+	PathToConfig   map[string]PathConfig // 完整请求路径到配置的映射
+	TrustedProxies *IPMatcher            // 编译后的可信代理地址，未配置时为 nil
 }
 
 // PathConfig 保存单个订阅请求路径对应的运行时配置。
 // ProviderConfig 在构建阶段已完成默认值合并，字段均可直接使用。
+// Remote 非 nil 时订阅内容从上游拉取，File 为最近一次成功拉取内容的落盘位置。
+// Files 非空时合并其中的全部订阅文件，File 为提供 rules 等其余字段的基准文件。
+// Template 为 true 时订阅内容在转换与注入之前先按 Go 模板渲染。
 type PathConfig struct {
-	Path         string
-	File         string
-	ProviderRef  string
-	ProviderType string
-	APIID        string
-	APIKey       string
+	Path           string
+	File           string
+	Files          []string
+	ConflictSuffix string
+	Remote         *RemoteSource
+	Template       bool
+	Vars           map[string]any
+	Transform      []NodeTransform
+	Credentials    []CredentialSubstitution
+	RuleProviders  []ClashProvider
+	ProxyProviders []ClashProvider
+	Rules          []string
+	ProviderRef    string
+	ProviderType   string
+	Format         string
+	Target         string
+	ExpireField    string
+	APIID          string
+	APIKey         string
 
 	ProviderConfig ProviderConfig
 	AccessControl  *AccessControlConfig
+	AccessUsers    []AccessUser
+	UserAgent      *UserAgentMatcher
+	IPAccess       *IPMatcher
 	UsageDisplay   *UsageDisplayConfig
+	Variants       []PathVariant
+}
+
+// UserAgentMatcher 表示编译后的 User-Agent 访问规则，正则已预先编译。
+// 字段含义：exact 为需完全一致的 User-Agent；mode 为匹配方式；allow 与 deny 为 prefix、contains 方式下的小写模式；
+// allowPatterns 与 denyPatterns 为 regex 方式下编译后的正则。
+type UserAgentMatcher struct {
+	exact         string
+	mode          string
+	allow         []string
+	deny          []string
+	allowPatterns []*regexp.Regexp
+	denyPatterns  []*regexp.Regexp
+}
+
+// NewUserAgentMatcher 用于编译 User-Agent 访问规则。
+// 参数含义：rule 为配置中的规则，Mode 为空时按 regex 处理。
+// 返回值：返回编译后的规则，规则未配置任何约束时返回 nil；匹配方式未知或正则非法时返回错误。
+func NewUserAgentMatcher(rule UserAgentRule) (*UserAgentMatcher, error) {
+	if rule.IsZero() {
+		return nil, nil
+	}
+
+	matcher := &UserAgentMatcher{exact: rule.Exact, mode: strings.ToLower(strings.TrimSpace(rule.Mode))}
+	if matcher.mode == "" {
+		matcher.mode = UserAgentModeRegex
+	}
+
+	switch matcher.mode {
+	case UserAgentModeRegex:
+		var err error
+		if matcher.allowPatterns, err = compileUserAgentPatterns("allow", rule.Allow); err != nil {
+			return nil, err
+		}
+		if matcher.denyPatterns, err = compileUserAgentPatterns("deny", rule.Deny); err != nil {
+			return nil, err
+		}
+	case UserAgentModePrefix, UserAgentModeContains:
+		for _, pattern := range rule.Allow {
+			matcher.allow = append(matcher.allow, strings.ToLower(pattern))
+		}
+		for _, pattern := range rule.Deny {
+			matcher.deny = append(matcher.deny, strings.ToLower(pattern))
+		}
+	default:
+		return nil, errors.New("mode must be regex, prefix or contains")
+	}
+
+	return matcher, nil
+}
+
+// compileUserAgentPatterns 用于编译 regex 方式下的模式列表。
+// 参数含义：field 为字段名，用于错误信息；patterns 为正则列表。
+// 返回值：返回编译后的正则和编译错误。
+func compileUserAgentPatterns(field string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d] is invalid: %w", field, i, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// Match 用于判断 User-Agent 是否满足规则：配置了 exact 时需完全一致，命中任一拒绝模式即拒绝，配置了允许模式时需命中其一。
+// 参数含义：userAgent 为请求中的 User-Agent。
+// 返回值：满足规则时返回 true。
+func (m *UserAgentMatcher) Match(userAgent string) bool {
+	if m.exact != "" && userAgent != m.exact {
+		return false
+	}
+	if m.matchAny(userAgent, m.deny, m.denyPatterns) {
+		return false
+	}
+	if len(m.allow) == 0 && len(m.allowPatterns) == 0 {
+		return true
+	}
+
+	return m.matchAny(userAgent, m.allow, m.allowPatterns)
+}
+
+// matchAny 用于按匹配方式判断 User-Agent 是否命中任一模式。
+// 参数含义：userAgent 为请求中的 User-Agent；patterns 为 prefix、contains 方式下的小写模式；regexps 为 regex 方式下的正则。
+// 返回值：命中任一模式时返回 true。
+func (m *UserAgentMatcher) matchAny(userAgent string, patterns []string, regexps []*regexp.Regexp) bool {
+	for _, re := range regexps {
+		if re.MatchString(userAgent) {
+			return true
+		}
+	}
+
+	lowered := strings.ToLower(userAgent)
+	for _, pattern := range patterns {
+		if m.mode == UserAgentModePrefix && strings.HasPrefix(lowered, pattern) {
+			return true
+		}
+		if m.mode == UserAgentModeContains && strings.Contains(lowered, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IPMatcher 表示编译后的客户端 IP 访问约束。
+// 字段含义：allow 为允许的网段，为空时不限制；deny 为拒绝的网段，优先于 allow。
+type IPMatcher struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewIPMatcher 用于编译客户端 IP 访问约束。
+// 参数含义：rule 为合并默认配置后的 IP 约束。
+// 返回值：返回编译后的约束，未配置任何地址时返回 nil；地址非法时返回错误。
+func NewIPMatcher(rule IPAccessRule) (*IPMatcher, error) {
+	if len(rule.IPAllow) == 0 && len(rule.IPDeny) == 0 {
+		return nil, nil
+	}
+
+	allow, err := parseIPPrefixes(rule.IPAllow)
+	if err != nil {
+		return nil, fmt.Errorf("ip_allow: %w", err)
+	}
+	deny, err := parseIPPrefixes(rule.IPDeny)
+	if err != nil {
+		return nil, fmt.Errorf("ip_deny: %w", err)
+	}
+
+	return &IPMatcher{allow: allow, deny: deny}, nil
+}
+
+// Match 用于判断客户端 IP 是否满足约束，IPv4 映射的 IPv6 地址按 IPv4 处理。
+// 参数含义：ip 为客户端 IP 字符串。
+// 返回值：满足约束时返回 true；无法解析的地址视为不满足。
+func (m *IPMatcher) Match(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range m.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(m.allow) == 0 {
+		return true
+	}
+	for _, prefix := range m.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AccessUser 表示路由允许访问的用户，令牌仅以摘要形式保存。
+// 字段含义：Name 为用户名；TokenHash 为令牌的 SHA-256 摘要。
+type AccessUser struct {
+	Name      string
+	TokenHash [32]byte
+}
+
+// MatchAccessUser 用于按令牌查找允许访问的用户。
+// 比较的是令牌摘要，且总是比较全部用户，耗时不随令牌内容或命中位置变化。
+// 参数含义：users 为路由允许访问的用户；token 为请求携带的令牌。
+// 返回值：返回命中的用户名，未命中或令牌为空时 ok 为 false。
+func MatchAccessUser(users []AccessUser, token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+
+	digest := HashToken(token)
+	matched := -1
+	for i, user := range users {
+		if subtle.ConstantTimeCompare(digest[:], user.TokenHash[:]) == 1 && matched < 0 {
+			matched = i
+		}
+	}
+	if matched < 0 {
+		return "", false
+	}
+
+	return users[matched].Name, true
+}
+
+// NodeTransform 表示编译后的节点处理步骤，正则已预先编译，Op 为 include、exclude、rename、prefix、suffix 或 sort 之一。
+// 字段含义：Name 为名称正则，include 与 exclude 未配置名称条件时为 nil；Types 为节点类型；
+// Replace 为重命名替换串；Affix 为前后缀模板；SortBy 为排序方式。
+type NodeTransform struct {
+	Op      string
+	Name    *regexp.Regexp
+	Types   []string
+	Replace string
+	Affix   string
+	SortBy  string
+}
+
+// 节点处理步骤的操作类型。
+const (
+	TransformInclude = "include"
+	TransformExclude = "exclude"
+	TransformRename  = "rename"
+	TransformPrefix  = "prefix"
+	TransformSuffix  = "suffix"
+	TransformSort    = "sort"
+)
+
+// Matches 用于判断节点是否满足 include 或 exclude 步骤的匹配条件。
+// 参数含义：name 为节点名称；nodeType 为节点类型。
+// 返回值：名称正则与节点类型均满足（未配置的条件视为满足）时返回 true。
+func (t NodeTransform) Matches(name string, nodeType string) bool {
+	if t.Name != nil && !t.Name.MatchString(name) {
+		return false
+	}
+
+	if len(t.Types) > 0 && !slices.Contains(t.Types, strings.ToLower(nodeType)) {
+		return false
+	}
+
+	return true
+}
+
+// CredentialSubstitution 表示编译后的凭据替换规则。
+// 字段含义：Match 为节点名称正则，为 nil 时匹配全部节点；Fields 为 Clash 节点字段名到替换值的映射。
+type CredentialSubstitution struct {
+	Match  *regexp.Regexp
+	Fields map[string]string
+}
+
+// RemoteSource 表示运行时的远程订阅源，默认值已补齐。
+// 字段含义：URL 为订阅地址；UserAgent 与 Headers 为拉取时附带的请求头；Timeout 为拉取超时；CacheTTL 为拉取结果的内存缓存时间，0 表示不缓存。
+type RemoteSource struct {
+	URL       string
+	UserAgent string
+	Headers   map[string]string
+	Timeout   time.Duration
+	CacheTTL  time.Duration
+}
+
+// CacheKey 用于生成远程订阅源的缓存键，地址、User-Agent 或请求头不同的拉取互不共享结果。
+// 参数含义：无。
+// 返回值：返回由地址与排序后的请求头拼接成的缓存键。
+func (r *RemoteSource) CacheKey() string {
+	var key strings.Builder
+	key.WriteString(r.URL)
+	key.WriteString("\nuser-agent:" + r.UserAgent)
+
+	names := make([]string, 0, len(r.Headers))
+	for name := range r.Headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		key.WriteString("\n" + strings.ToLower(name) + ":" + r.Headers[name])
+	}
+
+	return key.String()
+}
+
+// PathVariant 表示运行时的路由变体，文件、格式与转换目标均已按路由配置补齐。
+// 字段含义：UserAgent 为小写的 UA 子串；File 为订阅文件；Files 为待合并的订阅文件，变体改用单个文件时为 nil；
+// Remote 为远程订阅源，变体改用本地文件时为 nil；Format 为订阅格式；Target 为转换目标格式。
+type PathVariant struct {
+	UserAgent string
+	File      string
+	Files     []string
+	Remote    *RemoteSource
+	Format    string
+	Target    string
+}
+
+// SelectVariant 用于按客户端 User-Agent 选择第一个匹配的路由变体，并返回替换了文件、格式与转换目标的配置副本。
+// 参数含义：userAgent 为请求头中的 User-Agent。
+// 返回值：返回本次请求使用的路径配置，没有匹配的变体时原样返回。
+func (p PathConfig) SelectVariant(userAgent string) PathConfig {
+	userAgent = strings.ToLower(userAgent)
+	for _, variant := range p.Variants {
+		if !strings.Contains(userAgent, variant.UserAgent) {
+			continue
+		}
+
+		p.File = variant.File
+		p.Files = variant.Files
+		p.Remote = variant.Remote
+		p.Format = variant.Format
+		p.Target = variant.Target
+		return p
+	}
+
+	return p
 }
 
 // Load 用于读取并反序列化配置文件，同时补齐默认值并执行静态校验。
@@ -44,7 +376,12 @@ func Load(path string) (RootConfig, error) {
 		return conf, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	if err := v.Unmarshal(&conf); err != nil {
+	decodeHook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		userAgentRuleHook,
+	))
+	if err := v.Unmarshal(&conf, decodeHook); err != nil {
 		return conf, fmt.Errorf("failed to unmarshal config file: %w", err)
 	}
 
@@ -71,6 +408,12 @@ func BuildRuntime(root RootConfig) (*AppConfig, error) {
 		return nil, err
 	}
 
+	trustedProxies, err := NewIPMatcher(IPAccessRule{IPAllow: root.Server.TrustedProxies})
+	if err != nil {
+		return nil, fmt.Errorf("server.trusted_proxies: %w", err)
+	}
+	appConfig.TrustedProxies = trustedProxies
+
 	return appConfig, nil
 }
 
@@ -117,6 +460,49 @@ func (a *AppConfig) preprocessConfig() error {
 		}
 	}
 
+	// 提供者引用的路由可能定义在后面，待全部路由建立映射后再统一检查。
+	for _, route := range a.Routes {
+		source, _ := pathutil.NormalizeRoutePath(route.Path)
+		for _, item := range slices.Concat(route.RuleProviders, route.ProxyProviders) {
+			if item.Route == "" {
+				continue
+			}
+			target, exist := a.PathToConfig[item.Route]
+			if !exist {
+				return fmt.Errorf("route %q provider %q: route not found: %s", route.Path, item.Name, item.Route)
+			}
+			if err := checkRouteProviderAccess(a.PathToConfig[source], target); err != nil {
+				return fmt.Errorf("route %q provider %q: %w", route.Path, item.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkRouteProviderAccess 用于检查提供者引用的路由能否用当前路由的访问令牌访问。
+// 展开的地址只能附带请求者的用户令牌：被引用的路由只接受签名地址、当前路由无需用户令牌即可访问，
+// 或当前路由的某个用户无权访问被引用的路由时，客户端拉取提供者都会失败，因此在加载时拒绝。
+// 参数含义：source 为配置提供者的路由；target 为被引用的路由。
+// 返回值：返回无法满足访问约束时的错误。
+func checkRouteProviderAccess(source, target PathConfig) error {
+	targetSigned := target.AccessControl != nil && target.AccessControl.Signed
+	if len(target.AccessUsers) == 0 {
+		if targetSigned {
+			return fmt.Errorf("route %s only accepts signed urls", target.Path)
+		}
+		return nil
+	}
+
+	if len(source.AccessUsers) == 0 || (source.AccessControl != nil && source.AccessControl.Signed) {
+		return fmt.Errorf("route %s requires a user token that requests to this route may not carry", target.Path)
+	}
+	for _, user := range source.AccessUsers {
+		if !slices.ContainsFunc(target.AccessUsers, func(item AccessUser) bool { return item.Name == user.Name }) {
+			return fmt.Errorf("user %s cannot access route %s", user.Name, target.Path)
+		}
+	}
+
 	return nil
 }
 
@@ -138,6 +524,16 @@ func (a *AppConfig) buildPathForRoute(route RouteItem) error {
 		return fmt.Errorf("normalize path %q: %w", route.Path, err)
 	}
 
+	// 直接构建的根配置未经过 validate，这里同样为远程订阅源与合并路由补齐默认文件。
+	if strings.TrimSpace(route.File) == "" {
+		switch {
+		case route.URL != "":
+			route.File = remoteBackupFile(route.URL, route.Remote)
+		case len(route.Files) > 0:
+			route.File = route.Files[0]
+		}
+	}
+
 	filePath, err := pathutil.NormalizeSubscriptionFilePath(route.File)
 	if err != nil {
 		return fmt.Errorf("normalize file %q: %w", route.File, err)
@@ -152,21 +548,291 @@ func (a *AppConfig) buildPathForRoute(route RouteItem) error {
 		return fmt.Errorf("route %q usage_display: %w", reqPath, err)
 	}
 
+	files, err := resolveMergeFiles(route)
+	if err != nil {
+		return err
+	}
+
+	accessUsers, err := a.resolveAccessUsers(route.AccessControl)
+	if err != nil {
+		return fmt.Errorf("route %q access_control: %w", reqPath, err)
+	}
+
+	var userAgent *UserAgentMatcher
+	if route.AccessControl != nil {
+		if userAgent, err = NewUserAgentMatcher(route.AccessControl.UserAgent); err != nil {
+			return fmt.Errorf("route %q access_control.user_agent: %w", reqPath, err)
+		}
+	}
+
+	ipAccess, err := NewIPMatcher(a.resolveIPAccess(route.AccessControl))
+	if err != nil {
+		return fmt.Errorf("route %q access_control: %w", reqPath, err)
+	}
+
+	transforms, err := compileTransforms(route.Transform)
+	if err != nil {
+		return fmt.Errorf("route %q transform: %w", reqPath, err)
+	}
+
+	credentials, err := compileCredentials(route.Credentials)
+	if err != nil {
+		return fmt.Errorf("route %q credentials: %w", reqPath, err)
+	}
+
+	remote := resolveRemoteSource(route)
 	a.PathToConfig[reqPath] = PathConfig{
 		Path:           reqPath,
 		File:           filePath,
+		Files:          files,
+		ConflictSuffix: resolveConflictSuffix(route, files),
+		Remote:         remote,
+		Template:       route.Template,
+		Vars:           route.Vars,
+		Transform:      transforms,
+		Credentials:    credentials,
+		RuleProviders:  route.RuleProviders,
+		ProxyProviders: route.ProxyProviders,
+		Rules:          route.Rules,
 		ProviderRef:    route.ProviderRef,
 		ProviderType:   providerItem.Type,
+		Format:         ResolveFormat(route.Format, filePath),
+		Target:         route.Target,
+		ExpireField:    route.ExpireField,
 		APIID:          providerItem.APIID,
 		APIKey:         providerItem.APIKey,
 		ProviderConfig: a.resolveProviderConfig(providerItem),
 		AccessControl:  route.AccessControl,
+		AccessUsers:    accessUsers,
+		UserAgent:      userAgent,
+		IPAccess:       ipAccess,
 		UsageDisplay:   usageDisplay,
+		Variants:       resolveVariants(route, filePath, files, remote),
 	}
 
 	return nil
 }
 
+// resolveIPAccess 用于合并默认与路由级的 IP 访问约束，路由中配置了的字段整体替换默认值，配置为空列表可取消默认约束。
+// 参数含义：accessControl 为路由访问约束，可为 nil。
+// 返回值：返回合并后的 IP 访问约束。
+func (a *AppConfig) resolveIPAccess(accessControl *AccessControlConfig) IPAccessRule {
+	var rule IPAccessRule
+	if a.Defaults.AccessControl != nil {
+		rule = *a.Defaults.AccessControl
+	}
+	if accessControl == nil {
+		return rule
+	}
+
+	if accessControl.IPAllow != nil {
+		rule.IPAllow = accessControl.IPAllow
+	}
+	if accessControl.IPDeny != nil {
+		rule.IPDeny = accessControl.IPDeny
+	}
+
+	return rule
+}
+
+// resolveAccessUsers 用于把路由允许访问的用户名解析为令牌摘要。
+// 参数含义：accessControl 为路由访问约束，可为 nil。
+// 返回值：返回允许访问的用户列表；引用了未定义的用户时返回错误。
+func (a *AppConfig) resolveAccessUsers(accessControl *AccessControlConfig) ([]AccessUser, error) {
+	if accessControl == nil || len(accessControl.Users) == 0 {
+		return nil, nil
+	}
+
+	users := make([]AccessUser, 0, len(accessControl.Users))
+	for _, name := range accessControl.Users {
+		name = strings.ToLower(strings.TrimSpace(name))
+		userItem, ok := a.Users[name]
+		if !ok {
+			return nil, fmt.Errorf("user not found: %s", name)
+		}
+		digest, err := userItem.tokenHash()
+		if err != nil {
+			return nil, fmt.Errorf("user %q: %w", name, err)
+		}
+		users = append(users, AccessUser{Name: name, TokenHash: digest})
+	}
+
+	return users, nil
+}
+
+// compileTransforms 用于把节点处理步骤编译为运行时配置。
+// 参数含义：steps 为路由配置中的节点处理步骤。
+// 返回值：返回按配置顺序排列的运行时步骤；正则非法或步骤未配置任何操作时返回错误。
+func compileTransforms(steps []TransformStep) ([]NodeTransform, error) {
+	if len(steps) == 0 {
+		return nil, nil
+	}
+
+	compiled := make([]NodeTransform, 0, len(steps))
+	for i, step := range steps {
+		var transform NodeTransform
+		switch {
+		case step.Include != nil, step.Exclude != nil:
+			filter, op := step.Include, TransformInclude
+			if filter == nil {
+				filter, op = step.Exclude, TransformExclude
+			}
+			transform = NodeTransform{Op: op, Types: filter.Type}
+			if filter.Name != "" {
+				name, err := regexp.Compile(filter.Name)
+				if err != nil {
+					return nil, fmt.Errorf("[%d] %s.name is invalid: %w", i, op, err)
+				}
+				transform.Name = name
+			}
+		case step.Rename != nil:
+			pattern, err := regexp.Compile(step.Rename.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("[%d] rename.pattern is invalid: %w", i, err)
+			}
+			transform = NodeTransform{Op: TransformRename, Name: pattern, Replace: step.Rename.Replace}
+		case step.Prefix != "":
+			transform = NodeTransform{Op: TransformPrefix, Affix: step.Prefix}
+		case step.Suffix != "":
+			transform = NodeTransform{Op: TransformSuffix, Affix: step.Suffix}
+		case step.Sort != "":
+			transform = NodeTransform{Op: TransformSort, SortBy: step.Sort}
+		default:
+			return nil, fmt.Errorf("[%d] has no operation", i)
+		}
+		compiled = append(compiled, transform)
+	}
+
+	return compiled, nil
+}
+
+// compileCredentials 用于把凭据规则编译为运行时配置，配置项名称换算为 Clash 节点字段名。
+// 参数含义：rules 为路由配置中的凭据规则。
+// 返回值：返回按配置顺序排列的运行时规则；match 正则非法时返回错误。
+func compileCredentials(rules []CredentialRule) ([]CredentialSubstitution, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	compiled := make([]CredentialSubstitution, 0, len(rules))
+	for i, rule := range rules {
+		substitution := CredentialSubstitution{Fields: make(map[string]string, 3)}
+		if rule.Match != "" {
+			match, err := regexp.Compile(rule.Match)
+			if err != nil {
+				return nil, fmt.Errorf("[%d] match is invalid: %w", i, err)
+			}
+			substitution.Match = match
+		}
+		for field, value := range map[string]string{"uuid": rule.UUID, "password": rule.Password, "auth-str": rule.AuthStr} {
+			if value != "" {
+				substitution.Fields[field] = value
+			}
+		}
+		compiled = append(compiled, substitution)
+	}
+
+	return compiled, nil
+}
+
+// resolveMergeFiles 用于标准化路由中待合并的订阅文件列表。
+// 参数含义：route 为单条路由配置。
+// 返回值：返回标准化后的文件列表，只配置了单个文件时无需合并，返回 nil。
+func resolveMergeFiles(route RouteItem) ([]string, error) {
+	if len(route.Files) < 2 {
+		return nil, nil
+	}
+
+	files := make([]string, 0, len(route.Files))
+	for _, file := range route.Files {
+		normalizedFile, err := pathutil.NormalizeSubscriptionFilePath(file)
+		if err != nil {
+			return nil, fmt.Errorf("normalize file %q: %w", file, err)
+		}
+		files = append(files, normalizedFile)
+	}
+
+	return files, nil
+}
+
+// resolveConflictSuffix 用于确定合并时重名节点与分组追加的后缀模板。
+// 参数含义：route 为单条路由配置；files 为标准化后的待合并文件列表。
+// 返回值：返回后缀模板，无需合并时返回空串。
+func resolveConflictSuffix(route RouteItem, files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	if route.Merge != nil && route.Merge.ConflictSuffix != "" {
+		return route.Merge.ConflictSuffix
+	}
+
+	return defaultMergeConflictSuffix
+}
+
+// resolveRemoteSource 用于把路由的远程订阅源补齐默认值。
+// 参数含义：route 为单条路由配置。
+// 返回值：返回运行时远程订阅源，路由未配置 url 时返回 nil。
+func resolveRemoteSource(route RouteItem) *RemoteSource {
+	if route.URL == "" {
+		return nil
+	}
+
+	resolved := &RemoteSource{
+		URL:       route.URL,
+		UserAgent: defaultRemoteUserAgent,
+		Timeout:   defaultRemoteTimeout,
+		CacheTTL:  defaultRemoteCacheTTL,
+	}
+
+	if route.Remote == nil {
+		return resolved
+	}
+
+	if userAgent := strings.TrimSpace(route.Remote.UserAgent); userAgent != "" {
+		resolved.UserAgent = userAgent
+	}
+	if route.Remote.Timeout > 0 {
+		resolved.Timeout = route.Remote.Timeout
+	}
+	if route.Remote.CacheTTL != nil {
+		// CacheTTL=0 是有效配置（每次请求都重新拉取），因此只判断 nil。
+		resolved.CacheTTL = *route.Remote.CacheTTL
+	}
+	resolved.Headers = route.Remote.Headers
+
+	return resolved
+}
+
+// resolveVariants 用于把路由变体补齐为运行时配置，未配置的字段沿用路由本身的设置。
+// 变体改用本地文件时不再拉取路由的远程订阅源，也不再合并路由的多个订阅文件。
+// 参数含义：route 为单条路由配置；filePath 为路由已标准化的订阅文件路径；files 为路由待合并的文件；remote 为路由的远程订阅源。
+// 返回值：返回按配置顺序排列的运行时变体。
+func resolveVariants(route RouteItem, filePath string, files []string, remote *RemoteSource) []PathVariant {
+	if len(route.Variants) == 0 {
+		return nil
+	}
+
+	variants := make([]PathVariant, 0, len(route.Variants))
+	for _, variant := range route.Variants {
+		variantFiles, variantRemote := files, remote
+		if variant.File != "" {
+			variantFiles, variantRemote = nil, nil
+		}
+
+		variants = append(variants, PathVariant{
+			UserAgent: strings.ToLower(variant.UserAgent),
+			File:      cmp.Or(variant.File, filePath),
+			Files:     variantFiles,
+			Remote:    variantRemote,
+			Format:    variant.resolveFormat(&route),
+			Target:    cmp.Or(variant.Target, route.Target),
+		})
+	}
+
+	return variants
+}
+
 // resolveProviderConfig 将默认值与账号级覆盖合并，返回完全填充的运行时配置。
 // 参数含义：providerItem 为服务商账号配置。
 // 返回值：返回解析完成后的运行时服务商配置。
@@ -176,6 +842,8 @@ func (a *AppConfig) resolveProviderConfig(providerItem ProviderItem) ProviderCon
 		RequestTimeout: *a.Defaults.Provider.RequestTimeout,
 		UpdateInterval: *a.Defaults.Provider.UpdateInterval,
 	}
+	a.Defaults.Provider.applyTransport(&resolved.Transport)
+	a.Defaults.Provider.applyBilling(&resolved)
 
 	if providerItem.Overrides == nil {
 		return resolved
@@ -192,6 +860,8 @@ func (a *AppConfig) resolveProviderConfig(providerItem ProviderItem) ProviderCon
 	if o.UpdateInterval != nil {
 		resolved.UpdateInterval = *o.UpdateInterval
 	}
+	o.applyTransport(&resolved.Transport)
+	o.applyBilling(&resolved)
 
 	return resolved
 }
@@ -235,7 +905,19 @@ func applyUsageDisplayOverrides(dst *UsageDisplayConfig, src *UsageDisplayOverri
 		dst.TrafficUnit = *src.TrafficUnit
 	}
 
+	if src.TrafficPrecision != nil {
+		dst.TrafficPrecision = *src.TrafficPrecision
+	}
+
+	if src.TrafficBase != nil {
+		dst.TrafficBase = *src.TrafficBase
+	}
+
 	if src.ResetTimeFormat != nil {
 		dst.ResetTimeFormat = *src.ResetTimeFormat
 	}
+
+	if src.Lines != nil {
+		dst.Lines = src.Lines
+	}
 }
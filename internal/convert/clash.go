@@ -0,0 +1,276 @@
+package convert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseClash 用于解析 Clash 配置中的 proxies 列表。
+// 参数含义：content 为 Clash YAML 内容。
+// 返回值：返回可转换节点、无法转换的节点和解析错误。
+func parseClash(content []byte) ([]Proxy, []Unsupported, error) {
+	var doc struct {
+		Proxies []map[string]any `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to read clash config: %w", err)
+	}
+	if len(doc.Proxies) == 0 {
+		return nil, nil, errors.New("no proxies found in clash config")
+	}
+
+	var (
+		proxies     []Proxy
+		unsupported []Unsupported
+	)
+	for _, item := range doc.Proxies {
+		proxy, err := clashToProxy(item)
+		if err != nil {
+			unsupported = append(unsupported, Unsupported{Name: proxy.Name, Type: proxy.Type, Reason: err.Error()})
+			continue
+		}
+		proxies, unsupported = collect(proxies, unsupported, proxy, proxy.Type)
+	}
+
+	return proxies, unsupported, nil
+}
+
+// clashToProxy 用于把单个 Clash 节点映射为统一节点模型。
+// 参数含义：m 为 Clash 节点的键值表。
+// 返回值：返回节点模型；存在模型无法表达的字段时返回错误，节点名称与类型仍会填充以便上报。
+func clashToProxy(m map[string]any) (Proxy, error) {
+	proxy := Proxy{
+		Type:           mapString(m, "type"),
+		Name:           mapString(m, "name"),
+		Server:         mapString(m, "server"),
+		Port:           mapInt(m, "port"),
+		ALPN:           mapStrings(m, "alpn"),
+		SkipCertVerify: mapBool(m, "skip-cert-verify"),
+		Fingerprint:    mapString(m, "client-fingerprint"),
+	}
+
+	switch proxy.Type {
+	case TypeShadowsocks:
+		if plugin := mapString(m, "plugin"); plugin != "" {
+			return proxy, fmt.Errorf("plugin %s is not supported", plugin)
+		}
+		proxy.Cipher = mapString(m, "cipher")
+		proxy.Password = mapString(m, "password")
+	case TypeVMess:
+		proxy.UUID = mapString(m, "uuid")
+		proxy.AlterID = mapInt(m, "alterId")
+		proxy.Cipher = mapString(m, "cipher")
+		proxy.TLS = mapBool(m, "tls")
+		proxy.SNI = mapString(m, "servername")
+	case TypeVLESS:
+		proxy.UUID = mapString(m, "uuid")
+		proxy.Flow = mapString(m, "flow")
+		proxy.TLS = mapBool(m, "tls")
+		proxy.SNI = mapString(m, "servername")
+		if reality := mapMap(m, "reality-opts"); reality != nil {
+			proxy.RealityPublicKey = mapString(reality, "public-key")
+			proxy.RealityShortID = mapString(reality, "short-id")
+		}
+	case TypeTrojan:
+		proxy.Password = mapString(m, "password")
+		proxy.TLS = true
+		proxy.SNI = mapString(m, "sni")
+	case TypeHysteria2:
+		proxy.Password = mapString(m, "password")
+		proxy.TLS = true
+		proxy.SNI = mapString(m, "sni")
+		proxy.ObfsType = mapString(m, "obfs")
+		proxy.ObfsPassword = mapString(m, "obfs-password")
+	case TypeTUIC:
+		proxy.UUID = mapString(m, "uuid")
+		proxy.Password = mapString(m, "password")
+		proxy.TLS = true
+		proxy.SNI = mapString(m, "sni")
+		proxy.Congestion = mapString(m, "congestion-controller")
+	default:
+		return proxy, fmt.Errorf("type %s is not supported", proxy.Type)
+	}
+
+	proxy.Network = mapString(m, "network")
+	switch proxy.Network {
+	case "ws":
+		if opts := mapMap(m, "ws-opts"); opts != nil {
+			proxy.Path = mapString(opts, "path")
+			proxy.Host = mapString(mapMap(opts, "headers"), "Host")
+		}
+	case "grpc":
+		proxy.ServiceName = mapString(mapMap(m, "grpc-opts"), "grpc-service-name")
+	}
+
+	return proxy, nil
+}
+
+// renderClash 用于把节点渲染为可直接导入的 Clash 配置，包含全部节点的选择分组与兜底规则。
+// 节点使用 flow 风格输出，保证 emoji 等字符原样保留。
+// 参数含义：proxies 为节点列表。
+// 返回值：返回 Clash YAML 内容和渲染错误。
+func renderClash(proxies []Proxy) ([]byte, error) {
+	var buffer bytes.Buffer
+	names := make([]string, 0, len(proxies)+1)
+
+	buffer.WriteString("proxies:\n")
+	for _, proxy := range proxies {
+		line, err := proxyToClash(proxy).MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to render clash proxy %s: %w", proxy.Name, err)
+		}
+		buffer.WriteString("  - ")
+		buffer.Write(line)
+		buffer.WriteByte('\n')
+		names = append(names, proxy.Name)
+	}
+
+	group, err := object{}.
+		set("name", defaultGroupName).
+		set("type", "select").
+		set("proxies", append(names, "DIRECT")).
+		MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render clash proxy group: %w", err)
+	}
+	buffer.WriteString("proxy-groups:\n  - ")
+	buffer.Write(group)
+	buffer.WriteString("\nrules:\n  - MATCH," + defaultGroupName + "\n")
+
+	return buffer.Bytes(), nil
+}
+
+// proxyToClash 用于把统一节点模型映射为 Clash 节点字段。
+// 参数含义：proxy 为节点模型。
+// 返回值：返回按 Clash 习惯顺序排列的节点字段。
+func proxyToClash(proxy Proxy) object {
+	o := object{}.
+		set("name", proxy.Name).
+		set("type", proxy.Type).
+		set("server", proxy.Server).
+		set("port", proxy.Port)
+
+	sniKey := "sni"
+	switch proxy.Type {
+	case TypeShadowsocks:
+		o = o.set("cipher", proxy.Cipher).set("password", proxy.Password)
+	case TypeVMess:
+		cipher := proxy.Cipher
+		if cipher == "" {
+			cipher = "auto"
+		}
+		o = o.set("uuid", proxy.UUID).set("alterId", proxy.AlterID).set("cipher", cipher).set("tls", proxy.TLS)
+		sniKey = "servername"
+	case TypeVLESS:
+		o = o.set("uuid", proxy.UUID).set("flow", proxy.Flow).set("tls", proxy.TLS)
+		sniKey = "servername"
+	case TypeTrojan, TypeHysteria2:
+		o = o.set("password", proxy.Password)
+	case TypeTUIC:
+		o = o.set("uuid", proxy.UUID).set("password", proxy.Password).set("congestion-controller", proxy.Congestion)
+	}
+
+	if proxy.Type == TypeHysteria2 {
+		o = o.set("obfs", proxy.ObfsType).set("obfs-password", proxy.ObfsPassword)
+	}
+
+	o = o.set("udp", true).
+		set(sniKey, proxy.SNI).
+		set("alpn", proxy.ALPN).
+		set("skip-cert-verify", proxy.SkipCertVerify).
+		set("client-fingerprint", proxy.Fingerprint)
+
+	if proxy.RealityPublicKey != "" {
+		o = o.set("reality-opts", object{}.set("public-key", proxy.RealityPublicKey).set("short-id", proxy.RealityShortID))
+	}
+
+	switch proxy.Network {
+	case "ws":
+		o = o.set("network", proxy.Network).
+			set("ws-opts", object{}.set("path", proxy.Path).set("headers", object{}.set("Host", proxy.Host)))
+	case "grpc":
+		o = o.set("network", proxy.Network).
+			set("grpc-opts", object{}.set("grpc-service-name", proxy.ServiceName))
+	}
+
+	return o
+}
+
+// mapString 用于读取键值表中的字符串字段，兼容 YAML 把纯数字写成整数的情况。
+// 参数含义：m 为键值表，允许为 nil；key 为键名。
+// 返回值：返回字符串值，不存在时返回空串。
+func mapString(m map[string]any, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// mapInt 用于读取键值表中的整数字段，兼容字符串形式的数字。
+// 参数含义：m 为键值表；key 为键名。
+// 返回值：返回整数值，不存在或无法解析时返回 0。
+func mapInt(m map[string]any, key string) int {
+	switch v := m[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	default:
+		return 0
+	}
+}
+
+// mapBool 用于读取键值表中的布尔字段，兼容字符串形式的布尔值。
+// 参数含义：m 为键值表；key 为键名。
+// 返回值：返回布尔值，不存在或无法解析时返回 false。
+func mapBool(m map[string]any, key string) bool {
+	switch v := m[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	default:
+		return false
+	}
+}
+
+// mapStrings 用于读取键值表中的字符串列表字段。
+// 参数含义：m 为键值表；key 为键名。
+// 返回值：返回字符串列表，不存在时返回 nil。
+func mapStrings(m map[string]any, key string) []string {
+	items, ok := m[key].([]any)
+	if !ok {
+		return nil
+	}
+
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			values = append(values, s)
+		}
+	}
+
+	return values
+}
+
+// mapMap 用于读取键值表中的嵌套键值表字段。
+// 参数含义：m 为键值表；key 为键名。
+// 返回值：返回嵌套键值表，不存在时返回 nil。
+func mapMap(m map[string]any, key string) map[string]any {
+	nested, _ := m[key].(map[string]any)
+	return nested
+}
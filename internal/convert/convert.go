@@ -0,0 +1,220 @@
+// Package convert 提供 Clash、sing-box 与分享链接列表之间的订阅格式转换。
+//
+// 转换先把源订阅解析为统一的节点模型，再按目标格式渲染，仅支持 ss、vmess、vless、trojan、hysteria2 与 tuic 六类节点。
+// 源订阅中无法在模型中表示的节点不会被静默丢弃，而是作为 Unsupported 返回给调用方。
+package convert
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 支持转换的订阅格式，取值与路由配置中的 format 保持一致。
+const (
+	FormatClash   = "clash"
+	FormatSingBox = "singbox"
+	FormatURIList = "uri-list"
+)
+
+// 支持转换的节点类型，取值与 Clash 配置中的 type 保持一致。
+const (
+	TypeShadowsocks = "ss"
+	TypeVMess       = "vmess"
+	TypeVLESS       = "vless"
+	TypeTrojan      = "trojan"
+	TypeHysteria2   = "hysteria2"
+	TypeTUIC        = "tuic"
+)
+
+// 转换后生成的默认分组名称与直连出站名称。
+const (
+	defaultGroupName = "Proxy"
+	directTag        = "direct"
+)
+
+// Proxy 表示与订阅格式无关的统一节点模型，只保留六类节点在各格式之间可以互相表达的字段。
+// 字段含义：Type 为节点类型；Name 为节点名称；Server 与 Port 为服务器地址；
+// UUID 用于 vmess、vless、tuic；Password 用于 ss、trojan、hysteria2、tuic；Cipher 为 ss 加密方式或 vmess 加密方式；
+// AlterID 为 vmess 额外 ID；Flow 为 vless 流控；Network 为传输方式（tcp、ws、grpc），Path、Host 与 ServiceName 为对应传输参数；
+// TLS 为是否启用 TLS；SNI、ALPN、SkipCertVerify 与 Fingerprint 为 TLS 参数；RealityPublicKey 与 RealityShortID 为 REALITY 参数；
+// ObfsType 与 ObfsPassword 为 hysteria2 混淆参数；Congestion 为 tuic 拥塞控制算法。
+type Proxy struct {
+	Type   string
+	Name   string
+	Server string
+	Port   int
+
+	UUID     string
+	Password string
+	Cipher   string
+	AlterID  int
+	Flow     string
+
+	Network     string
+	Path        string
+	Host        string
+	ServiceName string
+
+	TLS              bool
+	SNI              string
+	ALPN             []string
+	SkipCertVerify   bool
+	Fingerprint      string
+	RealityPublicKey string
+	RealityShortID   string
+
+	ObfsType     string
+	ObfsPassword string
+	Congestion   string
+}
+
+// Unsupported 表示源订阅中无法转换的节点。
+// 字段含义：Name 为节点名称；Type 为源订阅中的节点类型；Reason 为无法转换的原因。
+type Unsupported struct {
+	Name   string
+	Type   string
+	Reason string
+}
+
+// String 用于输出便于日志记录的无法转换节点描述。
+// 参数含义：无。
+// 返回值：返回 “名称(类型): 原因” 形式的描述。
+func (u Unsupported) String() string {
+	return fmt.Sprintf("%s(%s): %s", u.Name, u.Type, u.Reason)
+}
+
+// Result 表示一次转换的结果。
+// 字段含义：Content 为目标格式的订阅内容；Unsupported 为源订阅中被跳过的节点。
+type Result struct {
+	Content     []byte
+	Unsupported []Unsupported
+}
+
+// IsSupportedFormat 用于判断订阅格式是否可以作为转换的源或目标。
+// 参数含义：format 为订阅格式。
+// 返回值：支持转换时返回 true。
+func IsSupportedFormat(format string) bool {
+	switch format {
+	case FormatClash, FormatSingBox, FormatURIList:
+		return true
+	default:
+		return false
+	}
+}
+
+// Convert 用于把源格式的订阅内容转换为目标格式。
+// 参数含义：content 为源订阅内容；from 为源格式；to 为目标格式。
+// 返回值：返回转换结果；格式不支持、源订阅无法解析或没有任何可转换节点时返回错误。
+func Convert(content []byte, from string, to string) (Result, error) {
+	proxies, unsupported, err := Parse(content, from)
+	if err != nil {
+		return Result{}, err
+	}
+	if len(proxies) == 0 {
+		return Result{Unsupported: unsupported}, errors.New("no convertible proxies found in subscription")
+	}
+
+	rendered, err := Render(proxies, to)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Content: rendered, Unsupported: unsupported}, nil
+}
+
+// Parse 用于按源格式把订阅内容解析为统一节点模型。
+// 参数含义：content 为订阅内容；format 为源格式。
+// 返回值：返回可转换节点、无法转换的节点和解析错误。
+func Parse(content []byte, format string) ([]Proxy, []Unsupported, error) {
+	switch format {
+	case FormatClash:
+		return parseClash(content)
+	case FormatSingBox:
+		return parseSingBox(content)
+	case FormatURIList:
+		return parseURIList(content)
+	default:
+		return nil, nil, fmt.Errorf("unsupported source format: %s", format)
+	}
+}
+
+// Render 用于把统一节点模型渲染为目标格式的完整订阅。
+// 渲染结果包含一个引用全部节点的选择分组，保证生成的配置可以直接导入客户端。
+// 参数含义：proxies 为节点列表；format 为目标格式。
+// 返回值：返回订阅内容和渲染错误。
+func Render(proxies []Proxy, format string) ([]byte, error) {
+	switch format {
+	case FormatClash:
+		return renderClash(proxies)
+	case FormatSingBox:
+		return renderSingBox(proxies)
+	case FormatURIList:
+		return renderURIList(proxies)
+	default:
+		return nil, fmt.Errorf("unsupported target format: %s", format)
+	}
+}
+
+// supportedNetwork 用于判断传输方式是否可以在各格式之间互相表达。
+// 参数含义：network 为传输方式，空串表示 tcp。
+// 返回值：支持时返回 true。
+func supportedNetwork(network string) bool {
+	switch network {
+	case "", "tcp", "ws", "grpc":
+		return true
+	default:
+		return false
+	}
+}
+
+// validate 用于校验节点是否具备目标格式渲染所需的最少字段。
+// 参数含义：无。
+// 返回值：字段缺失或传输方式不支持时返回错误。
+func (p Proxy) validate() error {
+	if p.Server == "" || p.Port <= 0 || p.Port > 65535 {
+		return errors.New("server or port is missing")
+	}
+
+	if !supportedNetwork(p.Network) {
+		return fmt.Errorf("network %s is not supported", p.Network)
+	}
+
+	switch p.Type {
+	case TypeShadowsocks:
+		if p.Cipher == "" || p.Password == "" {
+			return errors.New("cipher or password is missing")
+		}
+	case TypeVMess, TypeVLESS:
+		if p.UUID == "" {
+			return errors.New("uuid is missing")
+		}
+	case TypeTrojan, TypeHysteria2:
+		if p.Password == "" {
+			return errors.New("password is missing")
+		}
+	case TypeTUIC:
+		if p.UUID == "" || p.Password == "" {
+			return errors.New("uuid or password is missing")
+		}
+	default:
+		return fmt.Errorf("type %s is not supported", p.Type)
+	}
+
+	return nil
+}
+
+// collect 用于把解析出的节点按校验结果归入可转换或无法转换列表。
+// 参数含义：proxies 与 unsupported 为累积结果；proxy 为待归类节点；sourceType 为源订阅中的类型名。
+// 返回值：返回更新后的两个列表。
+func collect(proxies []Proxy, unsupported []Unsupported, proxy Proxy, sourceType string) ([]Proxy, []Unsupported) {
+	// tcp 是各格式的默认传输方式，统一归一为空值，避免同一节点因写法不同而被视为不一致。
+	if proxy.Network == "tcp" {
+		proxy.Network = ""
+	}
+
+	if err := proxy.validate(); err != nil {
+		return proxies, append(unsupported, Unsupported{Name: proxy.Name, Type: sourceType, Reason: err.Error()})
+	}
+
+	return append(proxies, proxy), unsupported
+}
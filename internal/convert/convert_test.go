@@ -0,0 +1,266 @@
+package convert
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testClashConfig = `proxies:
+  - name: "🇭🇰 SS"
+    type: ss
+    server: hk.example.com
+    port: 8388
+    cipher: aes-128-gcm
+    password: pass
+  - name: VMess WS
+    type: vmess
+    server: vm.example.com
+    port: 443
+    uuid: 0b4b8a5e-6f3c-4a43-9d76-3b0c5f8e0a11
+    alterId: 0
+    cipher: auto
+    tls: true
+    servername: vm.example.com
+    network: ws
+    ws-opts:
+      path: /ws
+      headers:
+        Host: cdn.example.com
+  - name: VLESS Reality
+    type: vless
+    server: vl.example.com
+    port: 443
+    uuid: 9d1f0c2a-1b7e-4a3c-8f6d-2e5a4b3c2d1e
+    flow: xtls-rprx-vision
+    tls: true
+    servername: www.microsoft.com
+    client-fingerprint: chrome
+    reality-opts:
+      public-key: pubkey
+      short-id: abcd
+  - name: Trojan gRPC
+    type: trojan
+    server: tj.example.com
+    port: 443
+    password: trojan-pass
+    sni: tj.example.com
+    skip-cert-verify: true
+    network: grpc
+    grpc-opts:
+      grpc-service-name: svc
+  - name: Hy2
+    type: hysteria2
+    server: hy.example.com
+    port: 8443
+    password: "user:pass"
+    sni: hy.example.com
+    obfs: salamander
+    obfs-password: obfs-pass
+    alpn: [h3]
+  - name: TUIC
+    type: tuic
+    server: tu.example.com
+    port: 443
+    uuid: 3c1b2a4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d
+    password: tuic-pass
+    sni: tu.example.com
+    congestion-controller: bbr
+  - name: WG
+    type: wireguard
+    server: wg.example.com
+    port: 51820
+  - name: SS Plugin
+    type: ss
+    server: p.example.com
+    port: 443
+    cipher: aes-128-gcm
+    password: pass
+    plugin: obfs
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies: ["🇭🇰 SS"]
+`
+
+// TestConvert_RoundTripsAcrossFormats 用于验证六类节点经 Clash、sing-box 与分享链接列表互相转换后，节点模型保持一致。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestConvert_RoundTripsAcrossFormats(t *testing.T) {
+	t.Parallel()
+
+	source, unsupported, err := Parse([]byte(testClashConfig), FormatClash)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(source) != 6 {
+		t.Fatalf("expected 6 convertible proxies, got %d", len(source))
+	}
+	if len(unsupported) != 2 {
+		t.Fatalf("expected 2 unsupported proxies, got %v", unsupported)
+	}
+
+	for _, format := range []string{FormatClash, FormatSingBox, FormatURIList} {
+		rendered, err := Render(source, format)
+		if err != nil {
+			t.Fatalf("%s: Render returned error: %v", format, err)
+		}
+
+		parsed, skipped, err := Parse(rendered, format)
+		if err != nil {
+			t.Fatalf("%s: Parse returned error: %v", format, err)
+		}
+		if len(skipped) != 0 {
+			t.Fatalf("%s: expected no unsupported proxies after round trip, got %v", format, skipped)
+		}
+
+		for i := range source {
+			want := source[i]
+			got := parsed[i]
+			// vmess 未写 cipher 时按 auto 渲染，这里按渲染后的语义比较。
+			if want.Type == TypeVMess && want.Cipher == "" {
+				want.Cipher = "auto"
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s: proxy %d mismatch\n got: %+v\nwant: %+v", format, i, got, want)
+			}
+		}
+	}
+}
+
+// TestConvert_ReportsUnsupportedProxies 用于验证不支持的节点类型与插件会连同原因一起返回，而不是静默丢弃。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestConvert_ReportsUnsupportedProxies(t *testing.T) {
+	t.Parallel()
+
+	result, err := Convert([]byte(testClashConfig), FormatClash, FormatSingBox)
+	if err != nil {
+		t.Fatalf("Convert returned error: %v", err)
+	}
+
+	got := make([]string, 0, len(result.Unsupported))
+	for _, item := range result.Unsupported {
+		got = append(got, item.String())
+	}
+	want := []string{
+		"WG(wireguard): type wireguard is not supported",
+		"SS Plugin(ss): plugin obfs is not supported",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected unsupported list: %v", got)
+	}
+
+	if _, err := Convert([]byte("proxies:\n  - {name: WG, type: wireguard, server: a, port: 1}\n"), FormatClash, FormatURIList); err == nil {
+		t.Fatalf("expected error when no proxy can be converted")
+	}
+}
+
+// TestRender_ProducesImportableConfigs 用于验证生成的 Clash 与 sing-box 配置可被解析，且包含引用全部节点的选择分组。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestRender_ProducesImportableConfigs(t *testing.T) {
+	t.Parallel()
+
+	proxies := []Proxy{{Type: TypeTrojan, Name: "🇯🇵 <JP>", Server: "jp.example.com", Port: 443, Password: "p", TLS: true}}
+
+	clash, err := Render(proxies, FormatClash)
+	if err != nil {
+		t.Fatalf("Render clash returned error: %v", err)
+	}
+	if !strings.Contains(string(clash), `"name":"🇯🇵 <JP>"`) {
+		t.Fatalf("expected emoji and html characters to be kept verbatim, got: %s", clash)
+	}
+
+	var clashDoc struct {
+		ProxyGroups []struct {
+			Name    string   `yaml:"name"`
+			Proxies []string `yaml:"proxies"`
+		} `yaml:"proxy-groups"`
+		Rules []string `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(clash, &clashDoc); err != nil {
+		t.Fatalf("expected valid yaml, got %v", err)
+	}
+	if len(clashDoc.ProxyGroups) != 1 || !reflect.DeepEqual(clashDoc.ProxyGroups[0].Proxies, []string{"🇯🇵 <JP>", "DIRECT"}) {
+		t.Fatalf("unexpected proxy groups: %+v", clashDoc.ProxyGroups)
+	}
+	if !reflect.DeepEqual(clashDoc.Rules, []string{"MATCH,Proxy"}) {
+		t.Fatalf("unexpected rules: %v", clashDoc.Rules)
+	}
+
+	singBox, err := Render(proxies, FormatSingBox)
+	if err != nil {
+		t.Fatalf("Render sing-box returned error: %v", err)
+	}
+
+	var singBoxDoc struct {
+		Outbounds []struct {
+			Type      string   `json:"type"`
+			Tag       string   `json:"tag"`
+			Outbounds []string `json:"outbounds"`
+		} `json:"outbounds"`
+		Route struct {
+			Final string `json:"final"`
+		} `json:"route"`
+	}
+	if err := json.Unmarshal(singBox, &singBoxDoc); err != nil {
+		t.Fatalf("expected valid json, got %v", err)
+	}
+	if len(singBoxDoc.Outbounds) != 3 || singBoxDoc.Outbounds[0].Type != "selector" || singBoxDoc.Outbounds[2].Type != "direct" {
+		t.Fatalf("unexpected outbounds: %+v", singBoxDoc.Outbounds)
+	}
+	if singBoxDoc.Route.Final != defaultGroupName {
+		t.Fatalf("expected route.final %s, got %s", defaultGroupName, singBoxDoc.Route.Final)
+	}
+}
+
+// TestParseURIList_AcceptsLegacyShadowsocksAndPlainList 用于验证旧版整体 base64 的 ss 链接与明文列表都能解析，未知协议会被上报。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestParseURIList_AcceptsLegacyShadowsocksAndPlainList(t *testing.T) {
+	t.Parallel()
+
+	legacy := "ss://" + base64.StdEncoding.EncodeToString([]byte("aes-256-gcm:pa:ss@1.2.3.4:8388")) + "#Legacy"
+	content := legacy + "\nwireguard://key@5.6.7.8:51820#WG\n"
+
+	proxies, unsupported, err := parseURIList([]byte(content))
+	if err != nil {
+		t.Fatalf("parseURIList returned error: %v", err)
+	}
+
+	want := Proxy{Type: TypeShadowsocks, Name: "Legacy", Server: "1.2.3.4", Port: 8388, Cipher: "aes-256-gcm", Password: "pa:ss"}
+	if len(proxies) != 1 || !reflect.DeepEqual(proxies[0], want) {
+		t.Fatalf("unexpected proxies: %+v", proxies)
+	}
+	if len(unsupported) != 1 || unsupported[0].Type != "wireguard" {
+		t.Fatalf("expected wireguard to be reported, got %v", unsupported)
+	}
+}
+
+// TestDecodeURIList_AcceptsWrappedAndUnpaddedBase64 用于验证折行与无填充的 base64 内容都能正确解码。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestDecodeURIList_AcceptsWrappedAndUnpaddedBase64(t *testing.T) {
+	t.Parallel()
+
+	list := "vless://uuid@example.com:443?security=tls#HK\n"
+	raw := base64.RawURLEncoding.EncodeToString([]byte(list))
+	wrapped := raw[:20] + "\n" + raw[20:] + "\n"
+
+	decoded, encoded, err := DecodeURIList([]byte(wrapped))
+	if err != nil {
+		t.Fatalf("DecodeURIList returned error: %v", err)
+	}
+	if !encoded || string(decoded) != list {
+		t.Fatalf("unexpected decode result: encoded=%v content=%q", encoded, decoded)
+	}
+
+	if _, _, err := DecodeURIList([]byte("proxy-groups: []")); err == nil {
+		t.Fatalf("expected error for non uri-list content")
+	}
+}
@@ -0,0 +1,91 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// field 表示有序对象中的一个键值对。
+type field struct {
+	key   string
+	value any
+}
+
+// object 表示按写入顺序输出键的 JSON 对象，用于让生成的配置保持 type、tag 等关键字段在前的可读顺序。
+type object []field
+
+// set 用于追加一个键值对，值为空时跳过，避免输出大量无意义的默认值。
+// 参数含义：key 为键名；value 为值。
+// 返回值：返回追加后的对象。
+func (o object) set(key string, value any) object {
+	if isEmptyValue(value) {
+		return o
+	}
+
+	return append(o, field{key: key, value: value})
+}
+
+// MarshalJSON 用于按写入顺序编码对象，且不转义 HTML 字符。
+// 参数含义：无。
+// 返回值：返回紧凑格式的 JSON 和编码错误。
+func (o object) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, item := range o {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		key, err := marshalJSON(item.key)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+
+		value, err := marshalJSON(item.value)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
+
+// marshalJSON 用于编码单个值，且不转义 HTML 字符。
+// 参数含义：value 为待编码的值。
+// 返回值：返回不带结尾换行的 JSON 和编码错误。
+func marshalJSON(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buffer.Bytes(), "\n"), nil
+}
+
+// isEmptyValue 用于判断值是否为需要省略的零值。
+// 参数含义：value 为待判断的值。
+// 返回值：零值或空集合时返回 true。
+func isEmptyValue(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case int:
+		return v == 0
+	case bool:
+		return !v
+	case []string:
+		return len(v) == 0
+	case object:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
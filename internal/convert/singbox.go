@@ -0,0 +1,212 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// singBoxShadowsocks 为 sing-box 中 Shadowsocks 出站的类型名，其余五类节点与模型类型同名。
+const singBoxShadowsocks = "shadowsocks"
+
+// singBoxNonProxyTypes 为 sing-box 中不代表节点的出站类型，解析时直接跳过而不是作为无法转换的节点上报。
+var singBoxNonProxyTypes = map[string]bool{
+	"direct":   true,
+	"block":    true,
+	"dns":      true,
+	"selector": true,
+	"urltest":  true,
+}
+
+// parseSingBox 用于解析 sing-box 配置中的 outbounds 列表。
+// 参数含义：content 为 sing-box JSON 内容。
+// 返回值：返回可转换节点、无法转换的节点和解析错误。
+func parseSingBox(content []byte) ([]Proxy, []Unsupported, error) {
+	var doc struct {
+		Outbounds []map[string]any `json:"outbounds"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to read sing-box config: %w", err)
+	}
+	if len(doc.Outbounds) == 0 {
+		return nil, nil, errors.New("no outbounds found in sing-box config")
+	}
+
+	var (
+		proxies     []Proxy
+		unsupported []Unsupported
+	)
+	for _, item := range doc.Outbounds {
+		sourceType := mapString(item, "type")
+		if singBoxNonProxyTypes[sourceType] {
+			continue
+		}
+
+		proxy, err := singBoxToProxy(item)
+		if err != nil {
+			unsupported = append(unsupported, Unsupported{Name: proxy.Name, Type: sourceType, Reason: err.Error()})
+			continue
+		}
+		proxies, unsupported = collect(proxies, unsupported, proxy, sourceType)
+	}
+
+	return proxies, unsupported, nil
+}
+
+// singBoxToProxy 用于把单个 sing-box 出站映射为统一节点模型。
+// 参数含义：m 为出站的键值表。
+// 返回值：返回节点模型；存在模型无法表达的字段时返回错误，节点名称仍会填充以便上报。
+func singBoxToProxy(m map[string]any) (Proxy, error) {
+	proxy := Proxy{
+		Type:   mapString(m, "type"),
+		Name:   mapString(m, "tag"),
+		Server: mapString(m, "server"),
+		Port:   mapInt(m, "server_port"),
+	}
+
+	switch proxy.Type {
+	case singBoxShadowsocks:
+		proxy.Type = TypeShadowsocks
+		if plugin := mapString(m, "plugin"); plugin != "" {
+			return proxy, fmt.Errorf("plugin %s is not supported", plugin)
+		}
+		proxy.Cipher = mapString(m, "method")
+		proxy.Password = mapString(m, "password")
+	case TypeVMess:
+		proxy.UUID = mapString(m, "uuid")
+		proxy.AlterID = mapInt(m, "alter_id")
+		proxy.Cipher = mapString(m, "security")
+	case TypeVLESS:
+		proxy.UUID = mapString(m, "uuid")
+		proxy.Flow = mapString(m, "flow")
+	case TypeTrojan:
+		proxy.Password = mapString(m, "password")
+	case TypeHysteria2:
+		proxy.Password = mapString(m, "password")
+		if obfs := mapMap(m, "obfs"); obfs != nil {
+			proxy.ObfsType = mapString(obfs, "type")
+			proxy.ObfsPassword = mapString(obfs, "password")
+		}
+	case TypeTUIC:
+		proxy.UUID = mapString(m, "uuid")
+		proxy.Password = mapString(m, "password")
+		proxy.Congestion = mapString(m, "congestion_control")
+	default:
+		return proxy, fmt.Errorf("type %s is not supported", proxy.Type)
+	}
+
+	if tls := mapMap(m, "tls"); tls != nil {
+		proxy.TLS = mapBool(tls, "enabled")
+		proxy.SNI = mapString(tls, "server_name")
+		proxy.SkipCertVerify = mapBool(tls, "insecure")
+		proxy.ALPN = mapStrings(tls, "alpn")
+		if utls := mapMap(tls, "utls"); mapBool(utls, "enabled") {
+			proxy.Fingerprint = mapString(utls, "fingerprint")
+		}
+		if reality := mapMap(tls, "reality"); mapBool(reality, "enabled") {
+			proxy.RealityPublicKey = mapString(reality, "public_key")
+			proxy.RealityShortID = mapString(reality, "short_id")
+		}
+	}
+
+	if transport := mapMap(m, "transport"); transport != nil {
+		proxy.Network = mapString(transport, "type")
+		proxy.Path = mapString(transport, "path")
+		proxy.Host = mapString(mapMap(transport, "headers"), "Host")
+		proxy.ServiceName = mapString(transport, "service_name")
+	}
+
+	return proxy, nil
+}
+
+// renderSingBox 用于把节点渲染为可直接导入的 sing-box 配置，包含全部节点的 selector 与直连出站。
+// 参数含义：proxies 为节点列表。
+// 返回值：返回缩进两个空格的 sing-box JSON 内容和渲染错误。
+func renderSingBox(proxies []Proxy) ([]byte, error) {
+	tags := make([]string, 0, len(proxies)+1)
+	outbounds := make([]object, 0, len(proxies)+2)
+	outbounds = append(outbounds, nil)
+	for _, proxy := range proxies {
+		outbounds = append(outbounds, proxyToSingBox(proxy))
+		tags = append(tags, proxy.Name)
+	}
+
+	// 选择器放在第一个，未配置路由规则的客户端也会默认走选择器。
+	outbounds[0] = object{}.set("type", "selector").set("tag", defaultGroupName).set("outbounds", append(tags, directTag))
+	outbounds = append(outbounds, object{}.set("type", "direct").set("tag", directTag))
+
+	compact, err := object{}.
+		set("outbounds", outbounds).
+		set("route", object{}.set("final", defaultGroupName)).
+		MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render sing-box config: %w", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := json.Indent(&buffer, compact, "", "  "); err != nil {
+		return nil, fmt.Errorf("failed to render sing-box config: %w", err)
+	}
+	buffer.WriteByte('\n')
+
+	return buffer.Bytes(), nil
+}
+
+// proxyToSingBox 用于把统一节点模型映射为 sing-box 出站字段。
+// 参数含义：proxy 为节点模型。
+// 返回值：返回按 sing-box 习惯顺序排列的出站字段。
+func proxyToSingBox(proxy Proxy) object {
+	outboundType := proxy.Type
+	if outboundType == TypeShadowsocks {
+		outboundType = singBoxShadowsocks
+	}
+
+	o := object{}.
+		set("type", outboundType).
+		set("tag", proxy.Name).
+		set("server", proxy.Server).
+		set("server_port", proxy.Port)
+
+	switch proxy.Type {
+	case TypeShadowsocks:
+		o = o.set("method", proxy.Cipher).set("password", proxy.Password)
+	case TypeVMess:
+		o = o.set("uuid", proxy.UUID).set("alter_id", proxy.AlterID).set("security", proxy.Cipher)
+	case TypeVLESS:
+		o = o.set("uuid", proxy.UUID).set("flow", proxy.Flow)
+	case TypeTrojan:
+		o = o.set("password", proxy.Password)
+	case TypeHysteria2:
+		o = o.set("password", proxy.Password)
+		if proxy.ObfsType != "" {
+			o = o.set("obfs", object{}.set("type", proxy.ObfsType).set("password", proxy.ObfsPassword))
+		}
+	case TypeTUIC:
+		o = o.set("uuid", proxy.UUID).set("password", proxy.Password).set("congestion_control", proxy.Congestion)
+	}
+
+	if proxy.TLS {
+		tls := object{}.
+			set("enabled", true).
+			set("server_name", proxy.SNI).
+			set("insecure", proxy.SkipCertVerify).
+			set("alpn", proxy.ALPN)
+		if proxy.Fingerprint != "" {
+			tls = tls.set("utls", object{}.set("enabled", true).set("fingerprint", proxy.Fingerprint))
+		}
+		if proxy.RealityPublicKey != "" {
+			tls = tls.set("reality", object{}.set("enabled", true).set("public_key", proxy.RealityPublicKey).set("short_id", proxy.RealityShortID))
+		}
+		o = o.set("tls", tls)
+	}
+
+	switch proxy.Network {
+	case "ws":
+		o = o.set("transport", object{}.set("type", "ws").set("path", proxy.Path).set("headers", object{}.set("Host", proxy.Host)))
+	case "grpc":
+		o = o.set("transport", object{}.set("type", "grpc").set("service_name", proxy.ServiceName))
+	}
+
+	return o
+}
@@ -0,0 +1,418 @@
+package convert
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// base64Encodings 为解码分享链接时依次尝试的编码，兼容带或不带填充、标准或 URL 安全字符集。
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.RawStdEncoding,
+	base64.URLEncoding,
+	base64.RawURLEncoding,
+}
+
+// DecodeURIList 用于解析分享链接列表，自动识别明文与 base64 编码两种存储方式。
+// 参数含义：content 为原始订阅内容。
+// 返回值：返回明文列表、原内容是否经过 base64 编码，以及解码错误。
+func DecodeURIList(content []byte) ([]byte, bool, error) {
+	trimmed := bytes.TrimSpace(content)
+
+	// 明文列表每行都是 scheme://，base64 字符集中不会出现冒号，据此区分两种存储方式。
+	if bytes.Contains(trimmed, []byte("://")) {
+		return content, false, nil
+	}
+
+	// 部分生成器会按 76 列折行，解码前先去掉所有空白。
+	decoded, err := decodeBase64(string(bytes.Join(bytes.Fields(trimmed), nil)))
+	if err != nil {
+		return nil, false, errors.New("failed to decode uri list: content is neither plain uri list nor base64")
+	}
+
+	return decoded, true, nil
+}
+
+// decodeBase64 用于按常见的几种 base64 变体依次尝试解码。
+// 参数含义：value 为待解码字符串。
+// 返回值：返回解码结果；全部变体都失败时返回错误。
+func decodeBase64(value string) ([]byte, error) {
+	for _, encoding := range base64Encodings {
+		if decoded, err := encoding.DecodeString(value); err == nil {
+			return decoded, nil
+		}
+	}
+
+	return nil, errors.New("invalid base64")
+}
+
+// parseURIList 用于解析分享链接列表中的每一行。
+// 参数含义：content 为明文或 base64 编码的分享链接列表。
+// 返回值：返回可转换节点、无法转换的节点和解析错误。
+func parseURIList(content []byte) ([]Proxy, []Unsupported, error) {
+	plain, _, err := DecodeURIList(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		proxies     []Proxy
+		unsupported []Unsupported
+	)
+	for _, line := range strings.Split(string(plain), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		proxy, err := parseURI(line)
+		if err != nil {
+			unsupported = append(unsupported, Unsupported{Name: proxy.Name, Type: proxy.Type, Reason: err.Error()})
+			continue
+		}
+		proxies, unsupported = collect(proxies, unsupported, proxy, proxy.Type)
+	}
+
+	if len(proxies) == 0 && len(unsupported) == 0 {
+		return nil, nil, errors.New("no proxies found in uri list")
+	}
+
+	return proxies, unsupported, nil
+}
+
+// parseURI 用于把单条分享链接解析为统一节点模型。
+// 参数含义：line 为单条分享链接。
+// 返回值：返回节点模型；协议不支持或格式非法时返回错误，节点类型与名称会尽量填充以便上报。
+func parseURI(line string) (Proxy, error) {
+	scheme, _, ok := strings.Cut(line, "://")
+	if !ok {
+		return Proxy{Name: line}, errors.New("uri scheme is missing")
+	}
+
+	switch scheme {
+	case TypeShadowsocks:
+		return parseShadowsocksURI(line)
+	case TypeVMess:
+		return parseVMessURI(line)
+	case TypeVLESS, TypeTrojan, TypeHysteria2, "hy2", TypeTUIC:
+		return parseStandardURI(line)
+	default:
+		return Proxy{Type: scheme}, fmt.Errorf("type %s is not supported", scheme)
+	}
+}
+
+// parseShadowsocksURI 用于解析 SIP002 与旧版整体 base64 两种 ss 分享链接。
+// 参数含义：line 为 ss:// 开头的分享链接。
+// 返回值：返回节点模型和解析错误。
+func parseShadowsocksURI(line string) (Proxy, error) {
+	body, fragment, _ := strings.Cut(strings.TrimPrefix(line, "ss://"), "#")
+	name, _ := url.PathUnescape(fragment)
+	proxy := Proxy{Type: TypeShadowsocks, Name: name}
+
+	// 旧版格式把 method:password@host:port 整体 base64 编码，先还原为 SIP002 的结构再统一解析。
+	if !strings.Contains(body, "@") {
+		encoded, query, _ := strings.Cut(body, "?")
+		decoded, err := decodeBase64(strings.TrimSuffix(encoded, "/"))
+		if err != nil {
+			return proxy, errors.New("ss uri is invalid")
+		}
+		body = string(decoded)
+		if query != "" {
+			body += "?" + query
+		}
+	}
+
+	userInfo, hostPart, _ := strings.Cut(body, "@")
+	u, err := url.Parse("ss://" + hostPart)
+	if err != nil {
+		return proxy, errors.New("ss uri is invalid")
+	}
+	if plugin := u.Query().Get("plugin"); plugin != "" {
+		return proxy, fmt.Errorf("plugin %s is not supported", plugin)
+	}
+
+	// SIP002 的 userinfo 可以是 base64(method:password)，2022 系列加密也允许直接百分号编码。
+	credential, err := url.PathUnescape(userInfo)
+	if err != nil {
+		return proxy, errors.New("ss uri is invalid")
+	}
+	if !strings.Contains(credential, ":") {
+		decoded, err := decodeBase64(credential)
+		if err != nil {
+			return proxy, errors.New("ss uri is invalid")
+		}
+		credential = string(decoded)
+	}
+
+	proxy.Cipher, proxy.Password, _ = strings.Cut(credential, ":")
+	proxy.Server = u.Hostname()
+	proxy.Port, _ = strconv.Atoi(u.Port())
+
+	return proxy, nil
+}
+
+// parseVMessURI 用于解析 V2RayN 风格的 vmess 分享链接，其主体为 base64 编码的 JSON。
+// 参数含义：line 为 vmess:// 开头的分享链接。
+// 返回值：返回节点模型和解析错误。
+func parseVMessURI(line string) (Proxy, error) {
+	proxy := Proxy{Type: TypeVMess}
+
+	decoded, err := decodeBase64(strings.TrimPrefix(line, "vmess://"))
+	if err != nil {
+		return proxy, errors.New("vmess uri is invalid")
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(decoded, &m); err != nil {
+		return proxy, errors.New("vmess uri is invalid")
+	}
+
+	proxy.Name = mapString(m, "ps")
+	proxy.Server = mapString(m, "add")
+	proxy.Port = mapInt(m, "port")
+	proxy.UUID = mapString(m, "id")
+	proxy.AlterID = mapInt(m, "aid")
+	proxy.Cipher = mapString(m, "scy")
+	proxy.TLS = mapString(m, "tls") == "tls"
+	proxy.SNI = mapString(m, "sni")
+	proxy.Fingerprint = mapString(m, "fp")
+	proxy.ALPN = splitList(mapString(m, "alpn"))
+	proxy.Network = mapString(m, "net")
+	proxy.Host = mapString(m, "host")
+
+	// V2RayN 在 grpc 传输下复用 path 字段存放 serviceName。
+	if proxy.Network == "grpc" {
+		proxy.ServiceName = mapString(m, "path")
+	} else {
+		proxy.Path = mapString(m, "path")
+	}
+
+	return proxy, nil
+}
+
+// parseStandardURI 用于解析 vless、trojan、hysteria2 与 tuic 这类标准 URL 结构的分享链接。
+// 参数含义：line 为分享链接。
+// 返回值：返回节点模型和解析错误。
+func parseStandardURI(line string) (Proxy, error) {
+	u, err := url.Parse(line)
+	if err != nil {
+		return Proxy{}, errors.New("uri is invalid")
+	}
+
+	proxy := Proxy{
+		Type:   u.Scheme,
+		Name:   u.Fragment,
+		Server: u.Hostname(),
+	}
+	proxy.Port, _ = strconv.Atoi(u.Port())
+	if proxy.Type == "hy2" {
+		proxy.Type = TypeHysteria2
+	}
+
+	query := u.Query()
+	username := u.User.Username()
+	password, _ := u.User.Password()
+
+	switch proxy.Type {
+	case TypeVLESS:
+		proxy.UUID = username
+		proxy.Flow = query.Get("flow")
+		security := query.Get("security")
+		proxy.TLS = security == "tls" || security == "reality"
+		proxy.RealityPublicKey = query.Get("pbk")
+		proxy.RealityShortID = query.Get("sid")
+	case TypeTrojan:
+		proxy.Password = username
+		proxy.TLS = true
+	case TypeHysteria2:
+		// hysteria2 的认证串允许包含冒号，解析后会被拆到 userinfo 的两部分，这里重新拼回。
+		proxy.Password = u.User.String()
+		if decoded, err := url.PathUnescape(proxy.Password); err == nil {
+			proxy.Password = decoded
+		}
+		proxy.TLS = true
+		proxy.ObfsType = query.Get("obfs")
+		proxy.ObfsPassword = query.Get("obfs-password")
+	case TypeTUIC:
+		proxy.UUID = username
+		proxy.Password = password
+		proxy.TLS = true
+		proxy.Congestion = query.Get("congestion_control")
+	}
+
+	proxy.SNI = cmp.Or(query.Get("sni"), query.Get("peer"))
+	proxy.Fingerprint = query.Get("fp")
+	proxy.ALPN = splitList(query.Get("alpn"))
+	proxy.SkipCertVerify = isTruthy(query.Get("allowInsecure")) || isTruthy(query.Get("insecure")) || isTruthy(query.Get("allow_insecure"))
+
+	proxy.Network = query.Get("type")
+	proxy.Path = query.Get("path")
+	proxy.Host = query.Get("host")
+	proxy.ServiceName = query.Get("serviceName")
+
+	return proxy, nil
+}
+
+// renderURIList 用于把节点渲染为 base64 编码的分享链接列表。
+// 参数含义：proxies 为节点列表。
+// 返回值：返回标准 base64 编码的列表内容和渲染错误。
+func renderURIList(proxies []Proxy) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, proxy := range proxies {
+		line, err := proxyToURI(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render uri for proxy %s: %w", proxy.Name, err)
+		}
+		buffer.WriteString(line)
+		buffer.WriteByte('\n')
+	}
+
+	return []byte(base64.StdEncoding.EncodeToString(buffer.Bytes())), nil
+}
+
+// proxyToURI 用于把统一节点模型渲染为单条分享链接。
+// 参数含义：proxy 为节点模型。
+// 返回值：返回分享链接和渲染错误。
+func proxyToURI(proxy Proxy) (string, error) {
+	hostPort := net.JoinHostPort(proxy.Server, strconv.Itoa(proxy.Port))
+
+	switch proxy.Type {
+	case TypeShadowsocks:
+		userInfo := base64.RawURLEncoding.EncodeToString([]byte(proxy.Cipher + ":" + proxy.Password))
+		return "ss://" + userInfo + "@" + hostPort + "#" + (&url.URL{Fragment: proxy.Name}).EscapedFragment(), nil
+	case TypeVMess:
+		return renderVMessURI(proxy)
+	}
+
+	query := url.Values{}
+	u := url.URL{Scheme: proxy.Type, Host: hostPort, Fragment: proxy.Name}
+
+	switch proxy.Type {
+	case TypeVLESS:
+		u.User = url.User(proxy.UUID)
+		query.Set("encryption", "none")
+		switch {
+		case proxy.RealityPublicKey != "":
+			query.Set("security", "reality")
+			query.Set("pbk", proxy.RealityPublicKey)
+			setIfNotEmpty(query, "sid", proxy.RealityShortID)
+		case proxy.TLS:
+			query.Set("security", "tls")
+		default:
+			query.Set("security", "none")
+		}
+		setIfNotEmpty(query, "flow", proxy.Flow)
+	case TypeTrojan:
+		u.User = url.User(proxy.Password)
+		query.Set("security", "tls")
+	case TypeHysteria2:
+		u.User = url.User(proxy.Password)
+		setIfNotEmpty(query, "obfs", proxy.ObfsType)
+		setIfNotEmpty(query, "obfs-password", proxy.ObfsPassword)
+	case TypeTUIC:
+		u.User = url.UserPassword(proxy.UUID, proxy.Password)
+		setIfNotEmpty(query, "congestion_control", proxy.Congestion)
+	default:
+		return "", fmt.Errorf("type %s is not supported", proxy.Type)
+	}
+
+	setIfNotEmpty(query, "sni", proxy.SNI)
+	setIfNotEmpty(query, "fp", proxy.Fingerprint)
+	setIfNotEmpty(query, "alpn", strings.Join(proxy.ALPN, ","))
+	if proxy.SkipCertVerify {
+		query.Set("allowInsecure", "1")
+		query.Set("insecure", "1")
+	}
+
+	if proxy.Network != "" && proxy.Network != "tcp" {
+		query.Set("type", proxy.Network)
+		setIfNotEmpty(query, "path", proxy.Path)
+		setIfNotEmpty(query, "host", proxy.Host)
+		setIfNotEmpty(query, "serviceName", proxy.ServiceName)
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// renderVMessURI 用于按 V2RayN 约定渲染 vmess 分享链接。
+// 参数含义：proxy 为 vmess 节点模型。
+// 返回值：返回分享链接和编码错误。
+func renderVMessURI(proxy Proxy) (string, error) {
+	network := proxy.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	path := proxy.Path
+	if network == "grpc" {
+		path = proxy.ServiceName
+	}
+
+	tls := ""
+	if proxy.TLS {
+		tls = "tls"
+	}
+
+	// V2RayN 约定端口与 aid 均以字符串表示，空字段也保留以兼容较老的客户端。
+	payload, err := object{
+		{key: "v", value: "2"},
+		{key: "ps", value: proxy.Name},
+		{key: "add", value: proxy.Server},
+		{key: "port", value: strconv.Itoa(proxy.Port)},
+		{key: "id", value: proxy.UUID},
+		{key: "aid", value: strconv.Itoa(proxy.AlterID)},
+		{key: "scy", value: cmp.Or(proxy.Cipher, "auto")},
+		{key: "net", value: network},
+		{key: "type", value: "none"},
+		{key: "host", value: proxy.Host},
+		{key: "path", value: path},
+		{key: "tls", value: tls},
+		{key: "sni", value: proxy.SNI},
+		{key: "alpn", value: strings.Join(proxy.ALPN, ",")},
+		{key: "fp", value: proxy.Fingerprint},
+	}.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+
+	return "vmess://" + base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// setIfNotEmpty 用于仅在值非空时写入查询参数。
+// 参数含义：query 为查询参数；key 为参数名；value 为参数值。
+// 返回值：无。
+func setIfNotEmpty(query url.Values, key string, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// splitList 用于拆分逗号分隔的列表并去掉空项。
+// 参数含义：value 为逗号分隔的字符串。
+// 返回值：返回拆分后的列表，输入为空时返回 nil。
+func splitList(value string) []string {
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// isTruthy 用于识别分享链接中常见的真值写法。
+// 参数含义：value 为查询参数值。
+// 返回值：值为 1 或 true 时返回 true。
+func isTruthy(value string) bool {
+	return value == "1" || strings.EqualFold(value, "true")
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"sync"
@@ -15,6 +16,8 @@ import (
 	"golang.org/x/sync/singleflight"
 
 	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/internal/convert"
+	"github.com/djx30103/vpsub/internal/middleware"
 	"github.com/djx30103/vpsub/pkg/pathutil"
 	"github.com/djx30103/vpsub/pkg/provider"
 	"github.com/djx30103/vpsub/pkg/provider/base"
@@ -25,17 +28,20 @@ type SubscribeHandler struct {
 	sfGroup   *singleflight.Group
 	appConfig *config.AppConfig
 
-	cache     *gocache.Cache
-	fileCache map[string]cachedSubscriptionFile
-	fileMu    sync.RWMutex
+	cache       *gocache.Cache
+	remoteCache *gocache.Cache
+	fileCache   map[string]cachedSubscriptionFile
+	fileMu      sync.RWMutex
+
+	providerClients map[string]provider.Provider
+	providerMu      sync.Mutex
 }
 
-// cachedSubscriptionFile 用于缓存订阅文件内容及其文件元信息，避免每次请求都重复读取未变化的文件。
-// 字段含义：content 为文件内容；modTime 为文件最后修改时间；size 为文件大小。
+// cachedSubscriptionFile 用于缓存订阅文件内容及其版本标识，避免每次请求都重复读取或合并未变化的文件。
+// 字段含义：content 为文件内容；version 为由来源文件修改时间与大小组成的版本标识。
 type cachedSubscriptionFile struct {
 	content []byte
-	modTime time.Time
-	size    int64
+	version string
 }
 
 // NewSubscribeHandler 用于构造订阅处理器，并初始化去重与内存缓存组件。
@@ -46,11 +52,14 @@ func NewSubscribeHandler(
 	appConfig *config.AppConfig,
 ) *SubscribeHandler {
 	return &SubscribeHandler{
-		Handler:   handler,
-		appConfig: appConfig,
-		sfGroup:   new(singleflight.Group),
-		cache:     gocache.New(gocache.NoExpiration, time.Second),
-		fileCache: make(map[string]cachedSubscriptionFile),
+		Handler:     handler,
+		appConfig:   appConfig,
+		sfGroup:     new(singleflight.Group),
+		cache:       gocache.New(gocache.NoExpiration, time.Second),
+		remoteCache: gocache.New(gocache.NoExpiration, time.Minute),
+		fileCache:   make(map[string]cachedSubscriptionFile),
+
+		providerClients: make(map[string]provider.Provider),
 	}
 }
 
@@ -67,13 +76,24 @@ func (h *SubscribeHandler) writeSubscriptionResponse(c *gin.Context, conf config
 	// c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.PathEscape(path.Base(conf.File))))
 
 	if apiInfo != nil {
-		subInfo := fmt.Sprintf("upload=%d; download=%d; total=%d; expire=%d", apiInfo.Upload, apiInfo.Download, apiInfo.Total, apiInfo.Expire)
+		subInfo := fmt.Sprintf("upload=%d; download=%d; total=%d; expire=%d", apiInfo.Upload, apiInfo.Download, apiInfo.Total, subscriptionExpire(conf, apiInfo))
 		c.Header("Subscription-Userinfo", subInfo)
 	}
 
 	c.Data(http.StatusOK, "text/plain; charset=utf-8", fileContent)
 }
 
+// subscriptionExpire 用于按路由配置选择写入 Subscription-Userinfo 的 expire 时间戳。
+// 参数含义：conf 为当前路径配置；apiInfo 为流量信息。
+// 返回值：返回服务到期时间或下一次流量重置时间，未配置时默认使用流量重置时间。
+func subscriptionExpire(conf config.PathConfig, apiInfo *base.APIResponseInfo) int64 {
+	if conf.ExpireField == config.ExpireFieldServiceExpireAt {
+		return apiInfo.ServiceExpireAt
+	}
+
+	return apiInfo.ResetAt
+}
+
 // Get 用于处理订阅下载请求，并在路径命中后返回附带流量信息的订阅内容。
 // 参数含义：c 为 Gin 上下文。
 // 返回值：无。
@@ -85,21 +105,35 @@ func (h *SubscribeHandler) Get(c *gin.Context) {
 		return
 	}
 
-	conf, ok := h.appConfig.PathToConfig[requestPath]
+	conf, pathToken, ok := h.resolveRoute(requestPath)
 	if !ok {
 		h.logger.Debug("path not found", zap.String("path", requestPath))
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
+	// 令牌可能写在路径末段，请求日志改为记录路由路径。
+	c.Set(middleware.RoutePathKey, conf.Path)
 
-	// 配置了精确 UA 约束时，仅允许匹配的客户端访问，未匹配时直接按不存在处理。
-	if conf.AccessControl != nil && conf.AccessControl.UserAgent != "" && c.GetHeader("User-Agent") != conf.AccessControl.UserAgent {
-		h.logger.Debug("user agent not allowed", zap.String("path", requestPath), zap.String("user_agent", c.GetHeader("User-Agent")))
+	// 未满足访问约束时直接按不存在处理，避免暴露路由是否存在。
+	if !h.allowAccess(c, conf, pathToken) {
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
+	// 令牌写在路径中时，后续日志统一使用路由路径，避免令牌出现在日志里。
+	requestPath = conf.Path
+
+	// 同一路由可按客户端切换订阅文件与格式，后续读取、转换与注入都基于选中的变体。
+	conf = conf.SelectVariant(c.GetHeader("User-Agent"))
 
-	fileContent, err := h.readSubscriptionFile(conf)
+	// 转换目标只取决于请求与路由配置，先于读取订阅和查询服务商完成校验，非法的 ?target= 不会触发任何上游请求。
+	target, detected, err := resolveConvertTarget(c, conf)
+	if err != nil {
+		h.logger.Debug("invalid convert target", zap.String("path", requestPath), zap.Error(err))
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	fileContent, err := h.readSubscriptionSource(c, conf)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			c.AbortWithStatus(http.StatusNotFound)
@@ -110,13 +144,85 @@ func (h *SubscribeHandler) Get(c *gin.Context) {
 		return
 	}
 
+	// 流量信息在转换之前获取，模板渲染与之后的注入共用同一份数据。
 	var apiInfo *base.APIResponseInfo
 	if conf.ProviderType != provider.ProviderType_Passthrough {
 		apiInfo = h.getProviderInfo(c, conf)
 	}
 
+	if conf.Template {
+		rendered, renderErr := renderSubscriptionTemplate(c, conf, fileContent, apiInfo)
+		if renderErr != nil {
+			h.logger.WithContext(c).Error("failed to render subscription template", zap.String("path", requestPath), zap.Error(renderErr))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		fileContent = rendered
+	}
+
+	// 凭据替换在节点处理之前进行，规则按订阅文件中的原始节点名称匹配；源文件缓存仍按共享文件区分。
+	if len(conf.Credentials) > 0 {
+		substituted, substituteErr := substituteCredentials(fileContent, conf.Credentials)
+		if substituteErr != nil {
+			h.logger.WithContext(c).Error("failed to substitute subscription credentials", zap.String("path", requestPath), zap.Error(substituteErr))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		fileContent = substituted
+	}
+
+	// 节点处理在格式转换之前基于 Clash 结构进行，转换后的各格式拿到的是同一份处理结果。
+	if len(conf.Transform) > 0 {
+		transformed, transformErr := applyNodeTransforms(fileContent, conf.Transform)
+		if transformErr != nil {
+			h.logger.WithContext(c).Error("failed to transform subscription nodes", zap.String("path", requestPath), zap.Error(transformErr))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		fileContent = transformed
+	}
+
+	if target != "" && target != conf.Format {
+		result, convertErr := convert.Convert(fileContent, conf.Format, target)
+		// 无法转换的节点不会静默丢弃，即使整体转换失败也先记录，便于排查源订阅。
+		if len(result.Unsupported) > 0 {
+			h.logger.WithContext(c).Warn("unsupported proxies skipped during conversion",
+				zap.String("path", requestPath),
+				zap.String("target", target),
+				zap.Stringers("proxies", result.Unsupported),
+			)
+			setUnsupportedProxiesHeader(c, result.Unsupported)
+		}
+
+		switch {
+		case convertErr == nil:
+			// conf 为值拷贝，改写格式只影响本次请求后续的注入逻辑。
+			fileContent = result.Content
+			conf.Format = target
+		case detected:
+			// 自动识别只是尽力而为，转换失败时退回源格式，客户端至少还能拿到原始订阅。
+			h.logger.WithContext(c).Warn("failed to convert subscription, fallback to source format", zap.String("path", requestPath), zap.String("target", target), zap.Error(convertErr))
+		default:
+			h.logger.WithContext(c).Error("failed to convert subscription", zap.String("target", target), zap.Error(convertErr))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// 提供者与规则只对最终输出的 Clash 配置生效，路由引用按对外访问地址展开，受保护的路由附带本次请求的令牌。
+	if conf.Format == config.FormatClash && (len(conf.RuleProviders) > 0 || len(conf.ProxyProviders) > 0 || len(conf.Rules) > 0) {
+		routeURL := h.routeProviderURL(h.publicBaseURL(c), requestToken(c, pathToken))
+		injected, injectErr := injectClashProviders(fileContent, conf, routeURL)
+		if injectErr != nil {
+			h.logger.WithContext(c).Error("failed to inject clash providers", zap.String("path", requestPath), zap.Error(injectErr))
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		fileContent = injected
+	}
+
 	if conf.UsageDisplay.Enable && apiInfo != nil {
-		updated, appendErr := appendUsageGroups(fileContent, apiInfo, conf.UsageDisplay)
+		updated, appendErr := appendUsage(conf.Format, fileContent, apiInfo, conf.UsageDisplay)
 		if appendErr != nil {
 			h.logger.WithContext(c).Error("failed to append usage groups", zap.Error(appendErr))
 		} else {
@@ -124,5 +230,54 @@ func (h *SubscribeHandler) Get(c *gin.Context) {
 		}
 	}
 
+	// Surge 托管配置按首行地址自动更新，改写为本次请求地址，避免文件中的旧地址导致客户端拉取到错误路由。
+	if conf.Format == config.FormatSurge {
+		fileContent = rewriteManagedConfig(fileContent, h.subscriptionURL(c), conf.ProviderConfig.UpdateInterval)
+	}
+
 	h.writeSubscriptionResponse(c, conf, fileContent, apiInfo)
 }
+
+// subscriptionURL 用于还原客户端本次请求使用的完整订阅地址。
+// 参数含义：c 为 Gin 上下文。
+// 返回值：返回对外访问地址拼接原始路径与查询参数后的地址。
+func (h *SubscribeHandler) subscriptionURL(c *gin.Context) string {
+	u := url.URL{
+		Path:     c.Request.URL.Path,
+		RawPath:  c.Request.URL.RawPath,
+		RawQuery: c.Request.URL.RawQuery,
+	}
+
+	return h.publicBaseURL(c) + u.String()
+}
+
+// publicBaseURL 用于确定生成订阅地址时使用的协议、主机与路径前缀。
+// 配置了 server.public_url 时直接使用，不受请求头影响；未配置时按本次请求的协议与 Host 请求头拼接。
+// 参数含义：c 为 Gin 上下文。
+// 返回值：返回不含末尾斜杠的地址前缀。
+func (h *SubscribeHandler) publicBaseURL(c *gin.Context) string {
+	if h.appConfig.Server.PublicURL != "" {
+		return h.appConfig.Server.PublicURL
+	}
+
+	return h.requestScheme(c) + "://" + c.Request.Host
+}
+
+// requestScheme 用于判断客户端本次请求使用的协议。
+// 参数含义：c 为 Gin 上下文。
+// 返回值：返回 http 或 https。
+func (h *SubscribeHandler) requestScheme(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	// 部署在反向代理之后时以代理告知的原始协议为准，只采信来自 server.trusted_proxies 的请求头，避免客户端伪造。
+	if h.appConfig.TrustedProxies == nil || !h.appConfig.TrustedProxies.Match(c.RemoteIP()) {
+		return scheme
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	return scheme
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/pkg/pathutil"
+	"github.com/djx30103/vpsub/pkg/urlsign"
+)
+
+// resolveRoute 用于按请求路径查找路由配置。
+// 未直接命中时，把最后一段路径视为访问令牌，再查找配置了用户的路由，支持 /client-a/<token> 形式的订阅地址。
+// 参数含义：requestPath 为归一化后的请求路径。
+// 返回值：返回路由配置、路径中携带的令牌和是否命中。
+func (h *SubscribeHandler) resolveRoute(requestPath string) (config.PathConfig, string, bool) {
+	if conf, ok := h.appConfig.PathToConfig[requestPath]; ok {
+		return conf, "", true
+	}
+
+	parent, token := path.Split(requestPath)
+	conf, ok := h.appConfig.PathToConfig[pathutil.NormalizeRequestPath(parent)]
+	if !ok || len(conf.AccessUsers) == 0 || token == "" {
+		return config.PathConfig{}, "", false
+	}
+
+	return conf, token, true
+}
+
+// requestToken 用于读取请求携带的访问令牌，依次取路径中的令牌、查询参数 token 与 Authorization 请求头中的 Bearer 令牌。
+// 参数含义：c 为 Gin 上下文；pathToken 为路径中携带的令牌。
+// 返回值：返回访问令牌，未携带时返回空字符串。
+func requestToken(c *gin.Context, pathToken string) string {
+	if pathToken != "" {
+		return pathToken
+	}
+
+	if token := c.Query("token"); token != "" {
+		return token
+	}
+
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
+
+// allowAccess 用于校验请求是否满足路由的访问约束，未满足时调用方按路径不存在处理，不暴露路由是否存在。
+// 路由接受签名地址时，有效签名与用户令牌满足其一即可；只接受签名地址时必须携带有效签名。
+// 参数含义：c 为 Gin 上下文；conf 为路由配置；pathToken 为路径中携带的令牌。
+// 返回值：满足全部访问约束时返回 true。
+func (h *SubscribeHandler) allowAccess(c *gin.Context, conf config.PathConfig, pathToken string) bool {
+	// 客户端 IP 按 server.trusted_proxies 识别，未配置可信代理时不会采信转发请求头。
+	if conf.IPAccess != nil && !conf.IPAccess.Match(c.ClientIP()) {
+		h.logger.Debug("client ip not allowed", zap.String("path", conf.Path), zap.String("ip", c.ClientIP()))
+		return false
+	}
+
+	// 配置了 UA 规则时，仅允许满足规则的客户端访问。
+	if conf.UserAgent != nil && !conf.UserAgent.Match(c.GetHeader("User-Agent")) {
+		h.logger.Debug("user agent not allowed", zap.String("path", conf.Path), zap.String("user_agent", c.GetHeader("User-Agent")))
+		return false
+	}
+
+	signed := conf.AccessControl != nil && conf.AccessControl.Signed
+	// 携带签名时只按签名校验，过期或被篡改的地址直接拒绝，不再回退到用户令牌。
+	if signed && c.Query(urlsign.SignatureParam) != "" {
+		err := urlsign.Verify(h.appConfig.Signing.Secrets, conf.Path, c.Query(urlsign.ExpiresParam), c.Query(urlsign.SignatureParam), time.Now())
+		if err != nil {
+			h.logger.Debug("signature not accepted", zap.String("path", conf.Path), zap.Error(err))
+			return false
+		}
+		return true
+	}
+
+	if len(conf.AccessUsers) > 0 {
+		user, ok := config.MatchAccessUser(conf.AccessUsers, requestToken(c, pathToken))
+		if !ok {
+			h.logger.Debug("access token not accepted", zap.String("path", conf.Path))
+			return false
+		}
+		h.logger.Debug("access token accepted", zap.String("path", conf.Path), zap.String("user", user))
+		return true
+	}
+
+	if signed {
+		h.logger.Debug("signature required", zap.String("path", conf.Path))
+		return false
+	}
+
+	return true
+}
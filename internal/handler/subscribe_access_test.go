@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/internal/middleware"
+	"github.com/djx30103/vpsub/pkg/urlsign"
+)
+
+// TestGet_AcceptsAccessTokenFromQueryPathAndHeader 用于验证用户令牌可以通过查询参数、路径末段或 Authorization 请求头携带，缺失或错误时按不存在处理。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_AcceptsAccessTokenFromQueryPathAndHeader(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestSubscribeHandler(t)
+	conf.AccessUsers = []config.AccessUser{
+		{Name: "alice", TokenHash: config.HashToken("alice-token")},
+		{Name: "bob", TokenHash: config.HashToken("bob-token")},
+	}
+	handler.appConfig.PathToConfig = map[string]config.PathConfig{
+		"/test.yaml": conf,
+	}
+
+	cases := []struct {
+		name          string
+		target        string
+		authorization string
+		want          int
+	}{
+		{name: "query", target: "/test.yaml?token=bob-token", want: http.StatusOK},
+		{name: "path", target: "/test.yaml/alice-token", want: http.StatusOK},
+		{name: "header", target: "/test.yaml", authorization: "Bearer alice-token", want: http.StatusOK},
+		{name: "missing", target: "/test.yaml", want: http.StatusNotFound},
+		{name: "wrong", target: "/test.yaml?token=alice", want: http.StatusNotFound},
+		{name: "wrong path", target: "/test.yaml/carol-token", want: http.StatusNotFound},
+		{name: "basic scheme", target: "/test.yaml", authorization: "Basic alice-token", want: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		c.Request = req
+		c.Params = gin.Params{{Key: "path", Value: req.URL.Path}}
+
+		handler.Get(c)
+
+		if recorder.Code != tc.want {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.want, recorder.Code)
+		}
+		// 请求日志按路由路径记录，路径中的令牌不会写入日志。
+		if got := c.GetString(middleware.RoutePathKey); got != "/test.yaml" {
+			t.Fatalf("%s: expected route path for request log, got %q", tc.name, got)
+		}
+	}
+}
+
+// TestResolveRoute_IgnoresPathTokenForRoutesWithoutUsers 用于验证未配置用户的路由不会把多出的路径段当作令牌，仍按不存在处理。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestResolveRoute_IgnoresPathTokenForRoutesWithoutUsers(t *testing.T) {
+	t.Parallel()
+
+	handler, conf := newTestSubscribeHandler(t)
+	handler.appConfig.PathToConfig = map[string]config.PathConfig{
+		"/test.yaml": conf,
+	}
+
+	if _, _, ok := handler.resolveRoute("/test.yaml/anything"); ok {
+		t.Fatalf("expected route without users to reject extra path segment")
+	}
+}
+
+// TestGet_AcceptsSignedLinkUntilExpiry 用于验证开启签名访问的路由接受未过期的签名地址，过期或篡改的签名不会回退到用户令牌。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_AcceptsSignedLinkUntilExpiry(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestSubscribeHandler(t)
+	handler.appConfig.Signing.Secrets = []string{"current-secret-0001", "previous-secret-01"}
+	conf.AccessControl = &config.AccessControlConfig{Signed: true, Users: []string{"alice"}}
+	conf.AccessUsers = []config.AccessUser{{Name: "alice", TokenHash: config.HashToken("alice-token")}}
+	handler.appConfig.PathToConfig = map[string]config.PathConfig{
+		"/test.yaml": conf,
+	}
+
+	valid := urlsign.Query("previous-secret-01", "/test.yaml", time.Now().Add(time.Hour))
+	expired := urlsign.Query("current-secret-0001", "/test.yaml", time.Now().Add(-time.Minute))
+	otherRoute := urlsign.Query("current-secret-0001", "/other.yaml", time.Now().Add(time.Hour))
+
+	cases := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{name: "signed", target: "/test.yaml?flag=1&" + valid, want: http.StatusOK},
+		{name: "token", target: "/test.yaml?token=alice-token", want: http.StatusOK},
+		{name: "expired", target: "/test.yaml?" + expired, want: http.StatusNotFound},
+		{name: "other route", target: "/test.yaml?" + otherRoute, want: http.StatusNotFound},
+		{name: "bad signature with token", target: "/test.yaml?token=alice-token&exp=1&sig=abc", want: http.StatusNotFound},
+		{name: "missing", target: "/test.yaml", want: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, tc.target, nil)
+		c.Params = gin.Params{{Key: "path", Value: c.Request.URL.Path}}
+
+		handler.Get(c)
+
+		if recorder.Code != tc.want {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.want, recorder.Code)
+		}
+	}
+}
+
+// TestGet_RejectsClientIPOutsideAllowlist 用于验证客户端 IP 不在允许网段内时按不存在处理。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_RejectsClientIPOutsideAllowlist(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestSubscribeHandler(t)
+	ipAccess, err := config.NewIPMatcher(config.IPAccessRule{IPAllow: []string{"192.168.1.0/24"}})
+	if err != nil {
+		t.Fatalf("NewIPMatcher returned error: %v", err)
+	}
+	conf.IPAccess = ipAccess
+	handler.appConfig.PathToConfig = map[string]config.PathConfig{
+		"/test.yaml": conf,
+	}
+
+	for remoteAddr, want := range map[string]int{
+		"192.168.1.20:50000": http.StatusOK,
+		"203.0.113.5:50000":  http.StatusNotFound,
+	} {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, "/test.yaml", nil)
+		c.Request.RemoteAddr = remoteAddr
+		c.Params = gin.Params{{Key: "path", Value: "/test.yaml"}}
+
+		handler.Get(c)
+
+		if recorder.Code != want {
+			t.Fatalf("%s: expected status %d, got %d", remoteAddr, want, recorder.Code)
+		}
+	}
+}
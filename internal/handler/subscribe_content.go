@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	emoji "github.com/Andrew-M-C/go.emoji"
@@ -13,7 +15,6 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/djx30103/vpsub/internal/config"
-	"github.com/djx30103/vpsub/pkg/bytesize"
 	"github.com/djx30103/vpsub/pkg/provider/base"
 )
 
@@ -39,53 +40,132 @@ func createProxyGroupNode(name string) *yaml.Node {
 	}
 }
 
-// newAppendGroupNodes 用于根据流量信息生成需要附加到订阅中的展示分组节点。
+// renderUsageLines 用于按展示配置渲染需要注入订阅的展示文案，各订阅格式共用同一份渲染结果。
+// 配置了 Lines 时按顺序渲染满足条件的展示行，渲染结果为空白的行会被跳过；全部行都被跳过时返回空列表，调用方原样返回订阅内容。
 // 参数含义：apiInfo 为流量信息；usageDisplay 为展示格式配置。
-// 返回值：返回待追加的分组节点列表和模板渲染错误。
-func newAppendGroupNodes(apiInfo *base.APIResponseInfo, usageDisplay *config.UsageDisplayConfig) ([]*yaml.Node, error) {
-	groupList := make([]*yaml.Node, 0, 2)
+// 返回值：返回展示文案和模板渲染错误；未配置 Lines 时按重置时间、流量顺序返回两行。
+func renderUsageLines(apiInfo *base.APIResponseInfo, usageDisplay *config.UsageDisplayConfig) ([]string, error) {
+	// 所有模板共用同一份展示数据，与配置校验使用的示例数据字段一致。
+	data := newUsageTemplateData(apiInfo, usageDisplay.FormatBytes, time.Now())
+
+	if len(usageDisplay.Lines) > 0 {
+		lines := make([]string, 0, len(usageDisplay.Lines))
+		for i, line := range usageDisplay.Lines {
+			matched, err := config.MatchUsageCondition(line.When, data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate usage line %d condition: %w", i, err)
+			}
+			if !matched {
+				continue
+			}
+
+			rendered, err := usageDisplay.RenderTemplate(line.Template, data, apiInfo.ResetLocation)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render usage line %d: %w", i, err)
+			}
+			if strings.TrimSpace(rendered) != "" {
+				lines = append(lines, rendered)
+			}
+		}
+		return lines, nil
+	}
 
-	// 重置时间按与配置校验一致的模板规则展开，避免校验与运行时语义不一致。
-	resetTimeFormat, err := renderResetTimeUsageTemplate(apiInfo.Expire, usageDisplay.ResetTimeFormat)
+	resetTimeFormat, err := usageDisplay.RenderTemplate(usageDisplay.ResetTimeFormat, data, apiInfo.ResetLocation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render reset time usage template: %w", err)
 	}
-	groupList = append(groupList, createProxyGroupNode(resetTimeFormat))
 
-	// 流量展示同样按模板执行，支持条件与内置模板函数等标准语义。
-	trafficFormat, err := renderTrafficUsageTemplate(apiInfo, usageDisplay)
+	trafficFormat, err := usageDisplay.RenderTemplate(usageDisplay.TrafficFormat, data, apiInfo.ResetLocation)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render traffic usage template: %w", err)
 	}
-	groupList = append(groupList, createProxyGroupNode(trafficFormat))
 
-	return groupList, nil
+	return []string{resetTimeFormat, trafficFormat}, nil
 }
 
-// renderResetTimeUsageTemplate 用于将重置时间按展示模板渲染为订阅分组名称。
-// 参数含义：expireUnix 为重置时间戳；format 为配置中的重置时间模板。
-// 返回值：返回渲染后的展示文案和模板执行错误。
-func renderResetTimeUsageTemplate(expireUnix int64, format string) (string, error) {
-	t := time.Unix(expireUnix, 0)
+// newUsageTemplateData 用于把流量信息整理为流量展示模板与订阅模板 .usage 共用的数据。
+// 重置时间按重置规则所在时区展示，避免服务部署时区与重置时区不同导致日期偏移一天。
+// 参数含义：apiInfo 为流量信息；formatBytes 为按展示配置格式化流量的函数；now 为当前时间，用于计算距离重置的天数。
+// 返回值：返回模板数据，百分比为保留一位小数的浮点数，距离重置天数为整数，以 _bytes 结尾的字节数与 Unix 时间戳为 int64，其余字段为字符串。
+func newUsageTemplateData(apiInfo *base.APIResponseInfo, formatBytes func(int64) string, now time.Time) map[string]any {
+	used := apiInfo.Upload + apiInfo.Download
+	percent := 0.0
+	if apiInfo.Total > 0 {
+		percent = math.Round(float64(used)/float64(apiInfo.Total)*1000) / 10
+	}
 
-	return config.ExecuteTemplate(format, map[string]string{
-		"year":  t.Format("2006"),
-		"month": t.Format("01"),
-		"day":   t.Format("02"),
-	})
+	resetAt := time.Unix(apiInfo.ResetAt, 0)
+	if apiInfo.ResetLocation != nil {
+		resetAt = resetAt.In(apiInfo.ResetLocation)
+	}
+
+	// 不足一天按一天计算，已过重置时间时为 0。
+	daysLeft := 0
+	if remaining := resetAt.Sub(now); remaining > 0 {
+		daysLeft = int(math.Ceil(remaining.Hours() / 24))
+	}
+
+	data := map[string]any{
+		"used":              formatBytes(used),
+		"total":             formatBytes(apiInfo.Total),
+		"remaining":         formatBytes(max(apiInfo.Total-used, 0)),
+		"upload":            formatBytes(apiInfo.Upload),
+		"download":          formatBytes(apiInfo.Download),
+		"used_bytes":        used,
+		"total_bytes":       apiInfo.Total,
+		"remaining_bytes":   max(apiInfo.Total-used, 0),
+		"upload_bytes":      apiInfo.Upload,
+		"download_bytes":    apiInfo.Download,
+		"percent":           percent,
+		"days_left":         daysLeft,
+		"reset_at":          apiInfo.ResetAt,
+		"reset_date":        resetAt.Format("2006-01-02"),
+		"service_expire_at": apiInfo.ServiceExpireAt,
+		"year":              resetAt.Format("2006"),
+		"month":             resetAt.Format("01"),
+		"day":               resetAt.Format("02"),
+		"hour":              resetAt.Format("15"),
+		"minute":            resetAt.Format("04"),
+		"weekday":           chineseWeekdays[resetAt.Weekday()],
+		"service_year":      "",
+		"service_month":     "",
+		"service_day":       "",
+	}
+
+	// 服务商未提供到期时间时保持空串，模板可通过 {{if .service_year}} 按需展示。
+	if apiInfo.ServiceExpireAt > 0 {
+		serviceExpireAt := time.Unix(apiInfo.ServiceExpireAt, 0)
+		data["service_year"] = serviceExpireAt.Format("2006")
+		data["service_month"] = serviceExpireAt.Format("01")
+		data["service_day"] = serviceExpireAt.Format("02")
+	}
+
+	return data
 }
 
-// renderTrafficUsageTemplate 用于将流量信息按展示模板渲染为订阅分组名称。
-// 参数含义：apiInfo 为流量信息；usageDisplay 为展示配置。
-// 返回值：返回渲染后的展示文案和模板执行错误。
-func renderTrafficUsageTemplate(apiInfo *base.APIResponseInfo, usageDisplay *config.UsageDisplayConfig) (string, error) {
-	return config.ExecuteTemplate(usageDisplay.TrafficFormat, map[string]string{
-		"used":  bytesize.Format(apiInfo.Download+apiInfo.Upload, usageDisplay.TrafficUnit),
-		"total": bytesize.Format(apiInfo.Total, usageDisplay.TrafficUnit),
-	})
+// chineseWeekdays 为星期的中文展示文案，下标与 time.Weekday 一致。
+var chineseWeekdays = [...]string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// appendUsage 用于按路由的订阅格式把流量信息注入订阅内容。
+// 参数含义：format 为订阅格式；fileContent 为原始订阅文件内容；apiInfo 为流量信息；usageDisplay 为展示格式配置。
+// 返回值：返回处理后的订阅内容和处理错误。
+func appendUsage(format string, fileContent []byte, apiInfo *base.APIResponseInfo, usageDisplay *config.UsageDisplayConfig) ([]byte, error) {
+	switch format {
+	case config.FormatSingBox:
+		return appendSingBoxUsageOutbounds(fileContent, apiInfo, usageDisplay)
+	case config.FormatURIList:
+		return appendURIListUsageNodes(fileContent, apiInfo, usageDisplay)
+	case config.FormatSurge, config.FormatLoon:
+		return appendSurgeUsageGroups(fileContent, apiInfo, usageDisplay)
+	case config.FormatQuantumultX:
+		return appendQuantumultXUsageServers(fileContent, apiInfo, usageDisplay)
+	default:
+		return appendUsageGroups(fileContent, apiInfo, usageDisplay)
+	}
 }
 
-// appendUsageGroups 用于把流量信息组装成代理分组后写回订阅内容。
+// appendUsageGroups 用于把流量信息组装成代理分组后插入订阅内容的 proxy-groups 序列。
+// 按节点在原文中的行列位置直接拼接字节，新分组沿用原有缩进风格，其余内容逐字节保留。
 // 参数含义：fileContent 为原始订阅文件内容；apiInfo 为流量信息；usageDisplay 为展示格式配置。
 // 返回值：返回处理后的订阅内容和处理错误。
 func appendUsageGroups(fileContent []byte, apiInfo *base.APIResponseInfo, usageDisplay *config.UsageDisplayConfig) ([]byte, error) {
@@ -94,9 +174,6 @@ func appendUsageGroups(fileContent []byte, apiInfo *base.APIResponseInfo, usageD
 		return nil, fmt.Errorf("failed to read yaml config: %w", err)
 	}
 
-	emojiTokens := make(map[string]string)
-	replaceNodeEmojiToToken(&root, emojiTokens)
-
 	groupList, err := findProxyGroupsNode(&root)
 	if err != nil {
 		return nil, err
@@ -105,34 +182,65 @@ func appendUsageGroups(fileContent []byte, apiInfo *base.APIResponseInfo, usageD
 		return nil, errors.New("no proxy-groups found in config")
 	}
 
-	appendGroupList, err := newAppendGroupNodes(apiInfo, usageDisplay)
+	lines, err := renderUsageLines(apiInfo, usageDisplay)
 	if err != nil {
 		return nil, err
 	}
-	if len(appendGroupList) == 0 {
-		return nil, errors.New("no usage groups found in config")
+	if len(lines) == 0 {
+		return fileContent, nil
 	}
-	for _, groupNode := range appendGroupList {
-		replaceNodeEmojiToToken(groupNode, emojiTokens)
+
+	// 整个 proxy-groups 写成 [...] 时没有可沿用的行结构，退回重新编码整份文档。
+	lineStarts := yamlLineStarts(fileContent)
+	first := groupList.Content[0]
+	style, ok := detectYAMLGroupStyle(fileContent, lineStarts, first)
+	if groupList.Style&yaml.FlowStyle != 0 || !ok {
+		return reencodeUsageGroups(&root, groupList, lines, usageDisplay.Prepend)
 	}
 
+	var groups bytes.Buffer
+	for _, line := range lines {
+		style.writeGroup(&groups, line)
+	}
+
+	// 前置时插在第一个分组的短横线所在行之前；追加时插在最后一个分组占用的最后一行之后。
+	var insertAt int
 	if usageDisplay.Prepend {
-		groupList.Content = append(appendGroupList, groupList.Content...)
+		dash := yamlDashOffset(fileContent, yamlNodeOffset(fileContent, lineStarts, first))
+		insertAt = bytes.LastIndexByte(fileContent[:dash], '\n') + 1
 	} else {
-		groupList.Content = append(groupList.Content, appendGroupList...)
+		insertAt = yamlItemEnd(fileContent, lineStarts, groupList.Content[len(groupList.Content)-1], style.dashIndent)
 	}
 
 	var buffer bytes.Buffer
-	encoder := yaml.NewEncoder(&buffer)
-	encoder.SetIndent(2)
-	if err := encoder.Encode(&root); err != nil {
-		return nil, fmt.Errorf("failed to marshal yaml config: %w", err)
+	buffer.Grow(len(fileContent) + groups.Len() + len(style.newline))
+	buffer.Write(fileContent[:insertAt])
+	// 文件末尾没有换行时先补齐，避免新分组接在最后一行后面。
+	if insertAt > 0 && fileContent[insertAt-1] != '\n' {
+		buffer.WriteString(style.newline)
 	}
-	if err := encoder.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close yaml encoder: %w", err)
+	buffer.Write(groups.Bytes())
+	buffer.Write(fileContent[insertAt:])
+
+	return buffer.Bytes(), nil
+}
+
+// reencodeUsageGroups 用于在语法树中插入展示分组后重新编码整份文档，仅用于无法按行拼接的 flow 风格 proxy-groups。
+// 参数含义：root 为文档根节点；groupList 为 proxy-groups 序列节点；lines 为展示文案；prepend 为是否插入到开头。
+// 返回值：返回处理后的订阅内容和编码错误。
+func reencodeUsageGroups(root *yaml.Node, groupList *yaml.Node, lines []string, prepend bool) ([]byte, error) {
+	appendGroupList := make([]*yaml.Node, 0, len(lines))
+	for _, line := range lines {
+		appendGroupList = append(appendGroupList, createProxyGroupNode(line))
 	}
 
-	return restoreEmojiTokens(buffer.Bytes(), emojiTokens), nil
+	if prepend {
+		groupList.Content = append(appendGroupList, groupList.Content...)
+	} else {
+		groupList.Content = append(groupList.Content, appendGroupList...)
+	}
+
+	return encodeYAMLDocument(root)
 }
 
 // findProxyGroupsNode 用于从 YAML 根节点中定位 proxy-groups 对应的序列节点。
@@ -205,6 +313,26 @@ func replaceEmojiWithToken(input string, emojiTokens map[string]string) string {
 	})
 }
 
+// encodeYAMLDocument 用于把修改后的语法树重新编码为 YAML，编码前后通过占位符保留 emoji 原文。
+// 参数含义：root 为文档根节点。
+// 返回值：返回编码后的内容和编码错误。
+func encodeYAMLDocument(root *yaml.Node) ([]byte, error) {
+	emojiTokens := make(map[string]string)
+	replaceNodeEmojiToToken(root, emojiTokens)
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to marshal yaml config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close yaml encoder: %w", err)
+	}
+
+	return restoreEmojiTokens(buffer.Bytes(), emojiTokens), nil
+}
+
 // restoreEmojiTokens 用于在 YAML 编码完成后恢复占位符对应的 emoji。
 // 参数含义：content 为编码后的 YAML 内容；emojiTokens 为 emoji 到占位符的映射表。
 // 返回值：返回恢复 emoji 后的 YAML 内容。
@@ -220,66 +348,78 @@ func restoreEmojiTokens(content []byte, emojiTokens map[string]string) []byte {
 // 参数含义：conf 为当前路径配置。
 // 返回值：返回订阅文件内容和读取错误。
 func (h *SubscribeHandler) readSubscriptionFile(conf config.PathConfig) ([]byte, error) {
-	filePath := filepath.Join(h.appConfig.Global.Storage.SubscriptionDir, conf.File)
+	content, _, err := h.readCachedFile(conf.File)
+	return content, err
+}
+
+// readCachedFile 用于读取订阅目录下的单个文件，文件未变化时直接返回缓存内容。
+// 缓存按订阅文件而不是对外路径区分，多个路由或变体共用同一文件时只读取一次。
+// 参数含义：file 为订阅目录下的相对路径。
+// 返回值：返回文件内容、版本标识和读取错误。
+func (h *SubscribeHandler) readCachedFile(file string) ([]byte, string, error) {
+	filePath := filepath.Join(h.appConfig.Global.Storage.SubscriptionDir, file)
 	stat, err := os.Stat(filePath)
 	if err != nil {
-		h.deleteCachedSubscriptionFile(conf.Path)
-		return nil, fmt.Errorf("failed to stat file: %w", err)
+		h.deleteCachedSubscriptionFile(file)
+		return nil, "", fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	if cached, ok := h.getCachedSubscriptionFile(conf.Path, stat.ModTime(), stat.Size()); ok {
-		return cached, nil
+	version := fileVersion(stat)
+	if cached, ok := h.getCachedSubscriptionFile(file, version); ok {
+		return cached, version, nil
 	}
 
 	res, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	h.setCachedSubscriptionFile(conf.Path, stat.ModTime(), stat.Size(), res)
-	return res, nil
+	h.setCachedSubscriptionFile(file, version, res)
+	return res, version, nil
+}
+
+// fileVersion 用于根据文件元信息生成版本标识。
+// 同时包含修改时间和大小，避免仅靠时间戳时漏掉同秒内更新。
+// 参数含义：stat 为文件元信息。
+// 返回值：返回版本标识。
+func fileVersion(stat os.FileInfo) string {
+	return fmt.Sprintf("%d:%d", stat.ModTime().UnixNano(), stat.Size())
 }
 
-// getCachedSubscriptionFile 用于按 path 和文件元信息读取仍然有效的订阅缓存。
-// 参数含义：path 为路由路径；modTime 为当前文件修改时间；size 为当前文件大小。
+// getCachedSubscriptionFile 用于按缓存键和版本标识读取仍然有效的订阅缓存。
+// 参数含义：key 为缓存键，通常为订阅文件相对路径；version 为当前版本标识。
 // 返回值：返回缓存内容以及是否命中。
-func (h *SubscribeHandler) getCachedSubscriptionFile(path string, modTime time.Time, size int64) ([]byte, bool) {
+func (h *SubscribeHandler) getCachedSubscriptionFile(key string, version string) ([]byte, bool) {
 	h.fileMu.RLock()
 	defer h.fileMu.RUnlock()
 
-	cached, ok := h.fileCache[path]
-	if !ok {
-		return nil, false
-	}
-
-	// 同时比较修改时间和大小，避免仅靠时间戳时漏掉同秒内更新。
-	if !cached.modTime.Equal(modTime) || cached.size != size {
+	cached, ok := h.fileCache[key]
+	if !ok || cached.version != version {
 		return nil, false
 	}
 
 	return cached.content, true
 }
 
-// setCachedSubscriptionFile 用于更新指定 path 的订阅文件缓存。
-// 参数含义：path 为路由路径；modTime 为文件修改时间；size 为文件大小；content 为文件内容。
+// setCachedSubscriptionFile 用于更新指定缓存键的订阅缓存。
+// 参数含义：key 为缓存键，通常为订阅文件相对路径；version 为版本标识；content 为文件内容。
 // 返回值：无。
-func (h *SubscribeHandler) setCachedSubscriptionFile(path string, modTime time.Time, size int64, content []byte) {
+func (h *SubscribeHandler) setCachedSubscriptionFile(key string, version string, content []byte) {
 	h.fileMu.Lock()
 	defer h.fileMu.Unlock()
 
-	h.fileCache[path] = cachedSubscriptionFile{
+	h.fileCache[key] = cachedSubscriptionFile{
 		content: content,
-		modTime: modTime,
-		size:    size,
+		version: version,
 	}
 }
 
 // deleteCachedSubscriptionFile 用于在文件不存在或读取失败时清理过期缓存。
-// 参数含义：path 为路由路径。
+// 参数含义：key 为缓存键，通常为订阅文件相对路径。
 // 返回值：无。
-func (h *SubscribeHandler) deleteCachedSubscriptionFile(path string) {
+func (h *SubscribeHandler) deleteCachedSubscriptionFile(key string) {
 	h.fileMu.Lock()
 	defer h.fileMu.Unlock()
 
-	delete(h.fileCache, path)
+	delete(h.fileCache, key)
 }
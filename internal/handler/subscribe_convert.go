@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/internal/convert"
+)
+
+// targetQueryKey 为请求中指定转换目标格式的查询参数。
+const targetQueryKey = "target"
+
+// userAgentTarget 表示客户端 User-Agent 特征与其偏好订阅格式的对应关系。
+// 字段含义：keyword 为小写的 UA 特征；prefix 为是否只匹配 UA 开头；format 为对应的订阅格式。
+type userAgentTarget struct {
+	keyword string
+	prefix  bool
+	format  string
+}
+
+// userAgentTargets 按优先级排列，sing-box 图形客户端的 UA 为 SFA、SFI、SFM 等缩写，只能按前缀识别。
+var userAgentTargets = []userAgentTarget{
+	{keyword: "sing-box", format: convert.FormatSingBox},
+	{keyword: "sfa/", prefix: true, format: convert.FormatSingBox},
+	{keyword: "sfi/", prefix: true, format: convert.FormatSingBox},
+	{keyword: "sfm/", prefix: true, format: convert.FormatSingBox},
+	{keyword: "sft/", prefix: true, format: convert.FormatSingBox},
+	{keyword: "shadowrocket", format: convert.FormatURIList},
+	{keyword: "v2rayn", format: convert.FormatURIList},
+	{keyword: "v2box", format: convert.FormatURIList},
+	{keyword: "clash", format: convert.FormatClash},
+	{keyword: "mihomo", format: convert.FormatClash},
+	{keyword: "stash", format: convert.FormatClash},
+}
+
+// unsupportedProxiesHeader 为响应中列出转换时被跳过节点的响应头，节点名称逐个 URL 编码后以逗号分隔。
+const unsupportedProxiesHeader = "Subscription-Unsupported-Proxies"
+
+// resolveConvertTarget 用于确定本次请求的转换目标格式，优先级为查询参数、路由固定目标、按 User-Agent 自动识别。
+// 参数含义：c 为 Gin 上下文；conf 为当前路径配置。
+// 返回值：返回目标格式，空串表示不转换；detected 表示目标由 User-Agent 自动识别得到；
+// 查询参数指定了不支持的格式或源格式不支持转换时返回错误。
+func resolveConvertTarget(c *gin.Context, conf config.PathConfig) (target string, detected bool, err error) {
+	if target := strings.ToLower(strings.TrimSpace(c.Query(targetQueryKey))); target != "" {
+		if !convert.IsSupportedFormat(target) {
+			return "", false, errors.New("target is not supported")
+		}
+		if !convert.IsSupportedFormat(conf.Format) {
+			return "", false, errors.New("route format does not support conversion")
+		}
+		return target, false, nil
+	}
+
+	if conf.Target == config.TargetAuto {
+		return detectTargetFromUserAgent(c.GetHeader("User-Agent")), true, nil
+	}
+
+	return conf.Target, false, nil
+}
+
+// setUnsupportedProxiesHeader 用于把转换时被跳过的节点名称写入响应头，便于客户端发现节点缺失的原因。
+// 参数含义：c 为 Gin 上下文；unsupported 为被跳过的节点。
+// 返回值：无。
+func setUnsupportedProxiesHeader(c *gin.Context, unsupported []convert.Unsupported) {
+	if len(unsupported) == 0 {
+		return
+	}
+
+	// 响应头只能安全承载 ASCII，节点名称中的中文、emoji 和逗号都需要编码。
+	names := make([]string, 0, len(unsupported))
+	for _, item := range unsupported {
+		names = append(names, url.PathEscape(item.Name))
+	}
+	c.Header(unsupportedProxiesHeader, strings.Join(names, ","))
+}
+
+// detectTargetFromUserAgent 用于根据客户端 User-Agent 推断其偏好的订阅格式。
+// 参数含义：userAgent 为请求头中的 User-Agent。
+// 返回值：返回识别出的订阅格式，无法识别时返回空串表示保持源格式。
+func detectTargetFromUserAgent(userAgent string) string {
+	userAgent = strings.ToLower(userAgent)
+	for _, item := range userAgentTargets {
+		if item.prefix && strings.HasPrefix(userAgent, item.keyword) {
+			return item.format
+		}
+		if !item.prefix && strings.Contains(userAgent, item.keyword) {
+			return item.format
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/internal/convert"
+	"github.com/djx30103/vpsub/pkg/provider"
+	"github.com/djx30103/vpsub/pkg/provider/base"
+)
+
+const testConvertClashConfig = `proxies:
+  - {name: HK, type: trojan, server: hk.example.com, port: 443, password: pass}
+  - {name: WG, type: wireguard, server: wg.example.com, port: 51820}
+proxy-groups:
+  - {name: Proxy, type: select, proxies: [HK, WG]}
+`
+
+// TestDetectTargetFromUserAgent_MatchesKnownClients 用于验证常见客户端 UA 能被识别为对应的订阅格式，未知 UA 保持源格式。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestDetectTargetFromUserAgent_MatchesKnownClients(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"sing-box 1.10.0":              convert.FormatSingBox,
+		"SFA/1.10.0 (Android)":         convert.FormatSingBox,
+		"Shadowrocket/2070 CFNetwork":  convert.FormatURIList,
+		"v2rayN/6.42":                  convert.FormatURIList,
+		"clash-verge/v1.7.7":           convert.FormatClash,
+		"mihomo/1.18.10":               convert.FormatClash,
+		"Mozilla/5.0 (Macintosh; SFA)": "",
+		"curl/8.7.1":                   "",
+	}
+
+	for userAgent, want := range cases {
+		if got := detectTargetFromUserAgent(userAgent); got != want {
+			t.Fatalf("detectTargetFromUserAgent(%q) = %q, want %q", userAgent, got, want)
+		}
+	}
+}
+
+// TestGet_ConvertsSubscriptionByQueryTarget 用于验证 ?target= 会把 Clash 订阅转换为 sing-box 配置，且优先于路由固定目标。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_ConvertsSubscriptionByQueryTarget(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestConvertHandler(t)
+	conf.Target = convert.FormatURIList
+	handler.appConfig.PathToConfig["/convert"] = conf
+
+	recorder := serveTestConvertRequest(handler, "/convert?target=singbox", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+
+	var doc struct {
+		Outbounds []singBoxOutbound `json:"outbounds"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("expected sing-box json, got %v: %s", err, recorder.Body.String())
+	}
+
+	var tags []string
+	for _, outbound := range doc.Outbounds {
+		tags = append(tags, outbound.Tag)
+	}
+	if len(tags) != 3 || tags[1] != "HK" {
+		t.Fatalf("expected unsupported wireguard node to be skipped, got %v", tags)
+	}
+}
+
+// TestGet_ConvertsSubscriptionByUserAgentWhenTargetAuto 用于验证 target: auto 时按客户端 UA 选择目标格式，无法识别时返回原文件。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_ConvertsSubscriptionByUserAgentWhenTargetAuto(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestConvertHandler(t)
+	conf.Target = config.TargetAuto
+	handler.appConfig.PathToConfig["/convert"] = conf
+
+	recorder := serveTestConvertRequest(handler, "/convert", "Shadowrocket/2070")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	plain, encoded, err := convert.DecodeURIList(recorder.Body.Bytes())
+	if err != nil || !encoded {
+		t.Fatalf("expected base64 uri list, got %s", recorder.Body.String())
+	}
+	if got := string(plain); got != "trojan://pass@hk.example.com:443?security=tls#HK\n" {
+		t.Fatalf("unexpected uri list: %q", got)
+	}
+
+	recorder = serveTestConvertRequest(handler, "/convert", "curl/8.7.1")
+	if recorder.Body.String() != testConvertClashConfig {
+		t.Fatalf("expected original content for unknown user agent, got %s", recorder.Body.String())
+	}
+}
+
+// TestGet_ReportsUnsupportedProxiesInHeader 用于验证转换时被跳过的节点会以 URL 编码的名称列在响应头中。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_ReportsUnsupportedProxiesInHeader(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, _ := newTestConvertHandler(t)
+
+	recorder := serveTestConvertRequest(handler, "/convert?target=singbox", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if got := recorder.Header().Get(unsupportedProxiesHeader); got != "WG" {
+		t.Fatalf("expected unsupported proxies header WG, got %q", got)
+	}
+}
+
+// TestGet_FallsBackToSourceWhenAutoConversionFails 用于验证按 UA 自动转换失败时返回源订阅，而显式指定目标时仍返回 500。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_FallsBackToSourceWhenAutoConversionFails(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestConvertHandler(t)
+	content := "proxies:\n  - {name: 家宽 WG, type: wireguard, server: wg.example.com, port: 51820}\n"
+	if err := os.WriteFile(filepath.Join(handler.appConfig.Global.Storage.SubscriptionDir, conf.File), []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write subscription file: %v", err)
+	}
+	conf.Target = config.TargetAuto
+	handler.appConfig.PathToConfig["/convert"] = conf
+
+	recorder := serveTestConvertRequest(handler, "/convert", "Shadowrocket/2070")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	if recorder.Body.String() != content {
+		t.Fatalf("expected source content on auto conversion failure, got %s", recorder.Body.String())
+	}
+	if got := recorder.Header().Get(unsupportedProxiesHeader); got != "%E5%AE%B6%E5%AE%BD%20WG" {
+		t.Fatalf("expected escaped unsupported proxy name, got %q", got)
+	}
+
+	recorder = serveTestConvertRequest(handler, "/convert?target=uri-list", "Shadowrocket/2070")
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500 for explicit target, got %d", recorder.Code)
+	}
+}
+
+// TestGet_SelectsVariantAfterUserAgentGate 用于验证请求按 UA 选中变体文件，且变体只在通过 access_control.user_agent 后才会生效。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_SelectsVariantAfterUserAgentGate(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestConvertHandler(t)
+	variantContent := `{"outbounds":[]}`
+	if err := os.WriteFile(filepath.Join(handler.appConfig.Global.Storage.SubscriptionDir, "variant.json"), []byte(variantContent), 0o600); err != nil {
+		t.Fatalf("failed to write variant file: %v", err)
+	}
+	conf.Variants = []config.PathVariant{
+		{UserAgent: "sing-box", File: "variant.json", Format: config.FormatSingBox},
+	}
+	conf.UserAgent, _ = config.NewUserAgentMatcher(config.UserAgentRule{Exact: "ClashX"})
+	handler.appConfig.PathToConfig["/convert"] = conf
+
+	// UA 门禁先于变体选择，门禁不放行的客户端即使命中变体也按不存在处理。
+	recorder := serveTestConvertRequest(handler, "/convert", "sing-box 1.10.0")
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for variant user agent rejected by gate, got %d", recorder.Code)
+	}
+
+	recorder = serveTestConvertRequest(handler, "/convert", "ClashX")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != testConvertClashConfig {
+		t.Fatalf("expected route file for gate user agent, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	conf.UserAgent, _ = config.NewUserAgentMatcher(config.UserAgentRule{Allow: []string{"ClashX", "sing-box"}})
+	handler.appConfig.PathToConfig["/convert"] = conf
+
+	recorder = serveTestConvertRequest(handler, "/convert", "sing-box 1.10.0")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != variantContent {
+		t.Fatalf("expected variant file once gate allows its user agent, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+// TestGet_RejectsUnsupportedQueryTarget 用于验证 ?target= 指定不支持的格式时返回 400。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_RejectsUnsupportedQueryTarget(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, _ := newTestConvertHandler(t)
+
+	recorder := serveTestConvertRequest(handler, "/convert?target=surge", "")
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", recorder.Code)
+	}
+}
+
+// countingProvider 为测试用的服务商客户端，只记录被调用的次数。
+type countingProvider struct {
+	calls atomic.Int32
+}
+
+// GetServiceInfo 用于记录一次调用并返回固定的流量信息。
+func (p *countingProvider) GetServiceInfo(context.Context) (*base.APIResponseInfo, error) {
+	p.calls.Add(1)
+	return &base.APIResponseInfo{Total: 100}, nil
+}
+
+// TestGet_RejectsInvalidTargetBeforeQueryingProvider 用于验证非法的 ?target= 直接返回 400，不会触发服务商请求。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_RejectsInvalidTargetBeforeQueryingProvider(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestConvertHandler(t)
+	conf.ProviderType = "racknerd"
+	handler.appConfig.PathToConfig["/convert"] = conf
+	fake := &countingProvider{}
+	handler.providerClients[conf.ProviderRef] = fake
+
+	recorder := serveTestConvertRequest(handler, "/convert?target=surge", "")
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", recorder.Code)
+	}
+	if got := fake.calls.Load(); got != 0 {
+		t.Fatalf("expected no provider call for invalid target, got %d", got)
+	}
+
+	recorder = serveTestConvertRequest(handler, "/convert?target=singbox", "")
+	if recorder.Code != http.StatusOK || fake.calls.Load() != 1 {
+		t.Fatalf("expected provider to be queried for valid target, got status %d calls %d", recorder.Code, fake.calls.Load())
+	}
+}
+
+// newTestConvertHandler 用于构造包含可转换 Clash 订阅的测试处理器。
+// 参数含义：t 为测试上下文。
+// 返回值：返回测试处理器和 /convert 路由的路径配置。
+func newTestConvertHandler(t *testing.T) (*SubscribeHandler, config.PathConfig) {
+	t.Helper()
+
+	handler, conf := newTestSubscribeHandler(t)
+	conf.Path = "/convert"
+	conf.File = "convert.yaml"
+	conf.Format = config.FormatClash
+	conf.ProviderType = provider.ProviderType_Passthrough
+	if err := os.WriteFile(filepath.Join(handler.appConfig.Global.Storage.SubscriptionDir, conf.File), []byte(testConvertClashConfig), 0o600); err != nil {
+		t.Fatalf("failed to write subscription file: %v", err)
+	}
+	handler.appConfig.PathToConfig = map[string]config.PathConfig{
+		"/convert": conf,
+	}
+
+	return handler, conf
+}
+
+// serveTestConvertRequest 用于向测试处理器发起一次订阅请求。
+// 参数含义：handler 为测试处理器；target 为请求地址；userAgent 为请求 UA。
+// 返回值：返回响应记录器。
+func serveTestConvertRequest(handler *SubscribeHandler, target string, userAgent string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	c.Request.Header.Set("User-Agent", userAgent)
+	c.Params = gin.Params{{Key: "path", Value: c.Request.URL.Path}}
+
+	handler.Get(c)
+
+	return recorder
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djx30103/vpsub/internal/config"
+)
+
+// substituteCredentials 用于把路由配置的凭据替换到 Clash 节点中，只改写节点已有的字段，不为不使用该字段的协议新增字段。
+// 规则按配置顺序依次生效，同一节点命中多条规则时后面的规则覆盖前面的规则。
+// 参数含义：content 为 Clash 订阅内容；credentials 为编译后的凭据规则。
+// 返回值：返回替换后的订阅内容和解析或编码错误。
+func substituteCredentials(content []byte, credentials []config.CredentialSubstitution) ([]byte, error) {
+	if len(credentials) == 0 {
+		return content, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("failed to read yaml config: %w", err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("yaml root must be mapping")
+	}
+
+	proxies, err := findTopLevelSequence(root.Content[0], "proxies")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range sequenceItems(proxies) {
+		nameNode := mappingValue(item, "name")
+		if nameNode == nil {
+			continue
+		}
+
+		for _, credential := range credentials {
+			if credential.Match != nil && !credential.Match.MatchString(nameNode.Value) {
+				continue
+			}
+			for field, value := range credential.Fields {
+				valueNode := mappingValue(item, field)
+				if valueNode == nil || valueNode.Kind != yaml.ScalarNode {
+					continue
+				}
+				// 凭据统一按字符串写出，避免纯数字密码被编码为整数。
+				valueNode.Value = value
+				valueNode.Tag = "!!str"
+				valueNode.Style = 0
+			}
+		}
+	}
+
+	return encodeYAMLDocument(&root)
+}
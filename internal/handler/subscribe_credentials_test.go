@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"regexp"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djx30103/vpsub/internal/config"
+)
+
+// TestSubstituteCredentials_ReplacesExistingFieldsInOrder 用于验证凭据只替换节点已有字段，按名称匹配的规则覆盖全局规则，纯数字密码保持字符串。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestSubstituteCredentials_ReplacesExistingFieldsInOrder(t *testing.T) {
+	t.Parallel()
+
+	content := []byte(`proxies:
+  - {name: "🇭🇰 HK", type: vless, server: hk.example.com, uuid: shared-uuid}
+  - name: JP
+    type: trojan
+    server: jp.example.com
+    password: shared-password
+  - name: US Hysteria
+    type: hysteria
+    server: us.example.com
+    auth-str: shared-auth
+`)
+	credentials := []config.CredentialSubstitution{
+		{Fields: map[string]string{"uuid": "friend-uuid", "password": "friend-password", "auth-str": "friend-auth"}},
+		{Match: regexp.MustCompile(`^JP`), Fields: map[string]string{"password": "123456"}},
+	}
+
+	updated, err := substituteCredentials(content, credentials)
+	if err != nil {
+		t.Fatalf("substituteCredentials returned error: %v", err)
+	}
+
+	var got struct {
+		Proxies []map[string]any `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(updated, &got); err != nil {
+		t.Fatalf("failed to parse substituted subscription: %v\n%s", err, updated)
+	}
+
+	if got.Proxies[0]["uuid"] != "friend-uuid" || got.Proxies[0]["name"] != "🇭🇰 HK" {
+		t.Fatalf("unexpected vless proxy: %#v", got.Proxies[0])
+	}
+	if _, ok := got.Proxies[0]["password"]; ok {
+		t.Fatalf("expected password not to be added to vless proxy: %#v", got.Proxies[0])
+	}
+	if got.Proxies[1]["password"] != "123456" {
+		t.Fatalf("expected matched rule to override password as string, got %#v", got.Proxies[1]["password"])
+	}
+	if got.Proxies[2]["auth-str"] != "friend-auth" {
+		t.Fatalf("unexpected hysteria proxy: %#v", got.Proxies[2])
+	}
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/pkg/provider/base"
+)
+
+const (
+	surgeProxyGroupSection     = "Proxy Group"
+	quantumultXServerSection   = "server_local"
+	managedConfigPrefix        = "#!MANAGED-CONFIG"
+	managedConfigIntervalParam = "interval="
+)
+
+// iniNameReplacer 用于清理展示文案中会破坏 INI 行结构的分隔符。
+var iniNameReplacer = strings.NewReplacer(",", " ", "=", " ", "\r", " ", "\n", " ")
+
+// appendSurgeUsageGroups 用于把流量信息组装成 select 策略组后写入 Surge / Loon 配置的 [Proxy Group] 段。
+// 参数含义：fileContent 为原始配置内容；apiInfo 为流量信息；usageDisplay 为展示格式配置。
+// 返回值：返回处理后的配置内容和处理错误。
+func appendSurgeUsageGroups(fileContent []byte, apiInfo *base.APIResponseInfo, usageDisplay *config.UsageDisplayConfig) ([]byte, error) {
+	lines, err := renderUsageLines(apiInfo, usageDisplay)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return fileContent, nil
+	}
+
+	entries := make([]string, 0, len(lines))
+	for _, line := range lines {
+		entries = append(entries, iniNameReplacer.Replace(line)+" = select, DIRECT")
+	}
+
+	return insertINISectionLines(fileContent, surgeProxyGroupSection, entries, usageDisplay.Prepend)
+}
+
+// appendQuantumultXUsageServers 用于把流量信息组装成伪节点后写入 Quantumult X 配置的 [server_local] 段。
+// 伪节点指向本机 1 端口，客户端误选时也不会产生外部连接。
+// 参数含义：fileContent 为原始配置内容；apiInfo 为流量信息；usageDisplay 为展示格式配置。
+// 返回值：返回处理后的配置内容和处理错误。
+func appendQuantumultXUsageServers(fileContent []byte, apiInfo *base.APIResponseInfo, usageDisplay *config.UsageDisplayConfig) ([]byte, error) {
+	lines, err := renderUsageLines(apiInfo, usageDisplay)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return fileContent, nil
+	}
+
+	entries := make([]string, 0, len(lines))
+	for _, line := range lines {
+		entries = append(entries, "trojan=127.0.0.1:1, password=vpsub, tag="+iniNameReplacer.Replace(line))
+	}
+
+	return insertINISectionLines(fileContent, quantumultXServerSection, entries, usageDisplay.Prepend)
+}
+
+// insertINISectionLines 用于在 INI 配置的指定段落头部或尾部插入若干行，其余内容逐字节保留。
+// 参数含义：content 为原始配置内容；section 为不含方括号的段落名，按大小写不敏感匹配；entries 为待插入的行；prepend 为是否插入到段落开头。
+// 返回值：返回处理后的配置内容；段落不存在时返回错误。
+func insertINISectionLines(content []byte, section string, entries []string, prepend bool) ([]byte, error) {
+	lineBreak := "\n"
+	if bytes.Contains(content, []byte("\r\n")) {
+		lineBreak = "\r\n"
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	header := -1
+	end := len(lines)
+	for i, line := range lines {
+		name, ok := parseINISectionHeader(line)
+		if !ok {
+			continue
+		}
+		if header >= 0 {
+			end = i
+			break
+		}
+		if strings.EqualFold(name, section) {
+			header = i
+		}
+	}
+	if header < 0 {
+		return nil, fmt.Errorf("no [%s] section found in config", section)
+	}
+
+	// 追加时跳过段落末尾的空行，让新行紧跟在最后一个有效条目之后。
+	insertAt := header + 1
+	if !prepend {
+		insertAt = end
+		for insertAt > header+1 && strings.TrimSpace(lines[insertAt-1]) == "" {
+			insertAt--
+		}
+	}
+
+	var buffer bytes.Buffer
+	buffer.Grow(len(content) + len(entries)*64)
+	for _, line := range lines[:insertAt] {
+		buffer.WriteString(line)
+	}
+	// 段落位于文件末尾且最后一行没有换行符时，先补齐换行再写入新行。
+	if insertAt > 0 && !strings.HasSuffix(lines[insertAt-1], "\n") {
+		buffer.WriteString(lineBreak)
+	}
+	for _, entry := range entries {
+		buffer.WriteString(entry)
+		buffer.WriteString(lineBreak)
+	}
+	for _, line := range lines[insertAt:] {
+		buffer.WriteString(line)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// parseINISectionHeader 用于识别 INI 段落头。
+// 参数含义：line 为包含行尾换行符的单行内容。
+// 返回值：返回段落名以及该行是否为段落头。
+func parseINISectionHeader(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) < 2 || trimmed[0] != '[' || trimmed[len(trimmed)-1] != ']' {
+		return "", false
+	}
+
+	return strings.TrimSpace(trimmed[1 : len(trimmed)-1]), true
+}
+
+// rewriteManagedConfig 用于把 Surge 配置首部的 #!MANAGED-CONFIG 行改写为当前订阅地址与更新间隔。
+// 仅改写已存在的托管声明，保留 strict 等其他参数；文件未声明托管时原样返回。
+// 参数含义：content 为配置内容；subscribeURL 为客户端本次请求的订阅地址；interval 为订阅更新间隔。
+// 返回值：返回改写后的配置内容。
+func rewriteManagedConfig(content []byte, subscribeURL string, interval time.Duration) []byte {
+	lineEnd := bytes.IndexByte(content, '\n')
+	if lineEnd < 0 {
+		lineEnd = len(content)
+	}
+
+	// Surge 只识别位于首行的托管声明，兼容 UTF-8 BOM。
+	firstLine := string(bytes.TrimPrefix(content[:lineEnd], []byte("\xef\xbb\xbf")))
+	firstLine = strings.TrimRight(firstLine, "\r")
+	if !strings.HasPrefix(firstLine, managedConfigPrefix) {
+		return content
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(firstLine, managedConfigPrefix))
+	params := []string{managedConfigIntervalParam + strconv.FormatInt(int64(interval.Seconds()), 10)}
+	for i, field := range fields {
+		// 第一个字段是旧的订阅地址，直接替换。
+		if i == 0 || strings.HasPrefix(field, managedConfigIntervalParam) {
+			continue
+		}
+		params = append(params, field)
+	}
+
+	rewritten := managedConfigPrefix + " " + subscribeURL + " " + strings.Join(params, " ")
+	if strings.HasSuffix(string(content[:lineEnd]), "\r") {
+		rewritten += "\r"
+	}
+
+	var buffer bytes.Buffer
+	buffer.Grow(len(content) + len(subscribeURL))
+	buffer.WriteString(rewritten)
+	buffer.Write(content[lineEnd:])
+
+	return buffer.Bytes()
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/pkg/provider"
+)
+
+const testSurgeProfile = `#!MANAGED-CONFIG https://old.example.com/sub interval=43200 strict=true
+[General]
+loglevel = notify
+
+[Proxy]
+HK = trojan, hk.example.com, 443, password=pass
+
+[Proxy Group]
+Proxy = select, HK, DIRECT
+
+[Rule]
+FINAL,Proxy
+`
+
+// TestAppendSurgeUsageGroups_InsertsIntoProxyGroupSection 用于验证 Surge 配置会在 [Proxy Group] 段首尾插入展示策略组，其余内容保持不变。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendSurgeUsageGroups_InsertsIntoProxyGroupSection(t *testing.T) {
+	t.Parallel()
+
+	updated, err := appendSurgeUsageGroups([]byte(testSurgeProfile), newTestSingBoxAPIInfo(), newTestUsageDisplayConfig())
+	if err != nil {
+		t.Fatalf("appendSurgeUsageGroups returned error: %v", err)
+	}
+
+	want := strings.Replace(testSurgeProfile, "[Proxy Group]\n", "[Proxy Group]\n📅 重置日期 2026-04-13 = select, DIRECT\n⛽ 已用流量 1G / 5G = select, DIRECT\n", 1)
+	if string(updated) != want {
+		t.Fatalf("unexpected prepend output:\n%s", updated)
+	}
+
+	usageDisplay := newTestUsageDisplayConfig()
+	usageDisplay.Prepend = false
+	updated, err = appendSurgeUsageGroups([]byte(testSurgeProfile), newTestSingBoxAPIInfo(), usageDisplay)
+	if err != nil {
+		t.Fatalf("appendSurgeUsageGroups returned error: %v", err)
+	}
+
+	want = strings.Replace(testSurgeProfile, "Proxy = select, HK, DIRECT\n", "Proxy = select, HK, DIRECT\n📅 重置日期 2026-04-13 = select, DIRECT\n⛽ 已用流量 1G / 5G = select, DIRECT\n", 1)
+	if string(updated) != want {
+		t.Fatalf("unexpected append output:\n%s", updated)
+	}
+}
+
+// TestAppendQuantumultXUsageServers_AppendsToLastSection 用于验证 Quantumult X 配置在段落位于文件末尾且缺少结尾换行时也能正确追加伪节点，并清理文案中的分隔符。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendQuantumultXUsageServers_AppendsToLastSection(t *testing.T) {
+	t.Parallel()
+
+	usageDisplay := newTestUsageDisplayConfig()
+	usageDisplay.Prepend = false
+	usageDisplay.TrafficFormat = "{{.used}},{{.total}}"
+
+	content := "[general]\r\nnetwork_check_url=http://www.gstatic.com/generate_204\r\n\r\n[SERVER_LOCAL]\r\nshadowsocks=hk.example.com:443, method=aes-128-gcm, password=pass, tag=HK"
+	updated, err := appendQuantumultXUsageServers([]byte(content), newTestSingBoxAPIInfo(), usageDisplay)
+	if err != nil {
+		t.Fatalf("appendQuantumultXUsageServers returned error: %v", err)
+	}
+
+	want := content + "\r\ntrojan=127.0.0.1:1, password=vpsub, tag=📅 重置日期 2026-04-13\r\ntrojan=127.0.0.1:1, password=vpsub, tag=1G 5G\r\n"
+	if string(updated) != want {
+		t.Fatalf("unexpected output:\n got: %q\nwant: %q", updated, want)
+	}
+}
+
+// TestInsertINISectionLines_ReturnsErrorWhenSectionMissing 用于验证目标段落不存在时返回错误，避免把内容写到错误位置。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestInsertINISectionLines_ReturnsErrorWhenSectionMissing(t *testing.T) {
+	t.Parallel()
+
+	if _, err := insertINISectionLines([]byte("[Proxy]\nHK = direct\n"), surgeProxyGroupSection, []string{"x = select, DIRECT"}, true); err == nil {
+		t.Fatalf("expected error when [Proxy Group] is missing")
+	}
+}
+
+// TestRewriteManagedConfig_ReplacesURLAndInterval 用于验证托管声明会替换为当前地址与更新间隔，并保留其他参数；未声明托管时原样返回。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestRewriteManagedConfig_ReplacesURLAndInterval(t *testing.T) {
+	t.Parallel()
+
+	updated := rewriteManagedConfig([]byte(testSurgeProfile), "https://sub.example.com/surge?token=a", 6*time.Hour)
+	wantFirstLine := "#!MANAGED-CONFIG https://sub.example.com/surge?token=a interval=21600 strict=true\n"
+	if !strings.HasPrefix(string(updated), wantFirstLine) {
+		t.Fatalf("unexpected managed config line: %q", strings.SplitN(string(updated), "\n", 2)[0])
+	}
+	if strings.TrimPrefix(string(updated), wantFirstLine) != strings.SplitN(testSurgeProfile, "\n", 2)[1] {
+		t.Fatalf("expected remaining content unchanged, got:\n%s", updated)
+	}
+
+	unmanaged := []byte("[Proxy Group]\nProxy = select, DIRECT\n")
+	if got := rewriteManagedConfig(unmanaged, "https://sub.example.com/surge", time.Hour); string(got) != string(unmanaged) {
+		t.Fatalf("expected unmanaged profile unchanged, got:\n%s", got)
+	}
+}
+
+// TestGet_RewritesManagedConfigForSurge 用于验证 Surge 路由在完整请求链路中会按请求地址与更新间隔改写托管声明。
+// X-Forwarded-Proto 只在连接来自可信代理时采信，配置了 server.public_url 时优先使用该地址。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGet_RewritesManagedConfigForSurge(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	// httptest 构造的请求固定来自 192.0.2.1。
+	trusted, err := config.NewIPMatcher(config.IPAccessRule{IPAllow: []string{"192.0.2.0/24"}})
+	if err != nil {
+		t.Fatalf("NewIPMatcher returned error: %v", err)
+	}
+	untrusted, err := config.NewIPMatcher(config.IPAccessRule{IPAllow: []string{"10.0.0.1"}})
+	if err != nil {
+		t.Fatalf("NewIPMatcher returned error: %v", err)
+	}
+
+	cases := []struct {
+		name           string
+		trustedProxies *config.IPMatcher
+		publicURL      string
+		want           string
+	}{
+		{name: "trusted proxy", trustedProxies: trusted, want: "https://sub.example.com/surge?token=a"},
+		{name: "untrusted peer", trustedProxies: untrusted, want: "http://sub.example.com/surge?token=a"},
+		{name: "public url", publicURL: "https://vpn.example.com/sub", want: "https://vpn.example.com/sub/surge?token=a"},
+	}
+	for _, tc := range cases {
+		handler, conf := newTestSubscribeHandler(t)
+		handler.appConfig.TrustedProxies = tc.trustedProxies
+		handler.appConfig.Server.PublicURL = tc.publicURL
+		conf.Path = "/surge"
+		conf.File = "surge.conf"
+		conf.Format = config.FormatSurge
+		conf.ProviderType = provider.ProviderType_Passthrough
+		if err := os.WriteFile(filepath.Join(handler.appConfig.Global.Storage.SubscriptionDir, conf.File), []byte(testSurgeProfile), 0o600); err != nil {
+			t.Fatalf("failed to write subscription file: %v", err)
+		}
+		handler.appConfig.PathToConfig = map[string]config.PathConfig{
+			"/surge": conf,
+		}
+
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, "http://sub.example.com/surge?token=a", nil)
+		c.Request.Header.Set("X-Forwarded-Proto", "https")
+		c.Params = gin.Params{{Key: "path", Value: "/surge"}}
+
+		handler.Get(c)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tc.name, recorder.Code)
+		}
+		if want := "#!MANAGED-CONFIG " + tc.want + " interval=3600 strict=true\n"; !strings.HasPrefix(recorder.Body.String(), want) {
+			t.Fatalf("%s: unexpected managed config line: %q", tc.name, strings.SplitN(recorder.Body.String(), "\n", 2)[0])
+		}
+	}
+}
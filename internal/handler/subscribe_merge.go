@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djx30103/vpsub/internal/config"
+)
+
+// maxMergeRenameAttempts 限制为单个重名节点寻找新名称的次数，避免异常模板导致死循环。
+const maxMergeRenameAttempts = 1000
+
+// readMergedSubscription 用于读取并合并路由配置的多个订阅文件，任一来源文件未变化时复用上一次的合并结果。
+// 参数含义：conf 为配置了 Files 的路径配置。
+// 返回值：返回合并后的订阅内容和读取或合并错误。
+func (h *SubscribeHandler) readMergedSubscription(conf config.PathConfig) ([]byte, error) {
+	contents := make([][]byte, 0, len(conf.Files))
+	versions := make([]string, 0, len(conf.Files))
+	baseIndex := 0
+	for i, file := range conf.Files {
+		content, version, err := h.readCachedFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read merge source %q: %w", file, err)
+		}
+
+		contents = append(contents, content)
+		versions = append(versions, version)
+		if file == conf.File {
+			baseIndex = i
+		}
+	}
+
+	// 合并结果取决于来源文件、基准文件与后缀模板，三者都纳入缓存键；来源文件的版本标识共同组成结果版本。
+	key := "merge:" + conf.File + "\n" + conf.ConflictSuffix + "\n" + strings.Join(conf.Files, "\n")
+	version := strings.Join(versions, ",")
+	if cached, ok := h.getCachedSubscriptionFile(key, version); ok {
+		return cached, nil
+	}
+
+	merged, err := mergeClashSubscriptions(conf.Files, contents, baseIndex, conf.ConflictSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	h.setCachedSubscriptionFile(key, version, merged)
+	return merged, nil
+}
+
+// mergeClashSubscriptions 用于合并多个 Clash 订阅：按文件顺序拼接 proxies 与 proxy-groups，其余字段取自基准文件。
+// 节点与分组共用同一命名空间，基准文件最先占用名称，其 rules 引用的分组始终保持原名；
+// 其余文件的重名项按后缀模板重命名，并同步改写其所在文件分组中的引用。
+// 参数含义：files 为来源文件相对路径；contents 为对应的文件内容；baseIndex 为基准文件下标；conflictSuffix 为重命名后缀模板。
+// 返回值：返回合并后的订阅内容和解析或编码错误。
+func mergeClashSubscriptions(files []string, contents [][]byte, baseIndex int, conflictSuffix string) ([]byte, error) {
+	used := make(map[string]struct{})
+	roots := make([]yaml.Node, len(contents))
+	proxiesByFile := make([]*yaml.Node, len(contents))
+	groupsByFile := make([]*yaml.Node, len(contents))
+
+	// 命名按“基准文件优先、其余按文件顺序”处理，输出顺序仍与 files 一致。
+	order := []int{baseIndex}
+	for i := range contents {
+		if i != baseIndex {
+			order = append(order, i)
+		}
+	}
+
+	for _, i := range order {
+		root := &roots[i]
+		if err := yaml.Unmarshal(contents[i], root); err != nil {
+			return nil, fmt.Errorf("failed to read yaml config %q: %w", files[i], err)
+		}
+		if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("yaml root of %q must be mapping", files[i])
+		}
+
+		proxies, err := findTopLevelSequence(root.Content[0], "proxies")
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", files[i], err)
+		}
+		groups, err := findTopLevelSequence(root.Content[0], "proxy-groups")
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", files[i], err)
+		}
+
+		// 文件内的分组只可能引用本文件的节点与分组，因此重命名映射按文件独立维护。
+		fileStem := strings.TrimSuffix(path.Base(files[i]), path.Ext(files[i]))
+		renames := make(map[string]string)
+		for _, item := range slices.Concat(sequenceItems(proxies), sequenceItems(groups)) {
+			if err := renameOnConflict(item, fileStem, conflictSuffix, used, renames); err != nil {
+				return nil, fmt.Errorf("%q: %w", files[i], err)
+			}
+		}
+		for _, group := range sequenceItems(groups) {
+			rewriteGroupReferences(group, renames)
+		}
+
+		proxiesByFile[i] = proxies
+		groupsByFile[i] = groups
+	}
+
+	mergedProxies := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	mergedGroups := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for i := range contents {
+		mergedProxies.Content = append(mergedProxies.Content, sequenceItems(proxiesByFile[i])...)
+		mergedGroups.Content = append(mergedGroups.Content, sequenceItems(groupsByFile[i])...)
+	}
+
+	baseRoot := &roots[baseIndex]
+	setTopLevelValue(baseRoot.Content[0], "proxies", mergedProxies)
+	setTopLevelValue(baseRoot.Content[0], "proxy-groups", mergedGroups)
+
+	return encodeYAMLDocument(baseRoot)
+}
+
+// findTopLevelSequence 用于在根映射中定位指定键的序列节点。
+// 参数含义：mappingNode 为 YAML 根映射；key 为顶层键名。
+// 返回值：返回序列节点，键不存在时返回 nil；值不是序列时返回错误。
+func findTopLevelSequence(mappingNode *yaml.Node, key string) (*yaml.Node, error) {
+	for i := 0; i+1 < len(mappingNode.Content); i += 2 {
+		if mappingNode.Content[i].Value != key {
+			continue
+		}
+
+		valueNode := mappingNode.Content[i+1]
+		// 空值（如 "proxies:" 后不写内容）按空列表处理。
+		if valueNode.Kind == yaml.ScalarNode && valueNode.Tag == "!!null" {
+			return nil, nil
+		}
+		if valueNode.Kind != yaml.SequenceNode {
+			return nil, fmt.Errorf("%s must be sequence", key)
+		}
+		return valueNode, nil
+	}
+
+	return nil, nil
+}
+
+// setTopLevelValue 用于替换根映射中指定键的值，键不存在时追加到末尾。
+// 参数含义：mappingNode 为 YAML 根映射；key 为顶层键名；value 为新的值节点。
+// 返回值：无。
+func setTopLevelValue(mappingNode *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mappingNode.Content); i += 2 {
+		if mappingNode.Content[i].Value == key {
+			// 保留基准文件原有序列节点上的注释与风格，只替换其中的元素。
+			value.HeadComment = mappingNode.Content[i+1].HeadComment
+			value.LineComment = mappingNode.Content[i+1].LineComment
+			value.FootComment = mappingNode.Content[i+1].FootComment
+			mappingNode.Content[i+1] = value
+			return
+		}
+	}
+
+	mappingNode.Content = append(mappingNode.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		value,
+	)
+}
+
+// sequenceItems 用于安全地取出序列节点中的元素。
+// 参数含义：node 为序列节点，可以为 nil。
+// 返回值：返回元素列表。
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	if node == nil {
+		return nil
+	}
+
+	return node.Content
+}
+
+// renameOnConflict 用于在节点或分组与已合并的名称冲突时按后缀模板重命名，并记录重命名映射。
+// 参数含义：item 为节点或分组的映射节点；fileStem 为来源文件名；conflictSuffix 为后缀模板；
+// used 为已占用的名称集合；renames 为当前文件的原名称到新名称的映射。
+// 返回值：返回后缀模板渲染错误或无法找到可用名称的错误。
+func renameOnConflict(item *yaml.Node, fileStem string, conflictSuffix string, used map[string]struct{}, renames map[string]string) error {
+	nameNode := mappingValue(item, "name")
+	if nameNode == nil {
+		return nil
+	}
+
+	name := nameNode.Value
+	if _, ok := used[name]; !ok {
+		used[name] = struct{}{}
+		return nil
+	}
+
+	// 序号从 2 开始，与第一次出现的同名项区分开。
+	for index := 2; index < maxMergeRenameAttempts; index++ {
+		suffix, err := config.ExecuteTemplate(conflictSuffix, map[string]string{
+			"index": strconv.Itoa(index),
+			"file":  fileStem,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to render conflict suffix: %w", err)
+		}
+
+		candidate := name + suffix
+		if _, ok := used[candidate]; ok {
+			continue
+		}
+
+		used[candidate] = struct{}{}
+		renames[name] = candidate
+		nameNode.Value = candidate
+		return nil
+	}
+
+	return fmt.Errorf("failed to rename duplicated name %q", name)
+}
+
+// rewriteGroupReferences 用于把分组 proxies 列表中被重命名的引用替换为新名称。
+// 参数含义：group 为分组映射节点；renames 为原名称到新名称的映射。
+// 返回值：无。
+func rewriteGroupReferences(group *yaml.Node, renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+
+	members := mappingValue(group, "proxies")
+	if members == nil || members.Kind != yaml.SequenceNode {
+		return
+	}
+
+	for _, member := range members.Content {
+		if renamed, ok := renames[member.Value]; ok && member.Kind == yaml.ScalarNode {
+			member.Value = renamed
+		}
+	}
+}
+
+// mappingValue 用于读取映射节点中指定键对应的值节点。
+// 参数含义：node 为映射节点；key 为键名。
+// 返回值：返回值节点，不存在或节点不是映射时返回 nil。
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	testMergeBase = `mixed-port: 7890
+proxies:
+  - name: HK
+    type: ss
+    server: hk.example.com
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies:
+      - HK
+rules:
+  - MATCH,Proxy
+`
+	testMergeExtra = `proxies:
+  - name: HK
+    type: ss
+    server: hk2.example.com
+  - name: 🇯🇵 JP
+    type: ss
+    server: jp.example.com
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies:
+      - HK
+      - 🇯🇵 JP
+rules:
+  - DOMAIN-SUFFIX,jp.example.com,Proxy
+  - MATCH,DIRECT
+`
+)
+
+// mergedTestSubscription 表示合并测试中需要断言的 Clash 字段。
+type mergedTestSubscription struct {
+	MixedPort int `yaml:"mixed-port"`
+	Proxies   []struct {
+		Name   string `yaml:"name"`
+		Server string `yaml:"server"`
+	} `yaml:"proxies"`
+	ProxyGroups []struct {
+		Name    string   `yaml:"name"`
+		Proxies []string `yaml:"proxies"`
+	} `yaml:"proxy-groups"`
+	Rules []string `yaml:"rules"`
+}
+
+// TestMergeClashSubscriptions_RenamesConflictsAndKeepsBaseRules 用于验证合并会拼接节点与分组、重命名冲突项并同步改写引用，其余字段取自基准文件。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestMergeClashSubscriptions_RenamesConflictsAndKeepsBaseRules(t *testing.T) {
+	t.Parallel()
+
+	merged, err := mergeClashSubscriptions(
+		[]string{"hk/base.yaml", "jp/extra.yaml"},
+		[][]byte{[]byte(testMergeBase), []byte(testMergeExtra)},
+		0,
+		" ({{.file}} {{.index}})",
+	)
+	if err != nil {
+		t.Fatalf("mergeClashSubscriptions returned error: %v", err)
+	}
+
+	var got mergedTestSubscription
+	if err := yaml.Unmarshal(merged, &got); err != nil {
+		t.Fatalf("failed to parse merged subscription: %v\n%s", err, merged)
+	}
+
+	if got.MixedPort != 7890 || len(got.Rules) != 1 || got.Rules[0] != "MATCH,Proxy" {
+		t.Fatalf("expected base fields to be kept, got port=%d rules=%v", got.MixedPort, got.Rules)
+	}
+
+	var names []string
+	for _, proxy := range got.Proxies {
+		names = append(names, proxy.Name)
+	}
+	if want := "HK,HK (extra 2),🇯🇵 JP"; strings.Join(names, ",") != want {
+		t.Fatalf("unexpected proxy names: %v", names)
+	}
+
+	if len(got.ProxyGroups) != 2 || got.ProxyGroups[1].Name != "Proxy (extra 2)" {
+		t.Fatalf("unexpected proxy groups: %+v", got.ProxyGroups)
+	}
+	if members := strings.Join(got.ProxyGroups[1].Proxies, ","); members != "HK (extra 2),🇯🇵 JP" {
+		t.Fatalf("expected renamed references in extra group, got %s", members)
+	}
+	if !strings.Contains(string(merged), "🇯🇵 JP") {
+		t.Fatalf("expected emoji to be kept as-is, got:\n%s", merged)
+	}
+}
+
+// TestReadMergedSubscription_RefreshesWhenSourceChanges 用于验证合并结果会被缓存，并在任一来源文件变化后重新合并。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestReadMergedSubscription_RefreshesWhenSourceChanges(t *testing.T) {
+	t.Parallel()
+
+	handler, conf := newTestSubscribeHandler(t)
+	dir := handler.appConfig.Global.Storage.SubscriptionDir
+	if err := os.WriteFile(filepath.Join(dir, "base.yaml"), []byte(testMergeBase), 0o600); err != nil {
+		t.Fatalf("failed to write base file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "extra.yaml"), []byte(testMergeExtra), 0o600); err != nil {
+		t.Fatalf("failed to write extra file: %v", err)
+	}
+
+	conf.File = "extra.yaml"
+	conf.Files = []string{"base.yaml", "extra.yaml"}
+	conf.ConflictSuffix = "-{{.index}}"
+
+	first, err := handler.readMergedSubscription(conf)
+	if err != nil {
+		t.Fatalf("readMergedSubscription returned error: %v", err)
+	}
+
+	// 基准文件不是第一个文件时仍先占用名称，其规则引用的 Proxy 分组必须指向基准文件自己的分组。
+	var got mergedTestSubscription
+	if err := yaml.Unmarshal(first, &got); err != nil {
+		t.Fatalf("failed to parse merged subscription: %v\n%s", err, first)
+	}
+	if rules := strings.Join(got.Rules, ";"); rules != "DOMAIN-SUFFIX,jp.example.com,Proxy;MATCH,DIRECT" {
+		t.Fatalf("expected rules from designated base, got %s", rules)
+	}
+	if len(got.ProxyGroups) != 2 || got.ProxyGroups[0].Name != "Proxy-2" || got.ProxyGroups[1].Name != "Proxy" {
+		t.Fatalf("expected non-base group to be renamed, got %+v", got.ProxyGroups)
+	}
+	if members := strings.Join(got.ProxyGroups[1].Proxies, ","); members != "HK,🇯🇵 JP" {
+		t.Fatalf("expected base group to keep its own members, got %s", members)
+	}
+	if members := strings.Join(got.ProxyGroups[0].Proxies, ","); members != "HK-2" {
+		t.Fatalf("expected renamed reference in non-base group, got %s", members)
+	}
+
+	cached, err := handler.readMergedSubscription(conf)
+	if err != nil {
+		t.Fatalf("readMergedSubscription returned error: %v", err)
+	}
+	if &cached[0] != &first[0] {
+		t.Fatalf("expected merged result to be served from cache")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	updated := strings.Replace(testMergeBase, "hk.example.com", "hk-new.example.com", 1)
+	if err := os.WriteFile(filepath.Join(dir, "base.yaml"), []byte(updated), 0o600); err != nil {
+		t.Fatalf("failed to update base file: %v", err)
+	}
+
+	refreshed, err := handler.readMergedSubscription(conf)
+	if err != nil {
+		t.Fatalf("readMergedSubscription returned error after update: %v", err)
+	}
+	if !strings.Contains(string(refreshed), "hk-new.example.com") {
+		t.Fatalf("expected merged result to refresh after source change, got:\n%s", refreshed)
+	}
+}
@@ -3,6 +3,8 @@ package handler
 import (
 	"context"
 	"fmt"
+	"net/http/httptrace"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -22,17 +24,12 @@ func (h *SubscribeHandler) getProviderInfo(ctx context.Context, conf config.Path
 			}
 		}
 
-		client, err := provider.NewProvider(base.APIRequestInfo{
-			APIID:          conf.APIID,
-			APIKey:         conf.APIKey,
-			ProviderType:   conf.ProviderType,
-			RequestTimeout: conf.ProviderConfig.RequestTimeout,
-		})
+		client, err := h.getProviderClient(conf)
 		if err != nil {
-			return nil, fmt.Errorf("failed to new provider: %w", err)
+			return nil, err
 		}
 
-		info, err := client.GetServiceInfo(ctx)
+		info, err := client.GetServiceInfo(httptrace.WithClientTrace(ctx, h.newProviderClientTrace(ctx, conf)))
 		if err != nil {
 			return nil, fmt.Errorf("failed to get service info: %w", err)
 		}
@@ -50,13 +47,87 @@ func (h *SubscribeHandler) getProviderInfo(ctx context.Context, conf config.Path
 	}
 
 	if res != nil {
-		return res.(*base.APIResponseInfo)
+		return applyProviderOverrides(conf.ProviderConfig, res.(*base.APIResponseInfo))
 	}
 
 	// API 失败时降级为最近一次成功缓存，尽量保证订阅接口继续可用。
 	if cached, ok := h.cache.Get(conf.ProviderRef); ok {
-		return cached.(*base.APIResponseInfo)
+		return applyProviderOverrides(conf.ProviderConfig, cached.(*base.APIResponseInfo))
 	}
 
 	return nil
 }
+
+// applyProviderOverrides 用于按账号配置的重置规则、服务到期时间与流量总量覆盖服务商给出的对应字段。
+// 每次请求都按当前时间重新推算重置时间，避免缓存跨越重置日后仍展示旧日期；返回副本以免污染共享缓存。
+// 参数含义：providerConf 为运行时服务商配置；info 为服务商流量信息。
+// 返回值：未配置任何覆盖时原样返回，否则返回覆盖后的副本。
+func applyProviderOverrides(providerConf config.ProviderConfig, info *base.APIResponseInfo) *base.APIResponseInfo {
+	if info == nil || (providerConf.ResetDay <= 0 && providerConf.ServiceExpireAt <= 0 && providerConf.Total <= 0) {
+		return info
+	}
+
+	overridden := *info
+	if providerConf.ResetDay > 0 {
+		overridden.ResetAt = base.NextResetUnix(time.Now(), providerConf.ResetDay, providerConf.ResetLocation)
+		overridden.ResetLocation = providerConf.ResetLocation
+	}
+
+	// BandwagonHost 与 RackNerd 的接口均不返回账单到期时间，只能由账号配置手动指定。
+	if providerConf.ServiceExpireAt > 0 {
+		overridden.ServiceExpireAt = providerConf.ServiceExpireAt
+	}
+
+	// 服务商接口返回的总量不准确或缺失时，以账号配置的流量总量为准。
+	if providerConf.Total > 0 {
+		overridden.Total = providerConf.Total
+	}
+
+	return &overridden
+}
+
+// getProviderClient 用于按 provider_ref 获取复用的服务商客户端，首次访问时创建并缓存。
+// 配置在运行期不会变化，因此同一账号的客户端及其连接池可以在整个进程生命周期内复用。
+// 参数含义：conf 为当前路径配置。
+// 返回值：返回服务商客户端和创建错误，创建失败时不会写入缓存。
+func (h *SubscribeHandler) getProviderClient(conf config.PathConfig) (provider.Provider, error) {
+	h.providerMu.Lock()
+	defer h.providerMu.Unlock()
+
+	if client, ok := h.providerClients[conf.ProviderRef]; ok {
+		return client, nil
+	}
+
+	client, err := provider.NewProvider(base.APIRequestInfo{
+		APIID:          conf.APIID,
+		APIKey:         conf.APIKey,
+		ProviderType:   conf.ProviderType,
+		RequestTimeout: conf.ProviderConfig.RequestTimeout,
+		Transport:      conf.ProviderConfig.Transport,
+		CountingMode:   conf.ProviderConfig.CountingMode,
+		DataMultiplier: conf.ProviderConfig.DataMultiplier,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to new provider: %w", err)
+	}
+
+	h.providerClients[conf.ProviderRef] = client
+	return client, nil
+}
+
+// newProviderClientTrace 用于构造服务商请求的连接追踪钩子，在 debug 日志中记录连接是否被复用。
+// 参数含义：ctx 为请求上下文；conf 为当前路径配置。
+// 返回值：返回可挂载到请求上下文的 ClientTrace。
+func (h *SubscribeHandler) newProviderClientTrace(ctx context.Context, conf config.PathConfig) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			h.logger.WithContext(ctx).Debug("provider connection acquired",
+				zap.String("provider_ref", conf.ProviderRef),
+				zap.String("remote_addr", info.Conn.RemoteAddr().String()),
+				zap.Bool("reused", info.Reused),
+				zap.Bool("was_idle", info.WasIdle),
+				zap.Duration("idle_time", info.IdleTime),
+			)
+		},
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djx30103/vpsub/internal/config"
+)
+
+// injectClashProviders 用于把路由配置的 rule-providers、proxy-providers 与前置规则写入 Clash 配置。
+// 与订阅文件中同名的提供者以路由配置为准；前置规则插在原有 rules 之前，保证优先匹配。
+// 参数含义：content 为 Clash 配置内容；conf 为当前路径配置；routeURL 用于把 route 引用展开为完整地址。
+// 返回值：返回处理后的配置内容和解析或编码错误。
+func injectClashProviders(content []byte, conf config.PathConfig, routeURL func(route string) string) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("failed to read yaml config: %w", err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("yaml root must be mapping")
+	}
+	mappingNode := root.Content[0]
+
+	if err := mergeClashProviders(mappingNode, "rule-providers", conf.RuleProviders, routeURL); err != nil {
+		return nil, err
+	}
+	if err := mergeClashProviders(mappingNode, "proxy-providers", conf.ProxyProviders, routeURL); err != nil {
+		return nil, err
+	}
+
+	if len(conf.Rules) > 0 {
+		rules, err := findTopLevelSequence(mappingNode, "rules")
+		if err != nil {
+			return nil, err
+		}
+		prepended := make([]*yaml.Node, 0, len(conf.Rules)+len(sequenceItems(rules)))
+		for _, rule := range conf.Rules {
+			prepended = append(prepended, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: rule})
+		}
+		prepended = append(prepended, sequenceItems(rules)...)
+		if rules != nil {
+			rules.Content = prepended
+		} else {
+			setTopLevelValue(mappingNode, "rules", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: prepended})
+		}
+	}
+
+	return encodeYAMLDocument(&root)
+}
+
+// mergeClashProviders 用于把提供者写入根映射中指定键对应的映射，键不存在时新建。
+// 参数含义：mappingNode 为 YAML 根映射；key 为 rule-providers 或 proxy-providers；providers 为路由配置的提供者；routeURL 用于展开 route 引用。
+// 返回值：返回已有值不是映射或提供者编码失败时的错误。
+func mergeClashProviders(mappingNode *yaml.Node, key string, providers []config.ClashProvider, routeURL func(route string) string) error {
+	if len(providers) == 0 {
+		return nil
+	}
+
+	target := mappingValue(mappingNode, key)
+	if target == nil || (target.Kind == yaml.ScalarNode && target.Tag == "!!null") {
+		target = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setTopLevelValue(mappingNode, key, target)
+	}
+	if target.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s must be mapping", key)
+	}
+
+	for _, item := range providers {
+		// 配置中的字段原样写入，只有 route 引用展开为 url，拷贝一份避免改动共享的运行时配置。
+		options := maps.Clone(item.Options)
+		if item.Route != "" {
+			options["url"] = routeURL(item.Route)
+		}
+
+		var valueNode yaml.Node
+		if err := valueNode.Encode(options); err != nil {
+			return fmt.Errorf("failed to encode %s %q: %w", key, item.Name, err)
+		}
+
+		replaced := false
+		for i := 0; i+1 < len(target.Content); i += 2 {
+			if target.Content[i].Value == item.Name {
+				target.Content[i+1] = &valueNode
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			target.Content = append(target.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item.Name},
+				&valueNode,
+			)
+		}
+	}
+
+	return nil
+}
+
+// routeProviderURL 用于生成展开 route 引用的函数，地址以对外访问地址为前缀。
+// 被引用的路由要求用户令牌时附带本次请求的令牌；配置加载时已确保能访问当前路由的用户同样能访问被引用的路由。
+// 参数含义：baseURL 为对外访问地址前缀；token 为本次请求携带的访问令牌，可为空。
+// 返回值：返回把路由路径展开为完整地址的函数。
+func (h *SubscribeHandler) routeProviderURL(baseURL, token string) func(route string) string {
+	return func(route string) string {
+		target := baseURL + route
+		if token != "" && len(h.appConfig.PathToConfig[route].AccessUsers) > 0 {
+			target += "?token=" + url.QueryEscape(token)
+		}
+
+		return target
+	}
+}
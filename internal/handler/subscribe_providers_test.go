@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djx30103/vpsub/internal/config"
+)
+
+// TestInjectClashProviders_MergesProvidersAndPrependsRules 用于验证提供者按路由配置写入或覆盖同名项，route 引用展开为完整地址，前置规则排在原有规则之前。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestInjectClashProviders_MergesProvidersAndPrependsRules(t *testing.T) {
+	t.Parallel()
+
+	content := []byte(`rule-providers:
+  reject:
+    type: http
+    behavior: domain
+    url: https://old.example.com/reject.yaml
+  direct:
+    type: file
+    behavior: domain
+    path: ./direct.yaml
+rules:
+  - MATCH,Proxy
+`)
+	conf := config.PathConfig{
+		RuleProviders: []config.ClashProvider{
+			{Name: "reject", Options: map[string]any{"type": "http", "behavior": "domain", "url": "https://rules.example.com/reject.yaml", "interval": 86400}},
+		},
+		ProxyProviders: []config.ClashProvider{
+			{Name: "Friend", Route: "/friend", Options: map[string]any{"type": "http", "interval": 3600}},
+		},
+		Rules: []string{"RULE-SET,reject,REJECT"},
+	}
+
+	updated, err := injectClashProviders(content, conf, func(route string) string { return "https://sub.example.com" + route })
+	if err != nil {
+		t.Fatalf("injectClashProviders returned error: %v", err)
+	}
+
+	var got struct {
+		RuleProviders  map[string]map[string]any `yaml:"rule-providers"`
+		ProxyProviders map[string]map[string]any `yaml:"proxy-providers"`
+		Rules          []string                  `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(updated, &got); err != nil {
+		t.Fatalf("failed to parse injected config: %v\n%s", err, updated)
+	}
+
+	if got.RuleProviders["reject"]["url"] != "https://rules.example.com/reject.yaml" || got.RuleProviders["reject"]["interval"] != 86400 {
+		t.Fatalf("expected route provider to override reject, got %#v", got.RuleProviders["reject"])
+	}
+	if got.RuleProviders["direct"]["path"] != "./direct.yaml" {
+		t.Fatalf("expected existing provider to be kept, got %#v", got.RuleProviders)
+	}
+	if got.ProxyProviders["Friend"]["url"] != "https://sub.example.com/friend" {
+		t.Fatalf("expected route reference to be expanded, got %#v", got.ProxyProviders)
+	}
+	if len(got.Rules) != 2 || got.Rules[0] != "RULE-SET,reject,REJECT" || got.Rules[1] != "MATCH,Proxy" {
+		t.Fatalf("unexpected rules: %v", got.Rules)
+	}
+	if _, ok := conf.ProxyProviders[0].Options["url"]; ok {
+		t.Fatalf("expected runtime provider options to stay untouched")
+	}
+}
+
+// TestRouteProviderURL_CarriesTokenForProtectedRoutes 用于验证引用要求用户令牌的路由时附带本次请求的令牌，公开路由不附带。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestRouteProviderURL_CarriesTokenForProtectedRoutes(t *testing.T) {
+	t.Parallel()
+
+	handler, conf := newTestSubscribeHandler(t)
+	protected := conf
+	protected.AccessUsers = []config.AccessUser{{Name: "alice", TokenHash: config.HashToken("alice token")}}
+	handler.appConfig.PathToConfig = map[string]config.PathConfig{
+		"/public": conf,
+		"/friend": protected,
+	}
+
+	routeURL := handler.routeProviderURL("https://sub.example.com", "alice token")
+	if got := routeURL("/friend"); got != "https://sub.example.com/friend?token=alice+token" {
+		t.Fatalf("expected protected route to carry token, got %q", got)
+	}
+	if got := routeURL("/public"); got != "https://sub.example.com/public" {
+		t.Fatalf("expected public route without token, got %q", got)
+	}
+}
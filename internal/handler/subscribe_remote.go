@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/pkg/provider/base"
+)
+
+// maxRemoteSubscriptionSize 限制单次拉取的远程订阅大小，避免异常上游把整段响应读入内存。
+const maxRemoteSubscriptionSize = 32 << 20
+
+// readSubscriptionSource 用于读取当前请求对应的原始订阅内容：远程订阅源从上游拉取，多文件路由读取后合并，否则直接读取订阅目录。
+// 参数含义：ctx 为请求上下文；conf 为当前路径配置。
+// 返回值：返回订阅内容和读取错误。
+func (h *SubscribeHandler) readSubscriptionSource(ctx context.Context, conf config.PathConfig) ([]byte, error) {
+	switch {
+	case conf.Remote != nil:
+		return h.readRemoteSubscription(ctx, conf)
+	case len(conf.Files) > 0:
+		return h.readMergedSubscription(conf)
+	default:
+		return h.readSubscriptionFile(conf)
+	}
+}
+
+// readRemoteSubscription 用于拉取远程订阅，内部集成 singleflight 去重、内存缓存与落盘副本降级。
+// 缓存与去重均以订阅地址和请求头为键，拉取参数相同的路由与变体共用同一份结果，即使落盘文件不同也不会串用。
+// 参数含义：ctx 为请求上下文；conf 为配置了远程订阅源的路径配置。
+// 返回值：返回订阅内容；上游失败且没有可用的落盘副本时返回拉取错误。
+func (h *SubscribeHandler) readRemoteSubscription(ctx context.Context, conf config.PathConfig) ([]byte, error) {
+	remote := conf.Remote
+	cacheKey := remote.CacheKey()
+
+	// 去重键带上前缀，避免与按 provider_ref 去重的服务商请求冲突。
+	res, err, _ := h.sfGroup.Do("remote:"+cacheKey, func() (interface{}, error) {
+		if remote.CacheTTL != 0 {
+			if cached, ok := h.remoteCache.Get(cacheKey); ok {
+				return cached.([]byte), nil
+			}
+		}
+
+		// 拉取结果由并发请求共享，不随发起请求的客户端断开而取消，超时由拉取配置约束。
+		content, err := fetchRemoteSubscription(context.WithoutCancel(ctx), remote, conf.ProviderConfig.Transport)
+		if err != nil {
+			return nil, err
+		}
+
+		if remote.CacheTTL != 0 {
+			h.remoteCache.Set(cacheKey, content, remote.CacheTTL)
+		}
+
+		if err := h.writeRemoteBackup(conf.File, content); err != nil {
+			h.logger.WithContext(ctx).Warn("failed to write remote subscription backup", zap.String("path", conf.Path), zap.String("file", conf.File), zap.Error(err))
+		}
+
+		return content, nil
+	})
+	if err == nil {
+		return res.([]byte), nil
+	}
+
+	// 上游失败时降级为最近一次成功拉取的落盘副本，尽量保证订阅接口继续可用。
+	h.logger.WithContext(ctx).Warn("failed to fetch remote subscription, fallback to last good copy", zap.String("path", conf.Path), zap.String("url", redactURL(remote.URL)), zap.Error(err))
+
+	content, backupErr := h.readSubscriptionFile(conf)
+	if backupErr != nil {
+		// 只包装拉取错误，避免副本不存在被当作订阅不存在而返回 404。
+		return nil, fmt.Errorf("failed to fetch remote subscription: %w (no last good copy: %v)", err, backupErr)
+	}
+
+	return content, nil
+}
+
+// fetchRemoteSubscription 用于按远程订阅源配置发起一次拉取。
+// 参数含义：ctx 为请求上下文；remote 为远程订阅源；transportConf 为路由所引用账号的出站网络配置。
+// 返回值：返回响应体内容；状态码非 200、响应为空或超过大小限制时返回错误，错误中的地址已去除查询参数。
+func fetchRemoteSubscription(ctx context.Context, remote *config.RemoteSource, transportConf base.TransportConfig) ([]byte, error) {
+	// 远程订阅与服务商 API 走同一套出站代理、DNS 与源地址配置，相同配置共用连接池。
+	transport, err := base.SharedTransport(transportConf)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout:   remote.Timeout,
+		Transport: transport,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, remote.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote subscription request: %w", err)
+	}
+	for name, value := range remote.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("User-Agent", remote.UserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// 请求错误会带上完整地址，面板令牌通常写在查询参数里，返回前先脱敏，避免写入日志。
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
+		return nil, fmt.Errorf("failed to get remote subscription: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get remote subscription, status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSubscriptionSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote subscription body: %w", err)
+	}
+
+	if len(body) > maxRemoteSubscriptionSize {
+		return nil, errors.New("remote subscription is too large")
+	}
+
+	// 面板在令牌失效等情况下常返回空内容，视为失败以免覆盖上一份可用副本。
+	if len(body) == 0 {
+		return nil, errors.New("remote subscription is empty")
+	}
+
+	return body, nil
+}
+
+// redactURL 用于去除订阅地址中的查询参数与用户密码，便于在日志中安全记录上游地址。
+// 参数含义：raw 为原始订阅地址。
+// 返回值：返回脱敏后的地址，无法解析时返回固定占位符。
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid url>"
+	}
+
+	if u.RawQuery != "" {
+		u.RawQuery = "redacted"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.Redacted()
+}
+
+// writeRemoteBackup 用于把最近一次成功拉取的远程订阅写入订阅目录，先写临时文件再替换，避免读到写了一半的副本。
+// 参数含义：file 为订阅目录下的落盘相对路径；content 为订阅内容。
+// 返回值：返回写入错误。
+func (h *SubscribeHandler) writeRemoteBackup(file string, content []byte) error {
+	filePath := filepath.Join(h.appConfig.Global.Storage.SubscriptionDir, file)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close backup file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to replace backup file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/pkg/provider/base"
+)
+
+const (
+	singBoxOutboundsKey = "outbounds"
+	singBoxTypeDirect   = "direct"
+	singBoxTypeSelector = "selector"
+)
+
+// singBoxOutbound 表示 sing-box outbound 中与注入逻辑相关的字段。
+// 字段含义：Type 为出站类型；Tag 为出站标签。
+type singBoxOutbound struct {
+	Type string `json:"type"`
+	Tag  string `json:"tag"`
+}
+
+// singBoxSelector 表示仅承载展示信息的 sing-box selector 出站，字段顺序即输出顺序。
+// 字段含义：Type 固定为 selector；Tag 为展示文案；Outbounds 为引用的已有出站标签。
+type singBoxSelector struct {
+	Type      string   `json:"type"`
+	Tag       string   `json:"tag"`
+	Outbounds []string `json:"outbounds"`
+}
+
+// jsonArraySpan 表示 JSON 文档中某个数组在原始字节里的位置。
+// 字段含义：open 为左方括号的下标；elems 为每个元素的起止下标（左闭右开）；raw 为元素原始内容。
+type jsonArraySpan struct {
+	open  int
+	elems [][2]int
+	raw   []json.RawMessage
+}
+
+// appendSingBoxUsageOutbounds 用于把流量信息组装成 selector 出站后插入 sing-box 配置的 outbounds 数组。
+// 直接在原始字节上拼接，不重新编码整份 JSON，以保留原有字段顺序、缩进和数字写法。
+// 参数含义：fileContent 为原始 sing-box 配置；apiInfo 为流量信息；usageDisplay 为展示格式配置。
+// 返回值：返回处理后的配置内容和处理错误。
+func appendSingBoxUsageOutbounds(fileContent []byte, apiInfo *base.APIResponseInfo, usageDisplay *config.UsageDisplayConfig) ([]byte, error) {
+	span, err := findJSONArray(fileContent, singBoxOutboundsKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(span.elems) == 0 {
+		return nil, errors.New("no outbounds found in config")
+	}
+
+	memberTag, usedTags, err := singBoxSelectorMember(span.raw)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := renderUsageLines(apiInfo, usageDisplay)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return fileContent, nil
+	}
+
+	unit, indent := detectJSONIndent(fileContent, span)
+	elems := make([][]byte, 0, len(lines))
+	for _, line := range lines {
+		// sing-box 要求出站标签唯一，展示文案与已有出站或其他展示行同名时追加序号。
+		tag, err := uniqueSingBoxTag(line, usedTags)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := encodeJSONElement(singBoxSelector{
+			Type:      singBoxTypeSelector,
+			Tag:       tag,
+			Outbounds: []string{memberTag},
+		}, unit, indent)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+
+	// sing-box 在未配置 route.final 时把第一个出站作为默认出站，
+	// 因此前置展示时插在第一个出站之后，避免所有流量被导向展示用的 selector。
+	insertAfter := span.elems[len(span.elems)-1][1]
+	if usageDisplay.Prepend {
+		insertAfter = span.elems[0][1]
+	}
+
+	var buffer bytes.Buffer
+	buffer.Grow(len(fileContent) + len(elems)*64)
+	buffer.Write(fileContent[:insertAfter])
+	for _, elem := range elems {
+		buffer.WriteByte(',')
+		buffer.WriteString(indent)
+		buffer.Write(elem)
+	}
+	buffer.Write(fileContent[insertAfter:])
+
+	return buffer.Bytes(), nil
+}
+
+// singBoxSelectorMember 用于挑选展示 selector 引用的出站标签，优先使用 direct 类型出站，同时收集已占用的出站标签。
+// 参数含义：outbounds 为原始 outbounds 数组元素。
+// 返回值：返回可被 selector 引用的出站标签与已占用的标签集合；不存在带标签的出站时返回错误。
+func singBoxSelectorMember(outbounds []json.RawMessage) (string, map[string]struct{}, error) {
+	var direct, fallback string
+	used := make(map[string]struct{}, len(outbounds))
+	for _, raw := range outbounds {
+		var outbound singBoxOutbound
+		if err := json.Unmarshal(raw, &outbound); err != nil {
+			return "", nil, fmt.Errorf("failed to read sing-box outbound: %w", err)
+		}
+		if outbound.Tag == "" {
+			continue
+		}
+		used[outbound.Tag] = struct{}{}
+		if outbound.Type == singBoxTypeDirect && direct == "" {
+			direct = outbound.Tag
+		}
+		if fallback == "" {
+			fallback = outbound.Tag
+		}
+	}
+
+	if fallback == "" {
+		return "", nil, errors.New("no tagged outbound found in config")
+	}
+
+	return cmp.Or(direct, fallback), used, nil
+}
+
+// uniqueSingBoxTag 用于为展示 selector 生成不与已有出站冲突的标签，冲突时与节点重名处理一致追加 -2、-3 等序号。
+// 参数含义：tag 为展示文案；used 为已占用的标签集合，生成的标签会写入其中。
+// 返回值：返回可用的标签；无法找到可用标签时返回错误。
+func uniqueSingBoxTag(tag string, used map[string]struct{}) (string, error) {
+	candidate := tag
+	for index := 2; index < maxMergeRenameAttempts; index++ {
+		if _, ok := used[candidate]; !ok {
+			used[candidate] = struct{}{}
+			return candidate, nil
+		}
+		candidate = tag + "-" + strconv.Itoa(index)
+	}
+
+	return "", fmt.Errorf("failed to rename duplicated outbound tag %q", tag)
+}
+
+// findJSONArray 用于定位 JSON 根对象中指定键对应数组的字节位置。
+// 参数含义：content 为完整 JSON 文档；key 为根对象中的键名。
+// 返回值：返回数组位置信息；文档不是对象、键不存在或值不是数组时返回错误。
+func findJSONArray(content []byte, key string) (jsonArraySpan, error) {
+	// 定位到目标数组后即停止解码，先整体校验一次，避免在语法错误的文档上拼接。
+	if !json.Valid(content) {
+		return jsonArraySpan{}, errors.New("failed to read json config: invalid json")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return jsonArraySpan{}, fmt.Errorf("failed to read json config: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return jsonArraySpan{}, errors.New("json root must be object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return jsonArraySpan{}, fmt.Errorf("failed to read json config: %w", err)
+		}
+
+		if name, _ := token.(string); name != key {
+			// 其他字段整体跳过，不关心其内部结构。
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return jsonArraySpan{}, fmt.Errorf("failed to read json config: %w", err)
+			}
+			continue
+		}
+
+		token, err = decoder.Token()
+		if err != nil {
+			return jsonArraySpan{}, fmt.Errorf("failed to read json config: %w", err)
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return jsonArraySpan{}, fmt.Errorf("%s must be array", key)
+		}
+
+		span := jsonArraySpan{open: int(decoder.InputOffset()) - 1}
+		for decoder.More() {
+			// InputOffset 停在上一个分隔符之后，需要跳过空白和逗号才是元素真正的起点。
+			start := skipJSONSeparators(content, int(decoder.InputOffset()))
+
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return jsonArraySpan{}, fmt.Errorf("failed to read json config: %w", err)
+			}
+
+			span.elems = append(span.elems, [2]int{start, int(decoder.InputOffset())})
+			span.raw = append(span.raw, raw)
+		}
+
+		return span, nil
+	}
+
+	return jsonArraySpan{}, fmt.Errorf("no %s found in config", key)
+}
+
+// skipJSONSeparators 用于从指定位置跳过 JSON 空白和逗号。
+// 参数含义：content 为完整 JSON 文档；offset 为起始下标。
+// 返回值：返回第一个非分隔符字符的下标。
+func skipJSONSeparators(content []byte, offset int) int {
+	for offset < len(content) {
+		switch content[offset] {
+		case ' ', '\t', '\r', '\n', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+
+	return offset
+}
+
+// detectJSONIndent 用于根据数组首个元素前的空白推断原文件的缩进与换行风格。
+// 参数含义：content 为完整 JSON 文档；span 为目标数组位置信息，要求至少包含一个元素。
+// 返回值：返回单级缩进字符串，以及新元素前需要写入的换行与缩进，原文件使用 CRLF 时换行同样为 CRLF；原文件为紧凑格式时分别返回空串和空格。
+func detectJSONIndent(content []byte, span jsonArraySpan) (string, string) {
+	leading := string(content[span.open+1 : span.elems[0][0]])
+	lineBreak := strings.LastIndexByte(leading, '\n')
+	if lineBreak < 0 {
+		return "", " "
+	}
+
+	newline := "\n"
+	if lineBreak > 0 && leading[lineBreak-1] == '\r' {
+		newline = "\r\n"
+	}
+	indent := newline + leading[lineBreak+1:]
+
+	// 数组所在行的前导空白即为上一层缩进，两者之差就是单级缩进。
+	lineStart := bytes.LastIndexByte(content[:span.open], '\n') + 1
+	outer := 0
+	for lineStart+outer < span.open && (content[lineStart+outer] == ' ' || content[lineStart+outer] == '\t') {
+		outer++
+	}
+
+	// 元素缩进不深于外层时无法推断单级缩进，退回两个空格。
+	unit := "  "
+	if len(indent)-len(newline) > outer {
+		unit = indent[len(newline)+outer:]
+	}
+
+	return unit, indent
+}
+
+// encodeJSONElement 用于按原文件缩进与换行风格编码待插入的数组元素，且不转义 HTML 字符。
+// 参数含义：value 为待编码的值；unit 为单级缩进，空串表示紧凑格式；indent 为元素所在行的换行与缩进。
+// 返回值：返回不带结尾换行的编码结果和编码错误。
+func encodeJSONElement(value any, unit string, indent string) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if unit != "" {
+		encoder.SetIndent(strings.TrimLeft(indent, "\r\n"), unit)
+	}
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, fmt.Errorf("failed to marshal json element: %w", err)
+	}
+
+	elem := bytes.TrimRight(buffer.Bytes(), "\n")
+	// 编码器只输出 LF，字符串中的换行会被转义，因此可以直接把结构换行替换为 CRLF。
+	if strings.HasPrefix(indent, "\r\n") {
+		elem = bytes.ReplaceAll(elem, []byte("\n"), []byte("\r\n"))
+	}
+
+	return elem, nil
+}
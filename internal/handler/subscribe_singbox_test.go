@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/pkg/provider/base"
+)
+
+const testSingBoxConfig = `{
+  "log": {"level": "warn"},
+  "outbounds": [
+    {
+      "type": "vless",
+      "tag": "proxy",
+      "server_port": 443
+    },
+    {
+      "type": "direct",
+      "tag": "direct"
+    }
+  ],
+  "route": {"final": "proxy"}
+}
+`
+
+// TestAppendSingBoxUsageOutbounds_AppendsSelectorsAndKeepsOriginalBytes 用于验证追加展示出站时原文件内容逐字节保留，并按原缩进写入 selector。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendSingBoxUsageOutbounds_AppendsSelectorsAndKeepsOriginalBytes(t *testing.T) {
+	t.Parallel()
+
+	usageDisplay := newTestUsageDisplayConfig()
+	usageDisplay.Prepend = false
+
+	updated, err := appendSingBoxUsageOutbounds([]byte(testSingBoxConfig), newTestSingBoxAPIInfo(), usageDisplay)
+	if err != nil {
+		t.Fatalf("appendSingBoxUsageOutbounds returned error: %v", err)
+	}
+
+	got := string(updated)
+	wantInsert := `,
+    {
+      "type": "selector",
+      "tag": "📅 重置日期 2026-04-13",
+      "outbounds": [
+        "direct"
+      ]
+    },`
+	if !strings.Contains(got, wantInsert) {
+		t.Fatalf("expected selector with original indentation, got: %s", got)
+	}
+
+	// 去掉新增片段后应与原文件完全一致，说明其余字节未被改写。
+	start := strings.Index(got, `,
+    {
+      "type": "selector"`)
+	end := strings.LastIndex(got, "\n  ],")
+	if start < 0 || end < 0 || got[:start]+got[end:] != testSingBoxConfig {
+		t.Fatalf("expected original bytes to be preserved, got: %s", got)
+	}
+
+	assertSingBoxOutboundTags(t, updated, []string{"proxy", "direct", "📅 重置日期 2026-04-13", "⛽ 已用流量 1G / 5G"})
+}
+
+// TestAppendSingBoxUsageOutbounds_KeepsDefaultOutboundWhenPrepend 用于验证前置展示时 selector 插在首个出站之后，不改变 sing-box 的默认出站。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendSingBoxUsageOutbounds_KeepsDefaultOutboundWhenPrepend(t *testing.T) {
+	t.Parallel()
+
+	updated, err := appendSingBoxUsageOutbounds([]byte(testSingBoxConfig), newTestSingBoxAPIInfo(), newTestUsageDisplayConfig())
+	if err != nil {
+		t.Fatalf("appendSingBoxUsageOutbounds returned error: %v", err)
+	}
+
+	assertSingBoxOutboundTags(t, updated, []string{"proxy", "📅 重置日期 2026-04-13", "⛽ 已用流量 1G / 5G", "direct"})
+}
+
+// TestAppendSingBoxUsageOutbounds_HandlesCompactJSON 用于验证单行紧凑 JSON 也能正确插入，且不转义 HTML 字符。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendSingBoxUsageOutbounds_HandlesCompactJSON(t *testing.T) {
+	t.Parallel()
+
+	usageDisplay := newTestUsageDisplayConfig()
+	usageDisplay.Prepend = false
+	usageDisplay.TrafficFormat = "<{{.used}}>"
+
+	content := `{"outbounds":[{"type":"vless","tag":"proxy"}]}`
+	updated, err := appendSingBoxUsageOutbounds([]byte(content), newTestSingBoxAPIInfo(), usageDisplay)
+	if err != nil {
+		t.Fatalf("appendSingBoxUsageOutbounds returned error: %v", err)
+	}
+
+	want := `{"outbounds":[{"type":"vless","tag":"proxy"}, {"type":"selector","tag":"📅 重置日期 2026-04-13","outbounds":["proxy"]}, {"type":"selector","tag":"<1G>","outbounds":["proxy"]}]}`
+	if string(updated) != want {
+		t.Fatalf("unexpected compact output:\n got: %s\nwant: %s", updated, want)
+	}
+}
+
+// TestAppendSingBoxUsageOutbounds_ReturnsErrorWhenOutboundsInvalid 用于验证非法 JSON、缺少 outbounds 或 outbounds 为空时返回错误。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendSingBoxUsageOutbounds_ReturnsErrorWhenOutboundsInvalid(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"invalid json":  `{"outbounds": [`,
+		"root array":    `[]`,
+		"missing":       `{"route": {}}`,
+		"not array":     `{"outbounds": {}}`,
+		"empty":         `{"outbounds": []}`,
+		"without a tag": `{"outbounds": [{"type": "direct"}]}`,
+	}
+
+	for name, content := range cases {
+		if _, err := appendSingBoxUsageOutbounds([]byte(content), newTestSingBoxAPIInfo(), newTestUsageDisplayConfig()); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+// TestAppendUsage_DispatchesByFormat 用于验证注入逻辑按路由订阅格式选择 Clash 或 sing-box 实现。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendUsage_DispatchesByFormat(t *testing.T) {
+	t.Parallel()
+
+	updated, err := appendUsage(config.FormatSingBox, []byte(testSingBoxConfig), newTestSingBoxAPIInfo(), newTestUsageDisplayConfig())
+	if err != nil {
+		t.Fatalf("appendUsage returned error: %v", err)
+	}
+	if !json.Valid(updated) {
+		t.Fatalf("expected valid json, got: %s", updated)
+	}
+
+	if _, err := appendUsage(config.FormatClash, []byte(testSingBoxConfig), newTestSingBoxAPIInfo(), newTestUsageDisplayConfig()); err == nil {
+		t.Fatalf("expected clash format to reject sing-box config without proxy-groups")
+	}
+}
+
+// newTestSingBoxAPIInfo 用于生成 sing-box 测试使用的流量信息。
+// 参数含义：无。
+// 返回值：返回已用 1G、总量 5G 的流量信息。
+func newTestSingBoxAPIInfo() *base.APIResponseInfo {
+	return &base.APIResponseInfo{
+		Upload:   512 * 1024 * 1024,
+		Download: 512 * 1024 * 1024,
+		Total:    5 * 1024 * 1024 * 1024,
+		ResetAt:  time.Date(2026, 4, 13, 8, 30, 0, 0, time.Local).Unix(),
+	}
+}
+
+// assertSingBoxOutboundTags 用于断言处理后的配置是合法 JSON，且出站标签顺序符合预期。
+// 参数含义：t 为测试上下文；content 为处理后的配置；want 为期望的标签顺序。
+// 返回值：无。
+func assertSingBoxOutboundTags(t *testing.T, content []byte, want []string) {
+	t.Helper()
+
+	var parsed struct {
+		Outbounds []singBoxOutbound `json:"outbounds"`
+	}
+	if err := json.Unmarshal(content, &parsed); err != nil {
+		t.Fatalf("expected valid json, got error %v: %s", err, content)
+	}
+
+	got := make([]string, 0, len(parsed.Outbounds))
+	for _, outbound := range parsed.Outbounds {
+		got = append(got, outbound.Tag)
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected outbound tags: got %v, want %v", got, want)
+	}
+}
+
+// TestAppendSingBoxUsageOutbounds_DeduplicatesTagsAndKeepsCRLF 用于验证展示文案与已有出站同名时追加序号，且 CRLF 换行的文件插入内容同样使用 CRLF。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendSingBoxUsageOutbounds_DeduplicatesTagsAndKeepsCRLF(t *testing.T) {
+	t.Parallel()
+
+	usageDisplay := newTestUsageDisplayConfig()
+	usageDisplay.Prepend = false
+	usageDisplay.Lines = []config.UsageLine{{Template: "direct"}, {Template: "direct"}}
+
+	content := strings.ReplaceAll(testSingBoxConfig, "\n", "\r\n")
+	updated, err := appendSingBoxUsageOutbounds([]byte(content), newTestSingBoxAPIInfo(), usageDisplay)
+	if err != nil {
+		t.Fatalf("appendSingBoxUsageOutbounds returned error: %v", err)
+	}
+
+	if strings.Count(string(updated), "\n") != strings.Count(string(updated), "\r\n") {
+		t.Fatalf("expected every inserted line to use CRLF, got: %q", updated)
+	}
+	assertSingBoxOutboundTags(t, updated, []string{"proxy", "direct", "direct-2", "direct-3"})
+}
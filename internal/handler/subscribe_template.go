@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/pkg/provider/base"
+)
+
+// renderSubscriptionTemplate 用于把订阅文件作为 Go 模板渲染，渲染发生在格式转换与流量注入之前。
+// 模板可引用 .vars（路由变量）、.path（路由路径）、.user_agent（客户端 User-Agent）与 .usage（与流量展示模板相同的数据，无流量信息时为 nil）。
+// 参数含义：c 为 Gin 上下文；conf 为当前路径配置；fileContent 为原始订阅内容；apiInfo 为流量信息，可以为 nil。
+// 返回值：返回渲染后的订阅内容和模板解析或执行错误。
+func renderSubscriptionTemplate(c *gin.Context, conf config.PathConfig, fileContent []byte, apiInfo *base.APIResponseInfo) ([]byte, error) {
+	vars := conf.Vars
+	if vars == nil {
+		vars = map[string]any{}
+	}
+
+	data := map[string]any{
+		"vars":       vars,
+		"path":       conf.Path,
+		"user_agent": c.GetHeader("User-Agent"),
+		"usage":      nil,
+	}
+	// .usage 与流量展示模板使用同一份数据，两处可引用的字段保持一致，date 同样按重置时区格式化。
+	var loc *time.Location
+	if apiInfo != nil {
+		data["usage"] = newUsageTemplateData(apiInfo, conf.UsageDisplay.FormatBytes, time.Now())
+		loc = apiInfo.ResetLocation
+	}
+
+	rendered, err := config.RenderVarsTemplate(string(fileContent), data, conf.UsageDisplay.TemplateFuncs(loc))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(rendered), nil
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRenderSubscriptionTemplate_UsesVarsUsageAndUserAgent 用于验证订阅模板可以引用路由变量、流量信息、客户端 User-Agent 与内置函数。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestRenderSubscriptionTemplate_UsesVarsUsageAndUserAgent(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	_, conf := newTestSubscribeHandler(t)
+	conf.Template = true
+	conf.Vars = map[string]any{"region": "hk", "dns": "1.1.1.1"}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/test.yaml", nil)
+	c.Request.Header.Set("User-Agent", "mihomo/1.18")
+
+	content := `# 剩余 {{.usage.remaining}}（{{.usage.percent}}%，下载 {{.usage.download}}），{{.usage.reset_date}} 重置
+dns:
+  nameserver:
+    - {{if contains "mihomo" .user_agent}}https://{{.vars.dns}}/dns-query{{else}}{{.vars.dns}}{{end}}
+proxies:
+  - name: {{upper .vars.region}}
+`
+	rendered, err := renderSubscriptionTemplate(c, conf, []byte(content), newTestSingBoxAPIInfo())
+	if err != nil {
+		t.Fatalf("renderSubscriptionTemplate returned error: %v", err)
+	}
+
+	want := `# 剩余 4G（20%，下载 0G），2026-04-13 重置
+dns:
+  nameserver:
+    - https://1.1.1.1/dns-query
+proxies:
+  - name: HK
+`
+	if string(rendered) != want {
+		t.Fatalf("unexpected rendered template:\n got: %q\nwant: %q", rendered, want)
+	}
+}
+
+// TestRenderSubscriptionTemplate_HandlesMissingUsageAndUnknownVars 用于验证无流量信息时 .usage 为空可按条件跳过，引用未定义变量时返回错误。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestRenderSubscriptionTemplate_HandlesMissingUsageAndUnknownVars(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	_, conf := newTestSubscribeHandler(t)
+	conf.Template = true
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/test.yaml", nil)
+
+	rendered, err := renderSubscriptionTemplate(c, conf, []byte("{{with .usage}}used {{.used}}{{else}}no usage{{end}}"), nil)
+	if err != nil {
+		t.Fatalf("renderSubscriptionTemplate returned error: %v", err)
+	}
+	if string(rendered) != "no usage" {
+		t.Fatalf("unexpected rendered template: %q", rendered)
+	}
+
+	if _, err := renderSubscriptionTemplate(c, conf, []byte("{{.vars.missing}}"), nil); err == nil {
+		t.Fatalf("expected error when template references an undefined var")
+	}
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/djx30103/vpsub/internal/config"
 	"github.com/djx30103/vpsub/internal/middleware"
+	"github.com/djx30103/vpsub/pkg/bytesize"
 	"github.com/djx30103/vpsub/pkg/log"
 	"github.com/djx30103/vpsub/pkg/pathutil"
 	"github.com/djx30103/vpsub/pkg/provider/base"
@@ -52,7 +53,7 @@ func TestGetProviderInfo_UsesCachedAPIWhenProviderFails(t *testing.T) {
 		Upload:   10,
 		Download: 20,
 		Total:    100,
-		Expire:   0,
+		ResetAt:  0,
 	}
 	handler.cache.Set("shared-provider", cachedAPI, time.Minute)
 
@@ -106,6 +107,120 @@ func TestGetProviderInfo_ReusesProviderLevelAPICache(t *testing.T) {
 	}
 }
 
+// TestGetProviderInfo_AppliesResetRuleWithoutMutatingCache 用于验证账号级重置规则会覆盖重置时间，且不会修改共享缓存中的原始数据。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGetProviderInfo_AppliesResetRuleWithoutMutatingCache(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	handler, conf := newTestSubscribeHandler(t)
+	conf.ProviderConfig.ResetDay = 17
+	conf.ProviderConfig.ResetLocation = shanghai
+
+	cachedAPI := &base.APIResponseInfo{
+		Download: 10,
+		Total:    100,
+		ResetAt:  1,
+	}
+	handler.cache.Set("shared-provider", cachedAPI, time.Minute)
+
+	apiInfo := handler.getProviderInfo(context.Background(), conf)
+	if apiInfo == nil || apiInfo == cachedAPI {
+		t.Fatalf("expected overridden copy of cached api info")
+	}
+	if cachedAPI.ResetAt != 1 {
+		t.Fatalf("expected cached api info to stay untouched, got reset at %d", cachedAPI.ResetAt)
+	}
+
+	resetAt := time.Unix(apiInfo.ResetAt, 0).In(shanghai)
+	if resetAt.Day() != 17 || resetAt.Hour() != 0 || !resetAt.After(time.Now()) {
+		t.Fatalf("expected next reset on day 17 in Asia/Shanghai, got %s", resetAt)
+	}
+
+	got, err := config.RenderTemplate("{{.day}}", newUsageTemplateData(apiInfo, (&config.UsageDisplayConfig{TrafficUnit: "G"}).FormatBytes, time.Now()))
+	if err != nil {
+		t.Fatalf("RenderTemplate returned error: %v", err)
+	}
+	if got != "17" {
+		t.Fatalf("expected reset day rendered in reset timezone, got %s", got)
+	}
+
+	// date 与 .day 使用同一时区，服务本地时区不同时也不会相差一天。
+	lines, err := renderUsageLines(apiInfo, &config.UsageDisplayConfig{
+		TrafficUnit: "G",
+		Lines:       []config.UsageLine{{Template: `{{date "02" .reset_at}}`}},
+	})
+	if err != nil || len(lines) != 1 || lines[0] != "17" {
+		t.Fatalf("expected date rendered in reset timezone, got %v, %v", lines, err)
+	}
+}
+
+// TestGetProviderInfo_AppliesServiceExpireAt 用于验证账号级服务到期时间会写入流量信息，并作为 expire 返回给客户端。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGetProviderInfo_AppliesServiceExpireAt(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestSubscribeHandler(t)
+	conf.ProviderConfig.ServiceExpireAt = 1798761600
+	conf.ExpireField = config.ExpireFieldServiceExpireAt
+
+	cachedAPI := &base.APIResponseInfo{
+		Download: 10,
+		Total:    100,
+		ResetAt:  1,
+	}
+	handler.cache.Set("shared-provider", cachedAPI, time.Minute)
+
+	apiInfo := handler.getProviderInfo(context.Background(), conf)
+	if apiInfo == nil || apiInfo.ServiceExpireAt != 1798761600 {
+		t.Fatalf("expected service expire at override, got %+v", apiInfo)
+	}
+	if cachedAPI.ServiceExpireAt != 0 {
+		t.Fatalf("expected cached api info to stay untouched, got %d", cachedAPI.ServiceExpireAt)
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	handler.writeSubscriptionResponse(c, conf, []byte("mixed-port: 7890\n"), apiInfo)
+
+	if got, want := recorder.Header().Get("Subscription-Userinfo"), "upload=0; download=10; total=100; expire=1798761600"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+// TestGetProviderInfo_AppliesTotal 用于验证账号手动指定的流量总量会覆盖服务商返回的总量，且不修改缓存中的原始数据。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGetProviderInfo_AppliesTotal(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestSubscribeHandler(t)
+	conf.ProviderConfig.Total = 2 * bytesize.TB
+
+	cachedAPI := &base.APIResponseInfo{Download: 10, Total: 100, ResetAt: 1}
+	handler.cache.Set("shared-provider", cachedAPI, time.Minute)
+
+	apiInfo := handler.getProviderInfo(context.Background(), conf)
+	if apiInfo == nil || apiInfo.Total != 2*bytesize.TB || apiInfo.Download != 10 {
+		t.Fatalf("expected total override, got %+v", apiInfo)
+	}
+	if cachedAPI.Total != 100 {
+		t.Fatalf("expected cached api info to stay untouched, got %d", cachedAPI.Total)
+	}
+}
+
 // TestGetProviderInfo_ReturnsNilWhenProviderFailsWithoutCache 用于验证上游接口失败且无缓存时不会返回流量信息。
 // 参数含义：t 为测试上下文。
 // 返回值：无。
@@ -131,6 +246,34 @@ func TestGetProviderInfo_ReturnsNilWhenProviderFailsWithoutCache(t *testing.T) {
 	}
 }
 
+// TestGetProviderClient_ReusesClientPerProviderRef 用于验证同一 provider_ref 的服务商客户端只创建一次，后续请求直接复用。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGetProviderClient_ReusesClientPerProviderRef(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestSubscribeHandler(t)
+	conf.ProviderType = "racknerd"
+	conf.APIID = "hash"
+	conf.APIKey = "key"
+
+	first, err := handler.getProviderClient(conf)
+	if err != nil {
+		t.Fatalf("getProviderClient returned error: %v", err)
+	}
+
+	second, err := handler.getProviderClient(conf)
+	if err != nil {
+		t.Fatalf("getProviderClient returned error: %v", err)
+	}
+
+	if first != second {
+		t.Fatalf("expected provider client to be reused for the same provider_ref")
+	}
+}
+
 // TestWriteSubscriptionResponse_OmitsUsageHeadersWithoutAPI 用于验证没有流量信息时仅返回文件内容，不写入流量相关响应头。
 // 参数含义：t 为测试上下文。
 // 返回值：无。
@@ -162,6 +305,149 @@ func TestWriteSubscriptionResponse_OmitsUsageHeadersWithoutAPI(t *testing.T) {
 	}
 }
 
+// TestWriteSubscriptionResponse_UsesConfiguredExpireField 用于验证 expire 默认取流量重置时间，并可按路由切换为服务到期时间。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestWriteSubscriptionResponse_UsesConfiguredExpireField(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	handler, conf := newTestSubscribeHandler(t)
+	apiInfo := &base.APIResponseInfo{
+		Upload:          1,
+		Download:        2,
+		Total:           3,
+		ResetAt:         1711929600,
+		ServiceExpireAt: 1735660800,
+	}
+
+	cases := map[string]string{
+		"":                                "upload=1; download=2; total=3; expire=1711929600",
+		config.ExpireFieldResetAt:         "upload=1; download=2; total=3; expire=1711929600",
+		config.ExpireFieldServiceExpireAt: "upload=1; download=2; total=3; expire=1735660800",
+	}
+
+	for expireField, want := range cases {
+		conf.ExpireField = expireField
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+
+		handler.writeSubscriptionResponse(c, conf, []byte("mixed-port: 7890\n"), apiInfo)
+
+		if got := recorder.Header().Get("Subscription-Userinfo"); got != want {
+			t.Fatalf("expire_field %q: expected %q, got %q", expireField, want, got)
+		}
+	}
+}
+
+// TestAppendUsageGroups_RendersServiceExpireDate 用于验证重置时间模板可以同时引用服务到期日期。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendUsageGroups_RendersServiceExpireDate(t *testing.T) {
+	t.Parallel()
+
+	setupSubscribeTestMode()
+
+	usageDisplay := newTestUsageDisplayConfig()
+	usageDisplay.ResetTimeFormat = "📅 {{.month}}-{{.day}}{{if .service_year}} 到期 {{.service_year}}-{{.service_month}}-{{.service_day}}{{end}}"
+
+	fileContent := []byte("proxy-groups:\n  - name: 原分组\n    type: select\n    proxies:\n      - REJECT\n")
+	apiInfo := &base.APIResponseInfo{
+		Total:           5 * 1024 * 1024 * 1024,
+		ResetAt:         time.Date(2026, 4, 13, 8, 30, 0, 0, time.Local).Unix(),
+		ServiceExpireAt: time.Date(2027, 1, 2, 8, 30, 0, 0, time.Local).Unix(),
+	}
+
+	updated, err := appendUsageGroups(fileContent, apiInfo, usageDisplay)
+	if err != nil {
+		t.Fatalf("appendUsageGroups returned error: %v", err)
+	}
+	if got := string(updated); !strings.Contains(got, "📅 04-13 到期 2027-01-02") {
+		t.Fatalf("expected service expire date in group name, got: %s", got)
+	}
+
+	apiInfo.ServiceExpireAt = 0
+	updated, err = appendUsageGroups(fileContent, apiInfo, usageDisplay)
+	if err != nil {
+		t.Fatalf("appendUsageGroups returned error: %v", err)
+	}
+	if got := string(updated); strings.Contains(got, "到期") {
+		t.Fatalf("expected service expire date to be omitted when unknown, got: %s", got)
+	}
+}
+
+// TestNewUsageTemplateData_ComputesDerivedFields 用于验证展示数据会计算剩余流量、百分比、距离重置天数与重置时区下的时分和星期。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestNewUsageTemplateData_ComputesDerivedFields(t *testing.T) {
+	t.Parallel()
+
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	const gib = 1024 * 1024 * 1024
+	apiInfo := &base.APIResponseInfo{
+		Upload:        1 * gib,
+		Download:      2 * gib,
+		Total:         8 * gib,
+		ResetAt:       time.Date(2026, 4, 13, 9, 5, 0, 0, shanghai).Unix(),
+		ResetLocation: shanghai,
+	}
+	now := time.Date(2026, 4, 10, 12, 0, 0, 0, shanghai)
+
+	data := newUsageTemplateData(apiInfo, (&config.UsageDisplayConfig{TrafficUnit: "G"}).FormatBytes, now)
+	if data["percent"] != 37.5 || data["days_left"] != 3 {
+		t.Fatalf("unexpected percent or days_left: %v %v", data["percent"], data["days_left"])
+	}
+	if data["remaining"] != bytesize.Format(5*gib, "G") || data["upload"] != bytesize.Format(1*gib, "G") {
+		t.Fatalf("unexpected traffic fields: %v", data)
+	}
+	if data["hour"] != "09" || data["minute"] != "05" || data["weekday"] != "周一" {
+		t.Fatalf("unexpected reset time fields: %v", data)
+	}
+
+	if got := newUsageTemplateData(apiInfo, (&config.UsageDisplayConfig{TrafficUnit: "G"}).FormatBytes, now.AddDate(0, 1, 0))["days_left"]; got != 0 {
+		t.Fatalf("expected days_left to be 0 after reset, got %v", got)
+	}
+}
+
+// TestRenderUsageLines_RendersConditionalLines 用于验证配置 lines 后只渲染满足条件的展示行，全部不满足时不注入任何分组。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestRenderUsageLines_RendersConditionalLines(t *testing.T) {
+	t.Parallel()
+
+	usageDisplay := newTestUsageDisplayConfig()
+	usageDisplay.Lines = []config.UsageLine{
+		{Template: "剩余 {{humanBytes .remaining_bytes}}"},
+		{Template: "⚠️ 流量即将用尽", When: "percent >= 80"},
+		{Template: "到期 {{.service_year}}-{{.service_month}}-{{.service_day}}", When: "service_expire_at > 0"},
+	}
+	apiInfo := &base.APIResponseInfo{
+		Download: 9 * bytesize.GB,
+		Total:    10 * bytesize.GB,
+		ResetAt:  time.Now().Add(48 * time.Hour).Unix(),
+	}
+
+	lines, err := renderUsageLines(apiInfo, usageDisplay)
+	if err != nil {
+		t.Fatalf("renderUsageLines returned error: %v", err)
+	}
+	if strings.Join(lines, "|") != "剩余 1G|⚠️ 流量即将用尽" {
+		t.Fatalf("unexpected usage lines: %q", lines)
+	}
+
+	usageDisplay.Lines = []config.UsageLine{{Template: "⚠️ 流量即将用尽", When: "percent >= 95"}}
+	fileContent := []byte("proxy-groups:\n  - name: Proxy\n    type: select\n    proxies:\n      - DIRECT\n")
+	updated, err := appendUsageGroups(fileContent, apiInfo, usageDisplay)
+	if err != nil || string(updated) != string(fileContent) {
+		t.Fatalf("expected content unchanged when no line matches, got %q, %v", updated, err)
+	}
+}
+
 // TestGet_RejectsRequestWhenUserAgentDoesNotMatch 用于验证命中路由但 UA 不匹配时会直接拒绝访问。
 // 参数含义：t 为测试上下文。
 // 返回值：无。
@@ -171,9 +457,7 @@ func TestGet_RejectsRequestWhenUserAgentDoesNotMatch(t *testing.T) {
 	setupSubscribeTestMode()
 
 	handler, conf := newTestSubscribeHandler(t)
-	conf.AccessControl = &config.AccessControlConfig{
-		UserAgent: "ClashX",
-	}
+	conf.UserAgent, _ = config.NewUserAgentMatcher(config.UserAgentRule{Exact: "ClashX"})
 	handler.appConfig.PathToConfig = map[string]config.PathConfig{
 		"/test.yaml": conf,
 	}
@@ -234,9 +518,7 @@ func TestServeHTTP_EmitsInfoLogWhenUserAgentRejected(t *testing.T) {
 	setupSubscribeTestMode()
 
 	handler, conf := newObservedSubscribeHandler(t, zap.InfoLevel)
-	conf.AccessControl = &config.AccessControlConfig{
-		UserAgent: "ClashX",
-	}
+	conf.UserAgent, _ = config.NewUserAgentMatcher(config.UserAgentRule{Exact: "ClashX"})
 	handler.appConfig.PathToConfig = map[string]config.PathConfig{
 		"/test.yaml": conf,
 	}
@@ -268,7 +550,7 @@ func TestServeHTTP_EmitsInfoLogWhenUserAgentRejected(t *testing.T) {
 	}
 }
 
-// TestReadSubscriptionFile_UsesCachedContentUntilFileChanges 用于验证订阅文件会按 path 缓存，并在文件变更后自动刷新。
+// TestReadSubscriptionFile_UsesCachedContentUntilFileChanges 用于验证订阅文件会按文件缓存，并在文件变更后自动刷新。
 // 参数含义：t 为测试上下文。
 // 返回值：无。
 func TestReadSubscriptionFile_UsesCachedContentUntilFileChanges(t *testing.T) {
@@ -357,7 +639,7 @@ func TestAppendUsageGroups_ExecutesTemplateSyntax(t *testing.T) {
 		Upload:   0,
 		Download: 2 * 1024 * 1024 * 1024,
 		Total:    5 * 1024 * 1024 * 1024,
-		Expire:   time.Date(2026, 4, 13, 8, 30, 0, 0, time.Local).Unix(),
+		ResetAt:  time.Date(2026, 4, 13, 8, 30, 0, 0, time.Local).Unix(),
 	}
 
 	updated, err := appendUsageGroups(fileContent, apiInfo, conf.UsageDisplay)
@@ -692,7 +974,7 @@ func TestAppendUsageGroups_KeepsExpireGroupBeforeTrafficGroup(t *testing.T) {
 		Upload:   0,
 		Download: 2 * 1024 * 1024 * 1024,
 		Total:    5 * 1024 * 1024 * 1024,
-		Expire:   time.Date(2026, 4, 13, 8, 30, 0, 0, time.Local).Unix(),
+		ResetAt:  time.Date(2026, 4, 13, 8, 30, 0, 0, time.Local).Unix(),
 	}
 
 	updated, err := appendUsageGroups(fileContent, apiInfo, newTestUsageDisplayConfig())
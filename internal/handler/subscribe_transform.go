@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/pkg/region"
+)
+
+// transformRenameSuffix 为节点处理后名称重复时追加的后缀模板，与多文件合并的默认后缀保持一致。
+const transformRenameSuffix = "-{{.index}}"
+
+// transformNode 表示节点处理流水线中的一个节点。
+// 字段含义：item 为节点的映射节点；original 为原始名称；name 为当前名称；nodeType 为节点类型；server 为服务器地址。
+type transformNode struct {
+	item     *yaml.Node
+	original string
+	name     string
+	nodeType string
+	server   string
+}
+
+// applyNodeTransforms 用于按路由配置的节点处理步骤筛选、重命名与排序 Clash 订阅中的节点，并同步改写分组成员。
+// 被移除的节点会从所有分组中删除，被重命名的节点在分组中同步改名；执行过排序步骤时，选择类分组内的节点成员按排序后的顺序重新排列。
+// 参数含义：content 为 Clash 订阅内容；transforms 为编译后的节点处理步骤。
+// 返回值：返回处理后的订阅内容和解析、模板渲染或编码错误。
+func applyNodeTransforms(content []byte, transforms []config.NodeTransform) ([]byte, error) {
+	if len(transforms) == 0 {
+		return content, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("failed to read yaml config: %w", err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("yaml root must be mapping")
+	}
+
+	proxies, err := findTopLevelSequence(root.Content[0], "proxies")
+	if err != nil {
+		return nil, err
+	}
+	groups, err := findTopLevelSequence(root.Content[0], "proxy-groups")
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*transformNode, 0, len(sequenceItems(proxies)))
+	for _, item := range sequenceItems(proxies) {
+		nameNode := mappingValue(item, "name")
+		if nameNode == nil {
+			continue
+		}
+		node := &transformNode{item: item, original: nameNode.Value, name: nameNode.Value}
+		if typeNode := mappingValue(item, "type"); typeNode != nil {
+			node.nodeType = typeNode.Value
+		}
+		if serverNode := mappingValue(item, "server"); serverNode != nil {
+			node.server = serverNode.Value
+		}
+		nodes = append(nodes, node)
+	}
+
+	sorted := false
+	for _, transform := range transforms {
+		nodes, err = applyNodeTransform(nodes, transform)
+		if err != nil {
+			return nil, err
+		}
+		sorted = sorted || transform.Op == config.TransformSort
+	}
+
+	// 分组成员引用的是原始名称，被移除的节点同样按原始名称记录。
+	removed := make(map[string]struct{})
+	for _, item := range sequenceItems(proxies) {
+		if nameNode := mappingValue(item, "name"); nameNode != nil {
+			removed[nameNode.Value] = struct{}{}
+		}
+	}
+	for _, node := range nodes {
+		delete(removed, node.original)
+	}
+
+	// 分组名称与节点共用命名空间，先占用分组名称，避免节点被改成与分组同名。
+	used := make(map[string]struct{})
+	for _, group := range sequenceItems(groups) {
+		if nameNode := mappingValue(group, "name"); nameNode != nil {
+			used[nameNode.Value] = struct{}{}
+		}
+	}
+
+	finalNames := make(map[string]string, len(nodes))
+	order := make(map[string]int, len(nodes))
+	kept := make([]*yaml.Node, 0, len(nodes))
+	for i, node := range nodes {
+		nameNode := mappingValue(node.item, "name")
+		nameNode.Value = node.name
+		if err := renameOnConflict(node.item, "", transformRenameSuffix, used, map[string]string{}); err != nil {
+			return nil, err
+		}
+		if _, ok := finalNames[node.original]; !ok {
+			finalNames[node.original] = nameNode.Value
+		}
+		order[nameNode.Value] = i
+		kept = append(kept, node.item)
+	}
+
+	if proxies != nil {
+		proxies.Content = kept
+	}
+	for _, item := range kept {
+		// 链式代理引用其他节点的名称，需与分组成员一起同步。
+		if dialer := mappingValue(item, "dialer-proxy"); dialer != nil {
+			if renamed, ok := finalNames[dialer.Value]; ok {
+				dialer.Value = renamed
+			}
+		}
+	}
+	// 没有排序步骤时分组成员保持原有顺序，筛选与重命名不应改变用户在分组中安排的优先级。
+	if !sorted {
+		order = nil
+	}
+	for _, group := range sequenceItems(groups) {
+		rewriteTransformedGroup(group, finalNames, removed, order)
+	}
+
+	return encodeYAMLDocument(&root)
+}
+
+// applyNodeTransform 用于执行单个节点处理步骤。
+// 参数含义：nodes 为当前节点列表；transform 为处理步骤。
+// 返回值：返回处理后的节点列表和前后缀模板渲染错误。
+func applyNodeTransform(nodes []*transformNode, transform config.NodeTransform) ([]*transformNode, error) {
+	switch transform.Op {
+	case config.TransformInclude, config.TransformExclude:
+		include := transform.Op == config.TransformInclude
+		filtered := nodes[:0]
+		for _, node := range nodes {
+			if transform.Matches(node.name, node.nodeType) == include {
+				filtered = append(filtered, node)
+			}
+		}
+		return filtered, nil
+	case config.TransformRename:
+		for _, node := range nodes {
+			// 替换结果为空时保留原名称，避免生成无名节点。
+			if renamed := transform.Name.ReplaceAllString(node.name, transform.Replace); renamed != "" {
+				node.name = renamed
+			}
+		}
+		return nodes, nil
+	case config.TransformPrefix, config.TransformSuffix:
+		for _, node := range nodes {
+			code := region.Detect(node.name, node.server)
+			// 名称已以旗帜开头时不再追加旗帜，避免重复执行或源订阅自带旗帜时出现两个旗帜。
+			flag := region.Flag(code)
+			if region.HasLeadingFlag(node.name) {
+				flag = ""
+			}
+			affix, err := config.ExecuteTemplate(transform.Affix, map[string]string{
+				"flag":   flag,
+				"region": code,
+				"type":   node.nodeType,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to render node %s: %w", transform.Op, err)
+			}
+			// 无法识别地区时旗帜为空，去掉首尾空白，避免名称以分隔用的空格开头或结尾。
+			if transform.Op == config.TransformPrefix {
+				node.name = strings.TrimSpace(affix + node.name)
+			} else {
+				node.name = strings.TrimSpace(node.name + affix)
+			}
+		}
+		return nodes, nil
+	case config.TransformSort:
+		sortTransformNodes(nodes, transform.SortBy)
+		return nodes, nil
+	default:
+		return nodes, nil
+	}
+}
+
+// sortTransformNodes 用于按名称或地区稳定排序节点，按地区排序时无法识别地区的节点排在最后，同地区内保持原有顺序。
+// 参数含义：nodes 为节点列表；sortBy 为排序方式。
+// 返回值：无。
+func sortTransformNodes(nodes []*transformNode, sortBy string) {
+	if sortBy == config.SortByName {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].name < nodes[j].name
+		})
+		return
+	}
+
+	regions := make(map[*transformNode]string, len(nodes))
+	for _, node := range nodes {
+		regions[node] = region.Detect(node.name, node.server)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		left, right := regions[nodes[i]], regions[nodes[j]]
+		if left == "" || right == "" {
+			return left != "" && right == ""
+		}
+		return left < right
+	})
+}
+
+// rewriteTransformedGroup 用于同步分组成员：删除被移除的节点、改写被重命名的节点，并在提供排序结果时按其重排节点成员。
+// 非节点成员（其他分组、DIRECT 等）保持原位置；fallback、load-balance 与 relay 分组的成员顺序决定优先级、哈希分配或链路，不参与重排。
+// 分组因此变空且没有 use 或 include-all 时补充 REJECT，保证配置仍然合法。
+// 参数含义：group 为分组映射节点；finalNames 为原名称到最终名称的映射；removed 为被移除的原名称集合；order 为最终名称到节点顺序的映射，为 nil 时不重排。
+// 返回值：无。
+func rewriteTransformedGroup(group *yaml.Node, finalNames map[string]string, removed map[string]struct{}, order map[string]int) {
+	members := mappingValue(group, "proxies")
+	if members == nil || members.Kind != yaml.SequenceNode {
+		return
+	}
+
+	hadMembers := len(members.Content) > 0
+	kept := make([]*yaml.Node, 0, len(members.Content))
+	var nodeSlots []int
+	var nodeMembers []*yaml.Node
+	for _, member := range members.Content {
+		if member.Kind != yaml.ScalarNode {
+			kept = append(kept, member)
+			continue
+		}
+		if renamed, ok := finalNames[member.Value]; ok {
+			member.Value = renamed
+			nodeSlots = append(nodeSlots, len(kept))
+			nodeMembers = append(nodeMembers, member)
+		} else if _, ok := removed[member.Value]; ok {
+			continue
+		}
+		kept = append(kept, member)
+	}
+
+	if order != nil && !orderSensitiveGroup(group) {
+		sort.SliceStable(nodeMembers, func(i, j int) bool {
+			return order[nodeMembers[i].Value] < order[nodeMembers[j].Value]
+		})
+		for i, slot := range nodeSlots {
+			kept[slot] = nodeMembers[i]
+		}
+	}
+
+	if hadMembers && len(kept) == 0 && mappingValue(group, "use") == nil && mappingValue(group, "include-all") == nil {
+		kept = append(kept, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "REJECT"})
+	}
+	members.Content = kept
+}
+
+// orderSensitiveGroup 用于判断分组的成员顺序是否具有语义，fallback 按顺序选择可用节点，load-balance 按成员顺序分配哈希，relay 按顺序串联链路。
+// 参数含义：group 为分组映射节点。
+// 返回值：成员顺序具有语义时返回 true。
+func orderSensitiveGroup(group *yaml.Node) bool {
+	typeNode := mappingValue(group, "type")
+	if typeNode == nil {
+		return false
+	}
+	switch strings.ToLower(typeNode.Value) {
+	case "fallback", "load-balance", "relay":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djx30103/vpsub/internal/config"
+)
+
+const testTransformSubscription = `proxies:
+  - name: Traffic Left 10GB
+    type: ss
+    server: info.example.com
+  - name: US 01
+    type: vmess
+    server: us.example.com
+  - name: node-a
+    type: trojan
+    server: edge.example.jp
+  - name: HK 01
+    type: ss
+    server: hk.example.com
+  - name: Chain
+    type: ss
+    server: 1.2.3.4
+    dialer-proxy: HK 01
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies:
+      - DIRECT
+      - Traffic Left 10GB
+      - US 01
+      - node-a
+      - HK 01
+  - name: Info
+    type: select
+    proxies:
+      - Traffic Left 10GB
+rules:
+  - MATCH,Proxy
+`
+
+// TestApplyNodeTransforms_KeepsGroupsConsistent 用于验证节点筛选、重命名、加旗帜与排序后，分组成员与链式代理引用同步更新，且旗帜不会重复追加。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestApplyNodeTransforms_KeepsGroupsConsistent(t *testing.T) {
+	t.Parallel()
+
+	transforms := []config.NodeTransform{
+		{Op: config.TransformExclude, Name: regexp.MustCompile(`(?i)traffic`)},
+		{Op: config.TransformRename, Name: regexp.MustCompile(`^node-(\w+)$`), Replace: "Node ${1}"},
+		{Op: config.TransformPrefix, Affix: "{{.flag}} "},
+		// 重复加旗帜时名称已以旗帜开头，不会出现两个旗帜。
+		{Op: config.TransformPrefix, Affix: "{{.flag}} "},
+		{Op: config.TransformInclude, Types: []string{"ss", "vmess", "trojan"}},
+		{Op: config.TransformSort, SortBy: config.SortByRegion},
+	}
+
+	content, err := applyNodeTransforms([]byte(testTransformSubscription), transforms)
+	if err != nil {
+		t.Fatalf("applyNodeTransforms returned error: %v", err)
+	}
+
+	var got mergedTestSubscription
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatalf("failed to parse transformed subscription: %v\n%s", err, content)
+	}
+
+	var names []string
+	for _, proxy := range got.Proxies {
+		names = append(names, proxy.Name)
+	}
+	if want := "🇭🇰 HK 01,🇯🇵 Node a,🇺🇸 US 01,Chain"; strings.Join(names, ",") != want {
+		t.Fatalf("unexpected proxy names: %v", names)
+	}
+
+	if members := strings.Join(got.ProxyGroups[0].Proxies, ","); members != "DIRECT,🇭🇰 HK 01,🇯🇵 Node a,🇺🇸 US 01" {
+		t.Fatalf("unexpected Proxy group members: %s", members)
+	}
+	if members := strings.Join(got.ProxyGroups[1].Proxies, ","); members != "REJECT" {
+		t.Fatalf("expected emptied group to fall back to REJECT, got %s", members)
+	}
+
+	var chain struct {
+		Proxies []struct {
+			DialerProxy string `yaml:"dialer-proxy"`
+		} `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(content, &chain); err != nil || chain.Proxies[3].DialerProxy != "🇭🇰 HK 01" {
+		t.Fatalf("expected dialer-proxy to follow rename, got:\n%s", content)
+	}
+}
+
+// TestApplyNodeTransforms_DeduplicatesRenamedNodes 用于验证重命名后出现重复名称时会追加序号，且不会与分组同名。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestApplyNodeTransforms_DeduplicatesRenamedNodes(t *testing.T) {
+	t.Parallel()
+
+	transforms := []config.NodeTransform{
+		{Op: config.TransformRename, Name: regexp.MustCompile(`^.*$`), Replace: "Proxy"},
+	}
+
+	content, err := applyNodeTransforms([]byte(testTransformSubscription), transforms)
+	if err != nil {
+		t.Fatalf("applyNodeTransforms returned error: %v", err)
+	}
+
+	var got mergedTestSubscription
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatalf("failed to parse transformed subscription: %v\n%s", err, content)
+	}
+
+	var names []string
+	for _, proxy := range got.Proxies {
+		names = append(names, proxy.Name)
+	}
+	if want := "Proxy-2,Proxy-3,Proxy-4,Proxy-5,Proxy-6"; strings.Join(names, ",") != want {
+		t.Fatalf("unexpected proxy names: %v", names)
+	}
+	if members := strings.Join(got.ProxyGroups[0].Proxies, ","); members != "DIRECT,Proxy-2,Proxy-3,Proxy-4,Proxy-5" {
+		t.Fatalf("unexpected Proxy group members: %s", members)
+	}
+}
+
+// TestApplyNodeTransforms_KeepsMemberOrderWithoutSort 用于验证只有执行排序步骤时才重排分组成员，且 fallback 分组的优先级顺序始终保持不变。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestApplyNodeTransforms_KeepsMemberOrderWithoutSort(t *testing.T) {
+	t.Parallel()
+
+	const subscription = `proxies:
+  - name: A
+    type: ss
+    server: a.example.com
+  - name: B
+    type: ss
+    server: b.example.com
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies:
+      - B
+      - A
+  - name: Backup
+    type: fallback
+    proxies:
+      - B
+      - A
+`
+
+	tests := []struct {
+		name         string
+		transforms   []config.NodeTransform
+		wantSelect   string
+		wantFallback string
+	}{
+		{
+			name:         "rename only",
+			transforms:   []config.NodeTransform{{Op: config.TransformRename, Name: regexp.MustCompile(`^(\w)$`), Replace: "Node ${1}"}},
+			wantSelect:   "Node B,Node A",
+			wantFallback: "Node B,Node A",
+		},
+		{
+			name:         "include only",
+			transforms:   []config.NodeTransform{{Op: config.TransformInclude, Types: []string{"ss"}}},
+			wantSelect:   "B,A",
+			wantFallback: "B,A",
+		},
+		{
+			name:         "sort by name",
+			transforms:   []config.NodeTransform{{Op: config.TransformSort, SortBy: config.SortByName}},
+			wantSelect:   "A,B",
+			wantFallback: "B,A",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			content, err := applyNodeTransforms([]byte(subscription), tt.transforms)
+			if err != nil {
+				t.Fatalf("applyNodeTransforms returned error: %v", err)
+			}
+
+			var got mergedTestSubscription
+			if err := yaml.Unmarshal(content, &got); err != nil {
+				t.Fatalf("failed to parse transformed subscription: %v\n%s", err, content)
+			}
+			if members := strings.Join(got.ProxyGroups[0].Proxies, ","); members != tt.wantSelect {
+				t.Fatalf("unexpected select group members: %s", members)
+			}
+			// fallback 分组按顺序决定优先级，任何处理步骤都不应颠倒。
+			if members := strings.Join(got.ProxyGroups[1].Proxies, ","); members != tt.wantFallback {
+				t.Fatalf("unexpected fallback group members: %s", members)
+			}
+		})
+	}
+}
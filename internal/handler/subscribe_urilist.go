@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/djx30103/vpsub/internal/config"
+	"github.com/djx30103/vpsub/internal/convert"
+	"github.com/djx30103/vpsub/pkg/provider/base"
+)
+
+// uriListPlaceholderPrefix 为展示用伪节点的固定前缀，指向本机 1 端口，客户端误选时也不会产生外部连接。
+const uriListPlaceholderPrefix = "trojan://vpsub@127.0.0.1:1#"
+
+// appendURIListUsageNodes 用于把流量信息组装成伪节点后写回分享链接列表。
+// 原文件为 base64 编码时按标准 base64 重新编码，原文件为明文列表时保持明文输出。
+// 参数含义：fileContent 为原始订阅文件内容；apiInfo 为流量信息；usageDisplay 为展示格式配置。
+// 返回值：返回处理后的订阅内容和处理错误。
+func appendURIListUsageNodes(fileContent []byte, apiInfo *base.APIResponseInfo, usageDisplay *config.UsageDisplayConfig) ([]byte, error) {
+	plain, encoded, err := convert.DecodeURIList(fileContent)
+	if err != nil {
+		return nil, err
+	}
+
+	lineBreak := "\n"
+	if bytes.Contains(plain, []byte("\r\n")) {
+		lineBreak = "\r\n"
+	}
+
+	body := strings.TrimRight(string(plain), "\r\n")
+	if strings.TrimSpace(body) == "" {
+		return nil, errors.New("no proxies found in config")
+	}
+
+	lines, err := renderUsageLines(apiInfo, usageDisplay)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return fileContent, nil
+	}
+
+	nodes := make([]string, 0, len(lines))
+	for _, line := range lines {
+		nodes = append(nodes, newURIListPlaceholder(line))
+	}
+
+	var result string
+	if usageDisplay.Prepend {
+		result = strings.Join(nodes, lineBreak) + lineBreak + body + lineBreak
+	} else {
+		result = body + lineBreak + strings.Join(nodes, lineBreak) + lineBreak
+	}
+
+	if !encoded {
+		return []byte(result), nil
+	}
+
+	return []byte(base64.StdEncoding.EncodeToString([]byte(result))), nil
+}
+
+// newURIListPlaceholder 用于生成以展示文案命名的伪节点分享链接。
+// 参数含义：name 为展示文案，会按 URI fragment 规则转义。
+// 返回值：返回完整的伪节点分享链接。
+func newURIListPlaceholder(name string) string {
+	return uriListPlaceholderPrefix + (&url.URL{Fragment: name}).EscapedFragment()
+}
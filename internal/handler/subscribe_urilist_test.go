@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/djx30103/vpsub/internal/config"
+)
+
+const testURIList = "vless://uuid@example.com:443?security=tls#HK\nss://YWVzLTI1Ni1nY206cGFzcw@example.com:8388#JP\n"
+
+// TestAppendURIListUsageNodes_PrependsPlaceholdersAndKeepsBase64 用于验证 base64 列表会在解码后前置伪节点，并重新编码为标准 base64。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendURIListUsageNodes_PrependsPlaceholdersAndKeepsBase64(t *testing.T) {
+	t.Parallel()
+
+	content := base64.StdEncoding.EncodeToString([]byte(testURIList))
+	updated, err := appendURIListUsageNodes([]byte(content), newTestSingBoxAPIInfo(), newTestUsageDisplayConfig())
+	if err != nil {
+		t.Fatalf("appendURIListUsageNodes returned error: %v", err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(updated))
+	if err != nil {
+		t.Fatalf("expected base64 output, got %q: %v", updated, err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(decoded), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %q", len(lines), decoded)
+	}
+	if want := newURIListPlaceholder("📅 重置日期 2026-04-13"); lines[0] != want {
+		t.Fatalf("expected reset placeholder first, got %q want %q", lines[0], want)
+	}
+	if !strings.HasPrefix(lines[1], uriListPlaceholderPrefix) || !strings.HasPrefix(lines[2], "vless://") {
+		t.Fatalf("expected traffic placeholder before original nodes, got %q", decoded)
+	}
+}
+
+// TestAppendURIListUsageNodes_AppendsToPlainList 用于验证明文列表关闭 prepend 时伪节点追加到末尾，且保持明文与原换行风格。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendURIListUsageNodes_AppendsToPlainList(t *testing.T) {
+	t.Parallel()
+
+	usageDisplay := newTestUsageDisplayConfig()
+	usageDisplay.Prepend = false
+
+	content := strings.ReplaceAll(testURIList, "\n", "\r\n")
+	updated, err := appendURIListUsageNodes([]byte(content), newTestSingBoxAPIInfo(), usageDisplay)
+	if err != nil {
+		t.Fatalf("appendURIListUsageNodes returned error: %v", err)
+	}
+
+	want := content + newURIListPlaceholder("📅 重置日期 2026-04-13") + "\r\n" + newURIListPlaceholder("⛽ 已用流量 1G / 5G") + "\r\n"
+	if string(updated) != want {
+		t.Fatalf("unexpected plain output:\n got: %q\nwant: %q", updated, want)
+	}
+}
+
+// TestAppendUsage_DispatchesURIList 用于验证 uri-list 格式会走分享链接列表的注入逻辑。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendUsage_DispatchesURIList(t *testing.T) {
+	t.Parallel()
+
+	updated, err := appendUsage(config.FormatURIList, []byte(testURIList), newTestSingBoxAPIInfo(), newTestUsageDisplayConfig())
+	if err != nil {
+		t.Fatalf("appendUsage returned error: %v", err)
+	}
+	if !strings.HasPrefix(string(updated), uriListPlaceholderPrefix) {
+		t.Fatalf("expected placeholder at head, got: %s", updated)
+	}
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlGroupStyle 表示 proxy-groups 序列在原文中的书写风格，新插入的分组按相同风格渲染。
+// 字段含义：dashIndent 为序列短横线前的缩进；contentIndent 为分组字段的缩进；flowItem 为分组是否写成 {...}；
+// membersOffset 为分组 proxies 子序列短横线相对 proxies 键的缩进；flowMembers 为 proxies 是否写成 [...]；newline 为换行符。
+type yamlGroupStyle struct {
+	dashIndent    int
+	contentIndent int
+	flowItem      bool
+	membersOffset int
+	flowMembers   bool
+	newline       string
+}
+
+// yamlLineStarts 用于计算每一行在原始内容中的起始下标。
+// 参数含义：content 为 YAML 原始内容。
+// 返回值：返回按行号（从 0 开始）排列的起始下标。
+func yamlLineStarts(content []byte) []int {
+	starts := []int{0}
+	for i, b := range content {
+		if b == '\n' && i+1 < len(content) {
+			starts = append(starts, i+1)
+		}
+	}
+
+	return starts
+}
+
+// yamlNodeOffset 用于把节点的行列位置换算为原始内容中的字节下标。
+// 列号按字符计数，这里逐个字符前进，保证行内存在多字节字符时仍然准确。
+// 参数含义：content 为 YAML 原始内容；lineStarts 为行起始下标；node 为语法树节点。
+// 返回值：返回节点起始位置的字节下标。
+func yamlNodeOffset(content []byte, lineStarts []int, node *yaml.Node) int {
+	offset := lineStarts[node.Line-1]
+	for column := 1; column < node.Column && offset < len(content); column++ {
+		_, size := utf8.DecodeRune(content[offset:])
+		offset += size
+	}
+
+	return offset
+}
+
+// yamlDashOffset 用于定位块序列元素之前的短横线。
+// 参数含义：content 为 YAML 原始内容；itemOffset 为元素内容的字节下标。
+// 返回值：返回短横线的字节下标，找不到时返回 -1。
+func yamlDashOffset(content []byte, itemOffset int) int {
+	for i := itemOffset - 1; i >= 0; i-- {
+		switch content[i] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '-':
+			return i
+		default:
+			return -1
+		}
+	}
+
+	return -1
+}
+
+// yamlItemEnd 用于计算块序列元素在原文中的结束位置：从元素首行之后逐行查找，遇到第一个缩进不超过短横线的非空行即视为后续内容。
+// 多行块标量、跨行书写的 [...] 与引号字符串的续行缩进都比短横线深，会一并计入元素，夹在中间的空行同样保留在元素内。
+// 参数含义：content 为 YAML 原始内容；lineStarts 为行起始下标；item 为序列元素节点；dashIndent 为序列短横线的缩进。
+// 返回值：返回元素最后一个非空行之后的下一行起始下标，元素延续到文件末尾时返回内容长度。
+func yamlItemEnd(content []byte, lineStarts []int, item *yaml.Node, dashIndent int) int {
+	lastLine := item.Line - 1
+	for i := item.Line; i < len(lineStarts); i++ {
+		lineEnd := len(content)
+		if i+1 < len(lineStarts) {
+			lineEnd = lineStarts[i+1]
+		}
+
+		line := bytes.TrimRight(content[lineStarts[i]:lineEnd], "\r\n")
+		text := bytes.TrimLeft(line, " ")
+		if len(bytes.TrimSpace(text)) == 0 {
+			continue
+		}
+		if len(line)-len(text) <= dashIndent {
+			break
+		}
+		lastLine = i
+	}
+
+	if lastLine+1 < len(lineStarts) {
+		return lineStarts[lastLine+1]
+	}
+
+	return len(content)
+}
+
+// detectYAMLGroupStyle 用于根据第一个已有分组推断 proxy-groups 的缩进与书写风格。
+// 参数含义：content 为 YAML 原始内容；lineStarts 为行起始下标；first 为第一个分组节点。
+// 返回值：返回推断出的书写风格；无法定位短横线时 ok 为 false。
+func detectYAMLGroupStyle(content []byte, lineStarts []int, first *yaml.Node) (yamlGroupStyle, bool) {
+	style := yamlGroupStyle{
+		contentIndent: first.Column - 1,
+		flowItem:      first.Style&yaml.FlowStyle != 0,
+		membersOffset: 2,
+		newline:       "\n",
+	}
+	if bytes.Contains(content, []byte("\r\n")) {
+		style.newline = "\r\n"
+	}
+
+	dash := yamlDashOffset(content, yamlNodeOffset(content, lineStarts, first))
+	if dash < 0 {
+		return yamlGroupStyle{}, false
+	}
+	style.dashIndent = dash - (bytes.LastIndexByte(content[:dash], '\n') + 1)
+	// 分组内容另起一行书写时，插入的分组仍与短横线写在同一行，保持常见的两格缩进。
+	if style.contentIndent <= style.dashIndent {
+		style.contentIndent = style.dashIndent + 2
+	}
+
+	for i := 0; i+1 < len(first.Content); i += 2 {
+		keyNode, members := first.Content[i], first.Content[i+1]
+		if keyNode.Value != "proxies" || members.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		style.flowMembers = members.Style&yaml.FlowStyle != 0
+		if !style.flowMembers && len(members.Content) > 0 {
+			memberDash := yamlDashOffset(content, yamlNodeOffset(content, lineStarts, members.Content[0]))
+			if memberDash >= 0 {
+				memberIndent := memberDash - (bytes.LastIndexByte(content[:memberDash], '\n') + 1)
+				style.membersOffset = max(memberIndent-(keyNode.Column-1), 0)
+			}
+		}
+		break
+	}
+
+	return style, true
+}
+
+// writeGroup 用于按书写风格渲染一个仅承载展示信息的分组，名称统一使用双引号，保留 emoji 原文。
+// 参数含义：buffer 为输出缓冲；name 为分组展示名称。
+// 返回值：无。
+func (s yamlGroupStyle) writeGroup(buffer *bytes.Buffer, name string) {
+	quoted := strconv.Quote(name)
+	dash := strings.Repeat(" ", s.dashIndent) + "-" + strings.Repeat(" ", s.contentIndent-s.dashIndent-1)
+
+	if s.flowItem {
+		buffer.WriteString(dash + "{name: " + quoted + ", type: select, proxies: [REJECT]}" + s.newline)
+		return
+	}
+
+	indent := strings.Repeat(" ", s.contentIndent)
+	buffer.WriteString(dash + "name: " + quoted + s.newline)
+	buffer.WriteString(indent + "type: select" + s.newline)
+	if s.flowMembers {
+		buffer.WriteString(indent + "proxies: [REJECT]" + s.newline)
+		return
+	}
+	buffer.WriteString(indent + "proxies:" + s.newline)
+	buffer.WriteString(indent + strings.Repeat(" ", s.membersOffset) + "- REJECT" + s.newline)
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/djx30103/vpsub/pkg/provider/base"
+)
+
+// newTestUsageLines 用于生成拼接测试使用的流量信息及其对应的展示文案。
+// 参数含义：t 为测试上下文。
+// 返回值：返回流量信息与双引号包裹后的两条展示文案。
+func newTestUsageLines(t *testing.T) (*base.APIResponseInfo, string, string) {
+	t.Helper()
+
+	apiInfo := &base.APIResponseInfo{
+		Total:   5 * 1024 * 1024 * 1024,
+		ResetAt: time.Date(2026, 4, 13, 8, 30, 0, 0, time.Local).Unix(),
+	}
+	lines, err := renderUsageLines(apiInfo, newTestUsageDisplayConfig())
+	if err != nil {
+		t.Fatalf("renderUsageLines returned error: %v", err)
+	}
+
+	return apiInfo, strconv.Quote(lines[0]), strconv.Quote(lines[1])
+}
+
+// TestAppendUsageGroups_PreservesOriginalBytes 用于验证前置注入只插入新分组，flow 风格节点、引号与注释逐字节保留。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendUsageGroups_PreservesOriginalBytes(t *testing.T) {
+	t.Parallel()
+
+	apiInfo, resetLine, trafficLine := newTestUsageLines(t)
+	head := "# 订阅说明\nproxies:\n  - {name: \"🇭🇰 HK\", type: ss, server: hk.example.com, port: 443}\nproxy-groups:\n"
+	tail := "  - name: 'Proxy'   # 主分组\n    type: select\n    proxies:\n      - \"🇭🇰 HK\"\nrules:\n  - MATCH,Proxy\n"
+
+	updated, err := appendUsageGroups([]byte(head+tail), apiInfo, newTestUsageDisplayConfig())
+	if err != nil {
+		t.Fatalf("appendUsageGroups returned error: %v", err)
+	}
+
+	want := head +
+		"  - name: " + resetLine + "\n    type: select\n    proxies:\n      - REJECT\n" +
+		"  - name: " + trafficLine + "\n    type: select\n    proxies:\n      - REJECT\n" +
+		tail
+	if string(updated) != want {
+		t.Fatalf("unexpected content:\n%s\nwant:\n%s", updated, want)
+	}
+}
+
+// TestAppendUsageGroups_FollowsDocumentIndentation 用于验证追加注入会沿用无缩进序列、flow 风格成员与 CRLF 换行，并补齐末尾缺失的换行。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendUsageGroups_FollowsDocumentIndentation(t *testing.T) {
+	t.Parallel()
+
+	apiInfo, resetLine, trafficLine := newTestUsageLines(t)
+	usageDisplay := newTestUsageDisplayConfig()
+	usageDisplay.Prepend = false
+
+	content := "proxy-groups:\r\n- name: Proxy\r\n  type: select\r\n  proxies: [DIRECT]"
+	updated, err := appendUsageGroups([]byte(content), apiInfo, usageDisplay)
+	if err != nil {
+		t.Fatalf("appendUsageGroups returned error: %v", err)
+	}
+
+	want := content + "\r\n" +
+		"- name: " + resetLine + "\r\n  type: select\r\n  proxies: [REJECT]\r\n" +
+		"- name: " + trafficLine + "\r\n  type: select\r\n  proxies: [REJECT]\r\n"
+	if string(updated) != want {
+		t.Fatalf("unexpected content:\n%q\nwant:\n%q", updated, want)
+	}
+}
+
+// TestAppendUsageGroups_FallsBackForFlowSequence 用于验证整个 proxy-groups 写成 flow 风格时仍能注入分组。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendUsageGroups_FallsBackForFlowSequence(t *testing.T) {
+	t.Parallel()
+
+	apiInfo, _, _ := newTestUsageLines(t)
+	updated, err := appendUsageGroups([]byte("proxy-groups: [{name: Proxy, type: select, proxies: [DIRECT]}]\n"), apiInfo, newTestUsageDisplayConfig())
+	if err != nil {
+		t.Fatalf("appendUsageGroups returned error: %v", err)
+	}
+
+	var got mergedTestSubscription
+	if err := yaml.Unmarshal(updated, &got); err != nil {
+		t.Fatalf("failed to parse updated subscription: %v\n%s", err, updated)
+	}
+	if len(got.ProxyGroups) != 3 || got.ProxyGroups[2].Name != "Proxy" || !strings.HasPrefix(got.ProxyGroups[0].Name, "📅") {
+		t.Fatalf("unexpected proxy groups: %+v", got.ProxyGroups)
+	}
+}
+
+// TestAppendUsageGroups_AppendsAfterMultiLineFlowValues 用于验证最后一个分组以跨行的 flow 序列或双引号字符串结尾时，追加的分组落在其续行之后。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestAppendUsageGroups_AppendsAfterMultiLineFlowValues(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"flow sequence": "proxy-groups:\n  - name: Proxy\n    type: select\n    proxies: [\n      HK,\n      DIRECT\n    ]\nrules:\n  - MATCH,Proxy\n",
+		"double quoted": "proxy-groups:\n  - name: Proxy\n    type: select\n    proxies: [DIRECT]\n    url: \"http://www.gstatic.com/\n      generate_204\"\n\nrules:\n  - MATCH,Proxy\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			apiInfo, _, _ := newTestUsageLines(t)
+			usageDisplay := newTestUsageDisplayConfig()
+			usageDisplay.Prepend = false
+
+			updated, err := appendUsageGroups([]byte(content), apiInfo, usageDisplay)
+			if err != nil {
+				t.Fatalf("appendUsageGroups returned error: %v", err)
+			}
+
+			var got mergedTestSubscription
+			if err := yaml.Unmarshal(updated, &got); err != nil {
+				t.Fatalf("failed to parse updated subscription: %v\n%s", err, updated)
+			}
+			if len(got.ProxyGroups) != 3 || got.ProxyGroups[0].Name != "Proxy" || !strings.HasPrefix(got.ProxyGroups[1].Name, "📅") {
+				t.Fatalf("unexpected proxy groups: %+v", got.ProxyGroups)
+			}
+			if !strings.HasPrefix(string(updated), strings.TrimRight(content[:strings.Index(content, "rules:")], "\n")) {
+				t.Fatalf("original group bytes changed:\n%s", updated)
+			}
+		})
+	}
+}
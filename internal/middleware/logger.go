@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -9,6 +12,33 @@ import (
 	"github.com/djx30103/vpsub/pkg/log"
 )
 
+// RoutePathKey 为处理器写入 Gin 上下文的路由路径，请求日志优先记录该路径，避免路径末段携带的访问令牌出现在日志中。
+const RoutePathKey = "route_path"
+
+// sensitiveQueryKeys 为日志中需要隐去取值的查询参数。
+var sensitiveQueryKeys = []string{"token", "sig"}
+
+// redactQuery 用于隐去查询参数中的访问令牌等敏感取值，其余参数保持原样与原有顺序。
+// 参数名按 url.ParseQuery 的规则解码后再比较，to%6Ben 这类编码写法与处理器读取到的参数一致，同样会被隐去。
+// 参数含义：query 为原始查询字符串。
+// 返回值：返回可写入日志的查询字符串。
+func redactQuery(query string) string {
+	if query == "" {
+		return query
+	}
+
+	parts := strings.Split(query, "&")
+	for i, part := range parts {
+		rawKey, _, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err == nil && slices.Contains(sensitiveQueryKeys, key) {
+			parts[i] = rawKey + "=***"
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
 func Logger(logger *log.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		bytesIn := 0
@@ -27,7 +57,10 @@ func Logger(logger *log.Logger) gin.HandlerFunc {
 		}
 
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		if routePath := c.GetString(RoutePathKey); routePath != "" {
+			path = routePath
+		}
+		query := redactQuery(c.Request.URL.RawQuery)
 		if query != "" {
 			path = path + "?" + query
 		}
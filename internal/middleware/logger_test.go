@@ -107,3 +107,68 @@ func TestLogger_KeepsRequestBodyReadable(t *testing.T) {
 		t.Fatalf("expected bytes_in to be integer, got %#v", fields["bytes_in"])
 	}
 }
+
+// TestLogger_RedactsAccessToken 用于验证请求日志会隐去查询参数中的访问令牌，其余参数保持原样。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestLogger_RedactsAccessToken(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	core, recorded := observer.New(zap.InfoLevel)
+	logger := &log.Logger{Logger: zap.New(core)}
+
+	engine := gin.New()
+	engine.Use(Logger(logger))
+	engine.GET("/client-a", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	cases := map[string]string{
+		"/client-a?target=clash&token=secret-token": "/client-a?target=clash&token=***",
+		"/client-a?to%6Ben=secret-token&sig=abc":    "/client-a?to%6Ben=***&sig=***",
+		"/client-a?token":                           "/client-a?token=***",
+	}
+	for target, want := range cases {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+
+		entries := recorded.TakeAll()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %d", len(entries))
+		}
+		if got := entries[0].ContextMap()["path"]; got != want {
+			t.Fatalf("%s: expected %q, got %#v", target, want, got)
+		}
+	}
+}
+
+// TestLogger_RecordsRoutePathInsteadOfPathToken 用于验证处理器写入路由路径后，日志不再记录路径末段携带的访问令牌。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestLogger_RecordsRoutePathInsteadOfPathToken(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	core, recorded := observer.New(zap.InfoLevel)
+	logger := &log.Logger{Logger: zap.New(core)}
+
+	engine := gin.New()
+	engine.Use(Logger(logger))
+	engine.GET("/*path", func(c *gin.Context) {
+		c.Set(RoutePathKey, "/client-a")
+		c.String(http.StatusOK, "ok")
+	})
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/client-a/secret-token?target=clash", nil))
+
+	entries := recorded.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	path, _ := entries[0].ContextMap()["path"].(string)
+	if path != "/client-a?target=clash" || strings.Contains(path, "secret-token") {
+		t.Fatalf("expected route path without token, got %q", path)
+	}
+}
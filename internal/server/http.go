@@ -44,6 +44,11 @@ func NewHTTPServer(
 ) *HTTPServer {
 	gin.SetMode(conf.AppMode)
 	r := gin.New()
+	// Gin 默认信任所有代理，任何人都能通过 X-Forwarded-For 伪造客户端 IP，这里只信任配置的代理地址。
+	if err := r.SetTrustedProxies(conf.Server.TrustedProxies); err != nil {
+		logger.Error("invalid trusted proxies, forwarded headers will be ignored", zap.Error(err))
+		_ = r.SetTrustedProxies(nil)
+	}
 	r.Use(
 		gin.Recovery(),
 		middleware.Logger(logger),                 // 日志
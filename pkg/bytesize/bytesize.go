@@ -1,6 +1,11 @@
 package bytesize
 
-import "fmt"
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
 
 const (
 	_        = iota
@@ -8,46 +13,180 @@ const (
 	MB
 	GB
 	TB
+	PB
+	EB
 )
 
 type Unit string
 
 const (
-	UnitK Unit = "K"
-	UnitM Unit = "M"
-	UnitG Unit = "G"
-	UnitT Unit = "T"
+	UnitK    Unit = "K"
+	UnitM    Unit = "M"
+	UnitG    Unit = "G"
+	UnitT    Unit = "T"
+	UnitP    Unit = "P"
+	UnitE    Unit = "E"
+	UnitAuto Unit = "auto"
+)
+
+// Base 表示单位换算的进制标准。
+type Base string
+
+const (
+	// BaseIEC 按 1024 进制换算，与大多数服务商面板一致。
+	BaseIEC Base = "iec"
+	// BaseSI 按 1000 进制换算，适用于按十进制计费的服务商。
+	BaseSI Base = "si"
 )
 
+// fixedUnits 为按从小到大排列的固定单位，下标加一即为单位的幂次。
+var fixedUnits = []Unit{UnitK, UnitM, UnitG, UnitT, UnitP, UnitE}
+
 // IsValidUnit 用于校验流量展示单位是否属于支持的范围。
 // 参数含义：unit 为待校验的流量单位字符串。
-// 返回值：当 unit 为 K、M、G、T 之一时返回 true，否则返回 false。
+// 返回值：当 unit 为 K、M、G、T、P、E 或 auto 之一时返回 true，否则返回 false。
 func IsValidUnit(unit string) bool {
-	return unit == string(UnitK) || unit == string(UnitM) || unit == string(UnitG) ||
-		unit == string(UnitT)
+	return unit == string(UnitAuto) || unitPower(unit) > 0
+}
+
+// IsValidBase 用于校验进制标准是否属于支持的范围。
+// 参数含义：base 为待校验的进制标准字符串。
+// 返回值：当 base 为 iec 或 si 时返回 true，否则返回 false。
+func IsValidBase(base string) bool {
+	return base == string(BaseIEC) || base == string(BaseSI)
+}
+
+// unitPower 用于返回固定单位相对字节的幂次。
+// 参数含义：unit 为流量单位字符串。
+// 返回值：返回 1 到 6 的幂次，未知单位时返回 0。
+func unitPower(unit string) int {
+	for i, candidate := range fixedUnits {
+		if unit == string(candidate) {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// divisor 用于按进制标准计算固定单位的除数。
+// 参数含义：unit 为流量单位字符串；base 为进制标准，未知标准按 IEC 处理。
+// 返回值：返回对应单位的除数，未知单位时回退为 1。
+func divisor(unit string, base Base) int64 {
+	radix := int64(1024)
+	if base == BaseSI {
+		radix = 1000
+	}
+
+	result := int64(1)
+	for range unitPower(unit) {
+		result *= radix
+	}
+
+	return result
 }
 
 // GetDivisor 用于根据流量单位返回格式化时使用的除数。
 // 参数含义：unit 为流量单位字符串。
 // 返回值：返回对应单位的二进制除数，未知单位时回退为 1。
 func GetDivisor(unit string) int64 {
-	switch unit {
-	case string(UnitT):
-		return TB
-	case string(UnitG):
-		return GB
-	case string(UnitM):
-		return MB
-	case string(UnitK):
-		return KB
-	default:
-		return 1
-	}
+	return divisor(unit, BaseIEC)
 }
 
 // Format 用于按指定流量单位格式化字节数展示值。
 // 参数含义：bytes 为原始字节数，unit 为目标展示单位。
 // 返回值：返回拼接单位后的整数字符串。
 func Format(bytes int64, unit string) string {
-	return fmt.Sprintf("%d%s", bytes/GetDivisor(unit), unit)
+	return FormatWith(bytes, unit, 0, BaseIEC)
+}
+
+// FormatWith 用于按指定单位、小数位数与进制标准格式化字节数展示值。
+// 固定单位按小数位数截断，保持与整数展示一致的向下取整语义；auto 会选择不小于 1 的最大单位，并去掉小数末尾的 0，不足 1K 时以 B 为单位。
+// 参数含义：bytes 为原始字节数；unit 为目标展示单位；precision 为保留的小数位数；base 为进制标准。
+// 返回值：返回拼接单位后的展示值。
+func FormatWith(bytes int64, unit string, precision int, base Base) string {
+	if unit != string(UnitAuto) {
+		return formatFixed(bytes, divisor(unit, base), precision) + unit
+	}
+
+	for i := len(fixedUnits) - 1; i >= 0; i-- {
+		candidate := string(fixedUnits[i])
+		size := divisor(candidate, base)
+		if bytes >= size || -bytes >= size {
+			value := formatFixed(bytes, size, precision)
+			if strings.Contains(value, ".") {
+				value = strings.TrimRight(strings.TrimRight(value, "0"), ".")
+			}
+			return value + candidate
+		}
+	}
+
+	return strconv.FormatInt(bytes, 10) + "B"
+}
+
+// formatFixed 用于以整数运算把字节数换算为指定除数下的定点小数，避免浮点误差影响截断结果。
+// 参数含义：bytes 为原始字节数；size 为单位除数；precision 为保留的小数位数，负数按 0 处理。
+// 返回值：返回不含单位的数值字符串。
+func formatFixed(bytes, size int64, precision int) string {
+	precision = max(precision, 0)
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+	scaled := new(big.Int).Mul(big.NewInt(bytes), scale)
+	scaled.Quo(scaled, big.NewInt(size))
+
+	sign := ""
+	if scaled.Sign() < 0 {
+		sign = "-"
+		scaled.Neg(scaled)
+	}
+
+	digits := scaled.String()
+	if precision == 0 {
+		return sign + digits
+	}
+	if len(digits) <= precision {
+		digits = strings.Repeat("0", precision-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-precision] + "." + digits[len(digits)-precision:]
+}
+
+// Parse 用于把 "100G"、"1.5 TB"、"512MiB" 这类人类可读的容量解析为字节数。
+// 单位不区分大小写，可省略末尾的 B；带 i 的单位（如 GiB）固定按 1024 进制换算，其余单位按 base 换算；没有单位时按字节处理，小数部分向下取整。
+// 参数含义：s 为待解析的容量字符串；base 为不带 i 的单位使用的进制标准。
+// 返回值：返回字节数和格式错误；结果为负数或超出 int64 范围时返回错误。
+func Parse(s string, base Base) (int64, error) {
+	text := strings.TrimSpace(s)
+	end := len(text)
+	for end > 0 && !(text[end-1] >= '0' && text[end-1] <= '9') && text[end-1] != '.' {
+		end--
+	}
+	number, suffix := strings.TrimSpace(text[:end]), strings.ToUpper(strings.TrimSpace(text[end:]))
+	if number == "" || strings.Trim(number, "0123456789.") != "" {
+		return 0, errors.New("size number is invalid")
+	}
+
+	suffix = strings.TrimSuffix(suffix, "B")
+	if strings.HasSuffix(suffix, "I") {
+		suffix = strings.TrimSuffix(suffix, "I")
+		if suffix == "" {
+			return 0, errors.New("size unit is invalid")
+		}
+		base = BaseIEC
+	}
+	if suffix != "" && unitPower(suffix) == 0 {
+		return 0, errors.New("size unit is invalid")
+	}
+
+	value, ok := new(big.Rat).SetString(number)
+	if !ok || value.Sign() < 0 {
+		return 0, errors.New("size number is invalid")
+	}
+	value.Mul(value, new(big.Rat).SetInt64(divisor(suffix, base)))
+
+	result := new(big.Int).Quo(value.Num(), value.Denom())
+	if !result.IsInt64() {
+		return 0, errors.New("size is too large")
+	}
+
+	return result.Int64(), nil
 }
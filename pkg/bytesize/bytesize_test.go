@@ -44,3 +44,91 @@ func TestConstants_使用二进制位移定义单位(t *testing.T) {
 		t.Fatalf("expected TB to equal 1<<40, got %d", TB)
 	}
 }
+
+// TestFormatWith_自动选择单位 用于验证 auto 会选择不小于 1 的最大单位并去掉多余的小数位。
+// 参数含义：t 为测试上下文，用于驱动断言和失败输出。
+// 返回值：无，测试失败时由 testing 框架终止当前用例。
+func TestFormatWith_自动选择单位(t *testing.T) {
+	cases := map[int64]string{
+		512:         "512B",
+		1536:        "1.5K",
+		512 * MB:    "512M",
+		5*GB + GB/4: "5.25G",
+		2 * TB:      "2T",
+	}
+	for size, want := range cases {
+		if got := FormatWith(size, string(UnitAuto), 2, BaseIEC); got != want {
+			t.Fatalf("FormatWith(%d, auto, 2) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+// TestFormatWith_支持小数位与进制标准 用于验证固定单位按小数位截断、auto 选择合适单位，以及 SI 按 1000 进制换算。
+// 参数含义：t 为测试上下文，用于驱动断言和失败输出。
+// 返回值：无，测试失败时由 testing 框架终止当前用例。
+func TestFormatWith_支持小数位与进制标准(t *testing.T) {
+	cases := []struct {
+		bytes     int64
+		unit      string
+		precision int
+		base      Base
+		want      string
+	}{
+		{512 * MB, "G", 0, BaseIEC, "0G"},
+		{512 * MB, "G", 2, BaseIEC, "0.50G"},
+		{GB + GB/3, "G", 2, BaseIEC, "1.33G"},
+		{512 * MB, "auto", 0, BaseIEC, "512M"},
+		{GB + GB/2, "auto", 2, BaseIEC, "1.5G"},
+		{3 * PB, "auto", 1, BaseIEC, "3P"},
+		{2 * EB, "E", 0, BaseIEC, "2E"},
+		{1_500_000_000, "G", 1, BaseSI, "1.5G"},
+		{999, "auto", 2, BaseSI, "999B"},
+		{-GB, "G", 1, BaseIEC, "-1.0G"},
+	}
+	for _, tc := range cases {
+		if got := FormatWith(tc.bytes, tc.unit, tc.precision, tc.base); got != tc.want {
+			t.Fatalf("FormatWith(%d, %q, %d, %q) = %q, want %q", tc.bytes, tc.unit, tc.precision, tc.base, got, tc.want)
+		}
+	}
+
+	if got := Format(GB+GB/2, "G"); got != "1G" {
+		t.Fatalf("expected Format to keep integer truncation, got %q", got)
+	}
+	if !IsValidUnit("auto") || !IsValidUnit("E") || IsValidUnit("g") {
+		t.Fatalf("unexpected unit validation result")
+	}
+}
+
+// TestParse_解析人类可读容量 用于验证 Parse 支持小数、省略 B、IEC 单位与 SI 进制，并拒绝非法输入。
+// 参数含义：t 为测试上下文，用于驱动断言和失败输出。
+// 返回值：无，测试失败时由 testing 框架终止当前用例。
+func TestParse_解析人类可读容量(t *testing.T) {
+	cases := []struct {
+		input string
+		base  Base
+		want  int64
+	}{
+		{"100G", BaseIEC, 100 * GB},
+		{"1.5 TB", BaseIEC, TB + TB/2},
+		{"512mib", BaseSI, 512 * MB},
+		{"1TB", BaseSI, 1_000_000_000_000},
+		{"2048", BaseIEC, 2048},
+		{"1.5", BaseIEC, 1},
+		{"7e", BaseIEC, 7 * EB},
+	}
+	for _, tc := range cases {
+		got, err := Parse(tc.input, tc.base)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tc.input, err)
+		}
+		if got != tc.want {
+			t.Fatalf("Parse(%q) = %d, want %d", tc.input, got, tc.want)
+		}
+	}
+
+	for _, input := range []string{"", "G", "1X", "1iB", "1/2G", "-1G", "8E"} {
+		if _, err := Parse(input, BaseIEC); err == nil {
+			t.Fatalf("expected Parse(%q) to fail", input)
+		}
+	}
+}
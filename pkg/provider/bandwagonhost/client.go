@@ -1,12 +1,14 @@
 package bandwagonhost
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/djx30103/vpsub/pkg/provider/base"
 )
@@ -15,38 +17,38 @@ type Client struct {
 	veid   string
 	apiKey string
 
+	countingMode   base.CountingMode
+	dataMultiplier float64
+
 	baseURL string
 	httpCli *http.Client
 }
 
 // New 用于根据账号信息创建 BandwagonHost API 客户端。
-// 参数含义：info 为调用接口所需的认证信息和请求超时配置。
-// 返回值：返回初始化完成的客户端。
-func New(info base.APIRequestInfo) *Client {
+// 参数含义：info 为调用接口所需的认证信息、请求超时和出站网络配置。
+// 返回值：返回初始化完成的客户端；出站网络配置非法时返回错误。
+func New(info base.APIRequestInfo) (*Client, error) {
+	httpCli, err := base.NewHTTPClient(info)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Client{
 		veid:    info.APIID,
 		apiKey:  info.APIKey,
-		baseURL: "https://api.64clouds.com",
-		httpCli: &http.Client{
-			Timeout: info.RequestTimeout,
-		},
-	}
+		baseURL: cmp.Or(info.BaseURL, "https://api.64clouds.com"),
+		httpCli: httpCli,
+
+		countingMode:   info.CountingMode,
+		dataMultiplier: info.DataMultiplier,
+	}, nil
 }
 
 // GetServiceInfo 用于查询 BandwagonHost 当前服务的流量使用情况。
 // 参数含义：ctx 为本次请求的上下文，用于控制超时和取消。
 // 返回值：返回统一格式的流量信息；若请求或解析失败则返回错误。
 func (c *Client) GetServiceInfo(ctx context.Context) (*base.APIResponseInfo, error) {
-	reqURL, err := url.Parse(c.baseURL + "/v1/getServiceInfo")
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse service info url: %w", err)
-	}
-	query := reqURL.Query()
-	query.Set("veid", c.veid)
-	query.Set("api_key", c.apiKey)
-	reqURL.RawQuery = query.Encode()
-
-	body, err := base.DoGetRequest(ctx, c.httpCli, reqURL.String())
+	body, err := c.call(ctx, "getServiceInfo")
 	if err != nil {
 		return nil, err
 	}
@@ -56,19 +58,94 @@ func (c *Client) GetServiceInfo(ctx context.Context) (*base.APIResponseInfo, err
 		return nil, fmt.Errorf("failed to unmarshal service info: %w", err)
 	}
 
-	// BandwagonHost API 只返回总用量，不区分上传和下载，因此各取一半作为近似值。
-	half := info.DataCounter * info.MonthlyDataMultiplier / 2
+	// 认证失败等业务错误同样返回 200，需要根据 error 字段区分，避免误判为套餐信息异常。
+	if info.Error != 0 {
+		return nil, fmt.Errorf("failed to get service info, api error %d: %s", info.Error, info.Message)
+	}
+
+	// API 文档要求已用与总量都乘以 monthly_data_multiplier 才是计费口径；账号配置了计费系数时替代已用流量的系数，
+	// 例如 data_multiplier: 1 可以撤销高价机房的倍率计费，总量仍是 API 给出的套餐额度。
 	total := info.PlanMonthlyData * info.MonthlyDataMultiplier
+	multiplier := float64(info.MonthlyDataMultiplier)
+	if c.dataMultiplier > 0 {
+		multiplier = c.dataMultiplier
+	}
 
 	// 总量为 0 代表套餐信息异常；已用流量为 0 则是正常场景，例如新开机或刚重置流量。
 	if total <= 0 {
 		return nil, errors.New("failed to get service info, total is 0")
 	}
 
+	// getServiceInfo 只返回总用量，不区分上传和下载，按计费方向拆分，未配置时各取一半。
+	upload, download := base.BilledUsage(info.DataCounter, c.countingMode, multiplier)
+	if c.countingMode == base.CountingModeMax {
+		// 按较大方向计费需要分方向的用量，只有此时才额外查询明细统计。
+		in, out, err := c.directionalUsage(ctx, billingPeriodStart(info.DataNextReset))
+		if err != nil {
+			return nil, err
+		}
+		upload, download = base.BilledDirections(in, out, c.countingMode, multiplier)
+	}
+
 	return &base.APIResponseInfo{
-		Upload:   half,
-		Download: half,
+		Upload:   upload,
+		Download: download,
 		Total:    total,
-		Expire:   info.DataNextReset,
+		ResetAt:  info.DataNextReset,
 	}, nil
 }
+
+// directionalUsage 用于汇总当前计费周期内的入站与出站流量，数据来自 KiwiVM 明细统计接口。
+// 约定 VPS 入站（ingress）对应上传、出站（egress）对应下载。
+// 参数含义：ctx 为本次请求的上下文；since 为计费周期开始的 Unix 时间戳，早于该时间的统计点不计入。
+// 返回值：返回未经换算的入站和出站字节数；若请求或解析失败则返回错误。
+func (c *Client) directionalUsage(ctx context.Context, since int64) (int64, int64, error) {
+	body, err := c.call(ctx, "getRawUsageStats")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	stats := new(RawUsageStats)
+	if err := json.Unmarshal(body, stats); err != nil {
+		return 0, 0, fmt.Errorf("failed to unmarshal raw usage stats: %w", err)
+	}
+	if stats.Error != 0 {
+		return 0, 0, fmt.Errorf("failed to get raw usage stats, api error %d: %s", stats.Error, stats.Message)
+	}
+
+	var in, out int64
+	for _, point := range stats.Data {
+		if point.Timestamp < since {
+			continue
+		}
+		in += point.NetworkInBytes
+		out += point.NetworkOutBytes
+	}
+	return in, out, nil
+}
+
+// call 用于请求 KiwiVM API 的指定接口，并附带账号认证参数。
+// 参数含义：ctx 为本次请求的上下文；name 为接口名称，例如 getServiceInfo。
+// 返回值：返回响应体；若地址非法或请求失败则返回错误。
+func (c *Client) call(ctx context.Context, name string) ([]byte, error) {
+	reqURL, err := url.Parse(c.baseURL + "/v1/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s url: %w", name, err)
+	}
+	query := reqURL.Query()
+	query.Set("veid", c.veid)
+	query.Set("api_key", c.apiKey)
+	reqURL.RawQuery = query.Encode()
+
+	return base.DoGetRequest(ctx, c.httpCli, reqURL.String())
+}
+
+// billingPeriodStart 用于根据下一次重置时间推算当前计费周期的开始时间，BandwagonHost 按月重置流量。
+// 参数含义：nextReset 为下一次流量重置的 Unix 时间戳，0 表示未提供。
+// 返回值：返回计费周期开始的 Unix 时间戳；未提供重置时间时返回 0，即统计全部明细。
+func billingPeriodStart(nextReset int64) int64 {
+	if nextReset <= 0 {
+		return 0
+	}
+	return time.Unix(nextReset, 0).UTC().AddDate(0, -1, 0).Unix()
+}
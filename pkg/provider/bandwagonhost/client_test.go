@@ -22,11 +22,14 @@ func TestGetServiceInfo_AllowsZeroUsage(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(base.APIRequestInfo{
+	client, err := New(base.APIRequestInfo{
 		APIID:          "veid-1",
 		APIKey:         "key-1",
 		RequestTimeout: time.Second,
 	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
 	client.baseURL = server.URL
 
 	info, err := client.GetServiceInfo(context.Background())
@@ -42,3 +45,93 @@ func TestGetServiceInfo_AllowsZeroUsage(t *testing.T) {
 		t.Fatalf("expected zero usage, got upload=%d download=%d", info.Upload, info.Download)
 	}
 }
+
+// TestGetServiceInfo_AppliesCountingModeAndMultiplierOverride 用于验证账号级计费系数替代 API 返回的已用流量系数而不是叠加，总量不受影响，且用量按计费方向拆分。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGetServiceInfo_AppliesCountingModeAndMultiplierOverride(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"monthly_data_multiplier":2,"plan_monthly_data":1000,"data_counter":300,"data_next_reset":1711929600}`))
+	}))
+	defer server.Close()
+
+	client, err := New(base.APIRequestInfo{
+		APIID:          "veid-1",
+		APIKey:         "key-1",
+		RequestTimeout: time.Second,
+		CountingMode:   base.CountingModeEgressOnly,
+		DataMultiplier: 1,
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	client.baseURL = server.URL
+
+	info, err := client.GetServiceInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetServiceInfo returned error: %v", err)
+	}
+
+	if info.Total != 2000 {
+		t.Fatalf("expected total scaled by api multiplier only 2000, got %d", info.Total)
+	}
+
+	// data_multiplier: 1 撤销了 API 的 2 倍系数，已用流量按原始计数展示。
+	if info.Upload != 0 || info.Download != 300 {
+		t.Fatalf("expected egress-only usage with overridden multiplier on download, got upload=%d download=%d", info.Upload, info.Download)
+	}
+}
+
+// TestGetServiceInfo_MaxModeUsesDirectionalStats 用于验证按较大方向计费时从明细统计汇总当前计费周期的入站与出站流量，只保留较大一侧。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestGetServiceInfo_MaxModeUsesDirectionalStats(t *testing.T) {
+	t.Parallel()
+
+	// 2024-04-01 重置，计费周期从 2024-03-01 开始，2024-02-29 的统计点不计入。
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/getServiceInfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"monthly_data_multiplier":2,"plan_monthly_data":1000,"data_counter":300,"data_next_reset":1711929600}`))
+	})
+	mux.HandleFunc("/v1/getRawUsageStats", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("veid") != "veid-1" || r.URL.Query().Get("api_key") != "key-1" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[
+			{"timestamp":1709164800,"network_in_bytes":500,"network_out_bytes":0},
+			{"timestamp":1709251200,"network_in_bytes":40,"network_out_bytes":100},
+			{"timestamp":1710000000,"network_in_bytes":20,"network_out_bytes":30}
+		],"vm_type":"kvm"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := New(base.APIRequestInfo{
+		APIID:          "veid-1",
+		APIKey:         "key-1",
+		RequestTimeout: time.Second,
+		CountingMode:   base.CountingModeMax,
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	client.baseURL = server.URL
+
+	info, err := client.GetServiceInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetServiceInfo returned error: %v", err)
+	}
+
+	if info.Total != 2000 {
+		t.Fatalf("expected total 2000, got %d", info.Total)
+	}
+	// 周期内入站 60、出站 130，按 API 系数 2 换算后只保留出站一侧。
+	if info.Upload != 0 || info.Download != 260 {
+		t.Fatalf("expected larger direction on download, got upload=%d download=%d", info.Upload, info.Download)
+	}
+}
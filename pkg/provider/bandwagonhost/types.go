@@ -36,4 +36,22 @@ type ServiceInfo struct {
 	MaxAbusePoints                  int64          `json:"max_abuse_points"`
 	FreeIpReplacementInterval       int64          `json:"free_ip_replacement_interval"`
 	Error                           int64          `json:"error"`
+	Message                         string         `json:"message"`
+}
+
+// RawUsageStats 表示 getRawUsageStats 接口返回的明细统计，每个统计点包含该时段的入站与出站字节数。
+type RawUsageStats struct {
+	Data    []RawUsageStat `json:"data"`
+	VmType  string         `json:"vm_type"`
+	Error   int64          `json:"error"`
+	Message string         `json:"message"`
+}
+
+type RawUsageStat struct {
+	Timestamp       int64 `json:"timestamp"`
+	NetworkInBytes  int64 `json:"network_in_bytes"`
+	NetworkOutBytes int64 `json:"network_out_bytes"`
+	DiskReadBytes   int64 `json:"disk_read_bytes"`
+	DiskWriteBytes  int64 `json:"disk_write_bytes"`
+	CPUUsage        int64 `json:"cpu_usage"`
 }
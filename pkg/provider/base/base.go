@@ -2,16 +2,27 @@ package base
 
 import "time"
 
+// APIResponseInfo 表示服务商返回的统一流量信息，时间字段均为 Unix 时间戳，0 表示服务商未提供。
+// 字段含义：ResetAt 为下一次流量重置时间；ServiceExpireAt 为服务（账单）到期时间；
+// ResetLocation 为重置规则所依据的时区，用于按该时区展示重置日期，nil 表示使用服务本地时区。
 type APIResponseInfo struct {
-	Upload   int64
-	Download int64
-	Total    int64
-	Expire   int64
+	Upload          int64
+	Download        int64
+	Total           int64
+	ResetAt         int64
+	ServiceExpireAt int64
+	ResetLocation   *time.Location
 }
 
+// APIRequestInfo 表示创建服务商客户端所需的参数。
+// BaseURL 为空时使用服务商官方 API 地址，非空时用于指向镜像或测试用的假上游。
 type APIRequestInfo struct {
+	BaseURL        string
 	APIID          string
 	APIKey         string
 	ProviderType   string
 	RequestTimeout time.Duration
+	Transport      TransportConfig
+	CountingMode   CountingMode
+	DataMultiplier float64
 }
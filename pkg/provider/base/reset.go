@@ -0,0 +1,25 @@
+package base
+
+import "time"
+
+// NextResetUnix 用于按指定时区计算每月重置日对应的下一次重置时间戳。
+// 重置日超过当月天数时（例如 31 日遇到 2 月）按当月最后一天处理，避免日期溢出到下个月。
+// 参数含义：now 为当前时间；resetDay 为每月重置日；loc 为重置规则所依据的时区。
+// 返回值：返回下一次重置时间的 Unix 时间戳。
+func NextResetUnix(now time.Time, resetDay int, loc *time.Location) int64 {
+	current := now.In(loc)
+	thisMonth := monthlyResetTime(current.Year(), current.Month(), resetDay, loc)
+	if current.Before(thisMonth) {
+		return thisMonth.Unix()
+	}
+	return monthlyResetTime(current.Year(), current.Month()+1, resetDay, loc).Unix()
+}
+
+// monthlyResetTime 用于计算指定月份的重置时间点，重置日会被限制在当月最后一天以内。
+// 参数含义：year 与 month 为目标月份，month 允许溢出到下一年；resetDay 为每月重置日；loc 为时区。
+// 返回值：返回该月重置日零点。
+func monthlyResetTime(year int, month time.Month, resetDay int, loc *time.Location) time.Time {
+	// 下个月第 0 天即为当月最后一天，借助 time.Date 的归一化得到当月天数。
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	return time.Date(year, month, min(resetDay, lastDay), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,123 @@
+package base
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// TestNextResetUnix_BeforeResetDay 验证当前日期在重置日之前时，返回本月重置日的时间戳。
+func TestNextResetUnix_BeforeResetDay(t *testing.T) {
+	t.Parallel()
+
+	// 构造一个"今天是 15 日，重置日是 20 日"的场景
+	now := time.Date(2026, 5, 15, 10, 0, 0, 0, time.Local)
+	resetDay := 20
+
+	got := NextResetUnix(now, resetDay, time.Local)
+	want := time.Date(2026, 5, 20, 0, 0, 0, 0, time.Local).Unix()
+
+	if got != want {
+		t.Fatalf("expected %d, got %d", want, got)
+	}
+}
+
+// TestNextResetUnix_OnResetDay 验证当前日期恰好等于重置日时，返回下月重置日的时间戳。
+func TestNextResetUnix_OnResetDay(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 5, 20, 10, 0, 0, 0, time.Local)
+	resetDay := 20
+
+	got := NextResetUnix(now, resetDay, time.Local)
+	want := time.Date(2026, 6, 20, 0, 0, 0, 0, time.Local).Unix()
+
+	if got != want {
+		t.Fatalf("expected %d, got %d", want, got)
+	}
+}
+
+// TestNextResetUnix_AfterResetDay 验证当前日期在重置日之后时，返回下月重置日的时间戳。
+func TestNextResetUnix_AfterResetDay(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 5, 25, 10, 0, 0, 0, time.Local)
+	resetDay := 20
+
+	got := NextResetUnix(now, resetDay, time.Local)
+	want := time.Date(2026, 6, 20, 0, 0, 0, 0, time.Local).Unix()
+
+	if got != want {
+		t.Fatalf("expected %d, got %d", want, got)
+	}
+}
+
+// TestNextResetUnix_YearRollover 验证 12 月时能正确跨年到次年 1 月。
+func TestNextResetUnix_YearRollover(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 12, 15, 10, 0, 0, 0, time.Local)
+	resetDay := 10
+
+	got := NextResetUnix(now, resetDay, time.Local)
+	want := time.Date(2027, 1, 10, 0, 0, 0, 0, time.Local).Unix()
+
+	if got != want {
+		t.Fatalf("expected %d, got %d", want, got)
+	}
+}
+
+// TestNextResetUnix_UsesResetTimezone 验证重置时间必须按供应商重置时区计算，不能跟随服务本地时区。
+func TestNextResetUnix_UsesResetTimezone(t *testing.T) {
+	t.Parallel()
+
+	shanghai := time.FixedZone("Asia/Shanghai", 8*60*60)
+	losAngeles := time.FixedZone("America/Los_Angeles", -7*60*60)
+
+	// 服务在上海时间 5 月 1 日凌晨，但此时洛杉矶仍处于 4 月 30 日白天，下一次重置应为洛杉矶 5 月 1 日零点。
+	now := time.Date(2026, 5, 1, 0, 30, 0, 0, shanghai)
+	resetDay := 1
+
+	got := NextResetUnix(now, resetDay, losAngeles)
+	want := time.Date(2026, 5, 1, 0, 0, 0, 0, losAngeles).Unix()
+
+	if got != want {
+		t.Fatalf("expected %d, got %d", want, got)
+	}
+}
+
+// TestNextResetUnix_UsesLosAngelesDST 用于验证重置时间按洛杉矶时区计算时，会自动跟随夏令时切换。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestNextResetUnix_UsesLosAngelesDST(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	now := time.Date(2026, 3, 8, 1, 0, 0, 0, loc)
+	got := NextResetUnix(now, 1, loc)
+	want := time.Date(2026, 4, 1, 0, 0, 0, 0, loc).Unix()
+
+	if got != want {
+		t.Fatalf("expected %d, got %d", want, got)
+	}
+}
+
+// TestNextResetUnix_ClampsToLastDayOfMonth 用于验证重置日超过当月天数时按当月最后一天计算。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestNextResetUnix_ClampsToLastDayOfMonth(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 2, 10, 10, 0, 0, 0, time.UTC)
+
+	got := NextResetUnix(now, 31, time.UTC)
+	want := time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC).Unix()
+
+	if got != want {
+		t.Fatalf("expected %d, got %d", want, got)
+	}
+}
@@ -0,0 +1,273 @@
+package base
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDNSPort = "53"
+
+	// 服务商 API 通常只有少数几个域名，按账号复用长连接即可，没必要放开过大的空闲连接数。
+	transportMaxIdleConns        = 64
+	transportMaxIdleConnsPerHost = 8
+	transportIdleConnTimeout     = 90 * time.Second
+)
+
+// sharedTransports 按出站网络配置缓存 Transport，相同配置的服务商账号共用同一个连接池。
+var sharedTransports sync.Map
+
+// TransportConfig 表示访问服务商 API 时使用的出站网络配置，零值表示直接使用系统默认网络。
+// 字段含义：ProxyURL 为 HTTP/HTTPS/SOCKS5 代理地址；DNSServer 为自定义 DNS 服务器；
+// LocalAddr 为绑定的本地源地址；CAFile 为额外信任的 CA 证书文件；InsecureSkipVerify 为是否跳过证书校验。
+type TransportConfig struct {
+	ProxyURL           string
+	DNSServer          string
+	LocalAddr          string
+	CAFile             string
+	InsecureSkipVerify bool
+}
+
+// Validate 用于校验出站网络配置的静态合法性，避免错误配置拖到请求期才暴露。
+// 参数含义：无。
+// 返回值：配置合法时返回 nil，否则返回对应字段的错误。
+func (c TransportConfig) Validate() error {
+	if _, err := parseProxyURL(c.ProxyURL); err != nil {
+		return err
+	}
+
+	if _, err := normalizeDNSServer(c.DNSServer); err != nil {
+		return err
+	}
+
+	if _, err := parseLocalAddr(c.LocalAddr); err != nil {
+		return err
+	}
+
+	if c.CAFile != "" {
+		if _, err := os.Stat(c.CAFile); err != nil {
+			return fmt.Errorf("ca_file is unreadable: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// NewHTTPClient 用于按请求信息创建访问服务商 API 的 HTTP 客户端，统一收敛超时与出站网络配置。
+// 相同出站网络配置的客户端共享同一个 Transport，以便跨请求复用 keep-alive 连接。
+// 参数含义：info 为调用接口所需的认证信息、超时和出站网络配置。
+// 返回值：返回初始化完成的 HTTP 客户端；出站网络配置非法或 CA 文件读取失败时返回错误。
+func NewHTTPClient(info APIRequestInfo) (*http.Client, error) {
+	transport, err := SharedTransport(info.Transport)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   info.RequestTimeout,
+		Transport: transport,
+	}, nil
+}
+
+// SharedTransport 用于获取指定出站网络配置对应的共享 Transport，不存在时创建并缓存。
+// 参数含义：conf 为出站网络配置。
+// 返回值：返回共享 Transport；配置非法时返回错误且不会写入缓存。
+func SharedTransport(conf TransportConfig) (*http.Transport, error) {
+	if cached, ok := sharedTransports.Load(conf); ok {
+		return cached.(*http.Transport), nil
+	}
+
+	transport, err := NewTransport(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// 并发首次创建时只保留先写入的实例，落败的实例尚未建立任何连接，直接丢弃即可。
+	actual, _ := sharedTransports.LoadOrStore(conf, transport)
+	return actual.(*http.Transport), nil
+}
+
+// NewTransport 用于根据出站网络配置创建 HTTP Transport。
+// 参数含义：conf 为出站网络配置。
+// 返回值：返回可直接挂载到 HTTP 客户端上的 Transport；配置非法时返回错误。
+func NewTransport(conf TransportConfig) (*http.Transport, error) {
+	proxyURL, err := parseProxyURL(conf.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dnsServer, err := normalizeDNSServer(conf.DNSServer)
+	if err != nil {
+		return nil, err
+	}
+
+	localAddr, err := parseLocalAddr(conf.LocalAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if localAddr != nil {
+		dialer.LocalAddr = localAddr
+	}
+	if dnsServer != "" {
+		dialer.Resolver = newResolver(dnsServer, localAddr)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConns = transportMaxIdleConns
+	transport.MaxIdleConnsPerHost = transportMaxIdleConnsPerHost
+	transport.IdleConnTimeout = transportIdleConnTimeout
+
+	// 未配置代理时仍然尊重 HTTP_PROXY 等环境变量，保持与默认客户端一致的行为。
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if conf.CAFile != "" || conf.InsecureSkipVerify {
+		tlsConfig, err := newTLSConfig(conf.CAFile, conf.InsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// newResolver 用于创建固定走指定 DNS 服务器的解析器，并与 API 请求共用同一本地源地址。
+// 参数含义：dnsServer 为带端口的 DNS 服务器地址；localAddr 为可选的本地源地址。
+// 返回值：返回自定义解析器。
+func newResolver(dnsServer string, localAddr *net.TCPAddr) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			dnsDialer := &net.Dialer{Timeout: 5 * time.Second}
+			if localAddr != nil {
+				// DNS 查询可能走 UDP，需要按实际网络类型构造源地址。
+				if strings.HasPrefix(network, "udp") {
+					dnsDialer.LocalAddr = &net.UDPAddr{IP: localAddr.IP}
+				} else {
+					dnsDialer.LocalAddr = localAddr
+				}
+			}
+			return dnsDialer.DialContext(ctx, network, dnsServer)
+		},
+	}
+}
+
+// newTLSConfig 用于构造带自定义 CA 或跳过证书校验的 TLS 配置。
+// 参数含义：caFile 为额外信任的 PEM 证书文件；insecureSkipVerify 为是否跳过证书校验。
+// 返回值：返回 TLS 配置和证书读取错误。
+func newTLSConfig(caFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	// 仅在用户显式配置时跳过校验，用于内网自签证书等场景。
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	if caFile == "" {
+		return tlsConfig, nil
+	}
+
+	pemData, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca_file: %w", err)
+	}
+
+	// 在系统根证书基础上追加自定义 CA，避免替换后无法访问公共证书签发的接口。
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, errors.New("ca_file contains no valid PEM certificate")
+	}
+	tlsConfig.RootCAs = pool
+
+	return tlsConfig, nil
+}
+
+// parseProxyURL 用于解析并校验代理地址，仅支持 Go 标准库可直接使用的代理协议。
+// 参数含义：raw 为配置中的代理地址。
+// 返回值：返回解析后的代理地址；未配置时返回 nil。
+func parseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.New("proxy_url is invalid")
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, errors.New("proxy_url scheme must be http, https, socks5 or socks5h")
+	}
+
+	if proxyURL.Host == "" {
+		return nil, errors.New("proxy_url host is required")
+	}
+
+	return proxyURL, nil
+}
+
+// normalizeDNSServer 用于校验 DNS 服务器地址，并在未写端口时补齐默认 53 端口。
+// 参数含义：raw 为配置中的 DNS 服务器地址，支持 host 或 host:port。
+// 返回值：返回带端口的 DNS 服务器地址；未配置时返回空串。
+func normalizeDNSServer(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+
+	if host, port, err := net.SplitHostPort(raw); err == nil {
+		if host == "" || port == "" {
+			return "", errors.New("dns_server is invalid")
+		}
+		return raw, nil
+	}
+
+	// 裸 IPv6 地址不带方括号，需要先识别再补端口，避免被误判为 host:port。
+	host := strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]")
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return "", errors.New("dns_server is invalid")
+	}
+
+	return net.JoinHostPort(host, defaultDNSPort), nil
+}
+
+// parseLocalAddr 用于解析出站请求绑定的本地源 IP。
+// 参数含义：raw 为配置中的本地源地址，仅支持 IP 字面量。
+// 返回值：返回 TCP 本地地址；未配置时返回 nil。
+func parseLocalAddr(raw string) (*net.TCPAddr, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return nil, errors.New("local_addr must be an IP address")
+	}
+
+	return &net.TCPAddr{IP: ip}, nil
+}
@@ -0,0 +1,152 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+// TestNewHTTPClient_RoutesRequestThroughProxy 用于验证配置 proxy_url 后，服务商请求会经由代理转发。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestNewHTTPClient_RoutesRequestThroughProxy(t *testing.T) {
+	t.Parallel()
+
+	var gotRequestURI string
+
+	// HTTP 代理收到的是绝对 URI，这里直接应答以模拟代理转发成功。
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRequestURI = r.RequestURI
+		_, _ = w.Write([]byte("via-proxy"))
+	}))
+	defer proxy.Close()
+
+	httpCli, err := NewHTTPClient(APIRequestInfo{
+		RequestTimeout: time.Second,
+		Transport: TransportConfig{
+			ProxyURL: proxy.URL,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPClient returned error: %v", err)
+	}
+
+	body, err := DoGetRequest(context.Background(), httpCli, "http://provider.invalid/v1/getServiceInfo")
+	if err != nil {
+		t.Fatalf("DoGetRequest returned error: %v", err)
+	}
+
+	if string(body) != "via-proxy" {
+		t.Fatalf("unexpected body: %s", string(body))
+	}
+	if gotRequestURI != "http://provider.invalid/v1/getServiceInfo" {
+		t.Fatalf("expected absolute request uri through proxy, got %s", gotRequestURI)
+	}
+}
+
+// TestTransportConfigValidate_RejectsInvalidFields 用于验证非法代理协议、DNS 地址和本地地址都会在校验阶段被拒绝。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestTransportConfigValidate_RejectsInvalidFields(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]TransportConfig{
+		"proxy scheme": {ProxyURL: "ftp://127.0.0.1:21"},
+		"proxy host":   {ProxyURL: "socks5://"},
+		"dns server":   {DNSServer: "1.1.1.1:"},
+		"local addr":   {LocalAddr: "eth0"},
+		"ca file":      {CAFile: "/nonexistent/ca.pem"},
+	}
+
+	for name, conf := range cases {
+		if err := conf.Validate(); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+
+	valid := TransportConfig{
+		ProxyURL:  "socks5://127.0.0.1:1080",
+		DNSServer: "1.1.1.1",
+		LocalAddr: "127.0.0.1",
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid transport config, got: %v", err)
+	}
+}
+
+// TestNormalizeDNSServer_AppendsDefaultPort 用于验证 DNS 服务器未写端口时会补齐 53 端口，且兼容裸 IPv6 地址。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestNormalizeDNSServer_AppendsDefaultPort(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"1.1.1.1":         "1.1.1.1:53",
+		"1.1.1.1:5353":    "1.1.1.1:5353",
+		"2606:4700::1111": "[2606:4700::1111]:53",
+		"dns.example":     "dns.example:53",
+	}
+
+	for input, want := range cases {
+		got, err := normalizeDNSServer(input)
+		if err != nil {
+			t.Fatalf("normalizeDNSServer(%q) returned error: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("normalizeDNSServer(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// TestNewHTTPClient_ReusesConnectionAcrossClients 用于验证相同出站配置的客户端共享 Transport，并复用到同一主机的空闲连接。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestNewHTTPClient_ReusesConnectionAcrossClients(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// 使用独立的 DNS 配置作为缓存键，避免与其他并行测试共用同一个连接池。
+	info := APIRequestInfo{
+		RequestTimeout: time.Second,
+		Transport: TransportConfig{
+			DNSServer: "127.0.0.1:5399",
+		},
+	}
+
+	first, err := NewHTTPClient(info)
+	if err != nil {
+		t.Fatalf("NewHTTPClient returned error: %v", err)
+	}
+	second, err := NewHTTPClient(info)
+	if err != nil {
+		t.Fatalf("NewHTTPClient returned error: %v", err)
+	}
+
+	if first.Transport != second.Transport {
+		t.Fatalf("expected clients with same transport config to share transport")
+	}
+
+	var reused []bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = append(reused, info.Reused)
+		},
+	})
+
+	for _, httpCli := range []*http.Client{first, second} {
+		if _, err := DoGetRequest(ctx, httpCli, server.URL); err != nil {
+			t.Fatalf("DoGetRequest returned error: %v", err)
+		}
+	}
+
+	if len(reused) != 2 || reused[0] || !reused[1] {
+		t.Fatalf("expected second request to reuse connection, got %v", reused)
+	}
+}
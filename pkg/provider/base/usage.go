@@ -0,0 +1,82 @@
+package base
+
+// CountingMode 表示服务商的流量计费方向，空值表示沿用服务商默认的上传/下载各半拆分。
+type CountingMode string
+
+const (
+	CountingModeBoth        CountingMode = "both"
+	CountingModeEgressOnly  CountingMode = "egress_only"
+	CountingModeIngressOnly CountingMode = "ingress_only"
+	CountingModeMax         CountingMode = "max"
+)
+
+// IsValidCountingMode 用于校验计费方向是否属于支持的范围，空串表示未配置。
+// 参数含义：mode 为配置中的计费方向。
+// 返回值：合法时返回 true。
+func IsValidCountingMode(mode string) bool {
+	switch CountingMode(mode) {
+	case "", CountingModeBoth, CountingModeEgressOnly, CountingModeIngressOnly, CountingModeMax:
+		return true
+	default:
+		return false
+	}
+}
+
+// SplitUsage 用于把只返回合计用量的服务商计量值按计费方向拆分为上传与下载。
+// 约定 VPS 出站（egress）对应下载、入站（ingress）对应上传。
+// 参数含义：used 为服务商计费口径下的已用流量；mode 为计费方向。
+// 返回值：返回拆分后的上传和下载字节数。
+func SplitUsage(used int64, mode CountingMode) (int64, int64) {
+	switch mode {
+	case CountingModeEgressOnly, CountingModeMax:
+		// 合计值无法区分方向，按较大方向计费时只能把合计值视为单方向用量，统一归入下载。
+		return 0, used
+	case CountingModeIngressOnly:
+		return used, 0
+	default:
+		// 双向计费或未配置时无法区分方向，各取一半作为近似值。
+		half := used / 2
+		return half, half
+	}
+}
+
+// BilledUsage 用于把服务商返回的原始合计用量按计费系数换算后，再按计费方向拆分为上传与下载。
+// 计费系数只作用于已用流量，总量始终取服务商给出的套餐额度；账号配置了计费系数时由调用方用它替代服务商自身的系数
+// （如 BandwagonHost 的 monthly_data_multiplier），两者不会叠加。
+// 参数含义：used 为未经换算的已用流量；mode 为计费方向；multiplier 为生效的计费系数，不大于 0 表示不换算。
+// 返回值：返回换算并拆分后的上传和下载字节数。
+func BilledUsage(used int64, mode CountingMode, multiplier float64) (int64, int64) {
+	return SplitUsage(applyMultiplier(used, multiplier), mode)
+}
+
+// BilledDirections 用于把服务商按方向返回的上传与下载用量按计费系数换算后，再按计费方向取舍。
+// 仅入站或仅出站时丢弃另一方向；按较大方向计费时只保留较大的一侧，另一侧记为 0。
+// 参数含义：upload 与 download 为未经换算的入站与出站用量；mode 为计费方向；multiplier 为生效的计费系数，不大于 0 表示不换算。
+// 返回值：返回计费口径下的上传和下载字节数。
+func BilledDirections(upload, download int64, mode CountingMode, multiplier float64) (int64, int64) {
+	upload, download = applyMultiplier(upload, multiplier), applyMultiplier(download, multiplier)
+	switch mode {
+	case CountingModeEgressOnly:
+		return 0, download
+	case CountingModeIngressOnly:
+		return upload, 0
+	case CountingModeMax:
+		if upload > download {
+			return upload, 0
+		}
+		return 0, download
+	default:
+		return upload, download
+	}
+}
+
+// applyMultiplier 用于按计费系数换算流量值，系数不大于 0 时视为未配置并原样返回。
+// 参数含义：value 为原始字节数；multiplier 为计费系数。
+// 返回值：返回换算后的字节数。
+func applyMultiplier(value int64, multiplier float64) int64 {
+	if multiplier <= 0 {
+		return value
+	}
+
+	return int64(float64(value) * multiplier)
+}
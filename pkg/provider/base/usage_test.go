@@ -0,0 +1,73 @@
+package base
+
+import "testing"
+
+// TestSplitUsage_FollowsCountingMode 用于验证合计用量会按计费方向拆分，未配置时回退为各取一半。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestSplitUsage_FollowsCountingMode(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		mode         CountingMode
+		wantUpload   int64
+		wantDownload int64
+	}{
+		{mode: "", wantUpload: 50, wantDownload: 50},
+		{mode: CountingModeBoth, wantUpload: 50, wantDownload: 50},
+		{mode: CountingModeEgressOnly, wantUpload: 0, wantDownload: 100},
+		{mode: CountingModeIngressOnly, wantUpload: 100, wantDownload: 0},
+		{mode: CountingModeMax, wantUpload: 0, wantDownload: 100},
+	}
+
+	for _, tc := range cases {
+		upload, download := SplitUsage(100, tc.mode)
+		if upload != tc.wantUpload || download != tc.wantDownload {
+			t.Fatalf("mode %q: expected upload=%d download=%d, got upload=%d download=%d",
+				tc.mode, tc.wantUpload, tc.wantDownload, upload, download)
+		}
+	}
+}
+
+// TestBilledUsage_AppliesMultiplierBeforeSplit 用于验证计费系数未配置时不换算，配置后先换算已用流量再按计费方向拆分。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestBilledUsage_AppliesMultiplierBeforeSplit(t *testing.T) {
+	t.Parallel()
+
+	if upload, download := BilledUsage(100, CountingModeEgressOnly, 0); upload != 0 || download != 100 {
+		t.Fatalf("expected usage unchanged for zero multiplier, got upload=%d download=%d", upload, download)
+	}
+
+	if upload, download := BilledUsage(100, "", 1.5); upload != 75 || download != 75 {
+		t.Fatalf("expected 150 split evenly, got upload=%d download=%d", upload, download)
+	}
+}
+
+// TestBilledDirections_FollowsCountingMode 用于验证分方向用量会先按计费系数换算，再按计费方向取舍，按较大方向计费时只保留较大一侧。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestBilledDirections_FollowsCountingMode(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		upload       int64
+		mode         CountingMode
+		wantUpload   int64
+		wantDownload int64
+	}{
+		{upload: 30, mode: "", wantUpload: 60, wantDownload: 140},
+		{upload: 30, mode: CountingModeEgressOnly, wantUpload: 0, wantDownload: 140},
+		{upload: 30, mode: CountingModeIngressOnly, wantUpload: 60, wantDownload: 0},
+		{upload: 30, mode: CountingModeMax, wantUpload: 0, wantDownload: 140},
+		{upload: 90, mode: CountingModeMax, wantUpload: 180, wantDownload: 0},
+	}
+
+	for _, tc := range cases {
+		upload, download := BilledDirections(tc.upload, 70, tc.mode, 2)
+		if upload != tc.wantUpload || download != tc.wantDownload {
+			t.Fatalf("mode %q upload %d: expected upload=%d download=%d, got upload=%d download=%d",
+				tc.mode, tc.upload, tc.wantUpload, tc.wantDownload, upload, download)
+		}
+	}
+}
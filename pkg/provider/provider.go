@@ -36,9 +36,9 @@ func IsValidProvider(providerType string) bool {
 func NewProvider(info base.APIRequestInfo) (Provider, error) {
 	switch info.ProviderType {
 	case ProviderType_BandwagonHost:
-		return bandwagonhost.New(info), nil
+		return bandwagonhost.New(info)
 	case ProviderType_Racknerd:
-		return racknerd.New(info), nil
+		return racknerd.New(info)
 	case ProviderType_Passthrough:
 		return passthrough.New(info), nil
 	default:
@@ -0,0 +1,32 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/djx30103/vpsub/pkg/provider"
+	"github.com/djx30103/vpsub/pkg/provider/providertest"
+)
+
+// TestConformance_BandwagonHost 用于回放 BandwagonHost 录制响应，验证客户端满足统一流量信息约束。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestConformance_BandwagonHost(t *testing.T) {
+	t.Parallel()
+
+	providertest.Run(t, provider.ProviderType_BandwagonHost, "testdata/bandwagonhost", providertest.Options{
+		APIID:  "veid-1",
+		APIKey: "key-1",
+	})
+}
+
+// TestConformance_RackNerd 用于回放 RackNerd 录制响应，验证客户端满足统一流量信息约束。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestConformance_RackNerd(t *testing.T) {
+	t.Parallel()
+
+	providertest.Run(t, provider.ProviderType_Racknerd, "testdata/racknerd", providertest.Options{
+		APIID:  "hash",
+		APIKey: "key",
+	})
+}
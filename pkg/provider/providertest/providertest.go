@@ -0,0 +1,266 @@
+// Package providertest 提供服务商客户端的契约测试工具：回放录制的上游响应，并断言统一流量信息满足基本约束。
+//
+// 录制文件放在 testdata 目录下，每个文件是一条完整的原始 HTTP 响应（状态行、响应头、空行、响应体）。
+// 文件名以 ok_ 开头表示期望解析成功，以 err_ 开头表示期望返回错误，扩展名固定为 .http。
+// 响应中的 ${FUTURE_UNIX} 会在回放时替换为当前时间之后的时间戳，避免录制数据随时间过期。
+package providertest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/djx30103/vpsub/pkg/provider"
+	"github.com/djx30103/vpsub/pkg/provider/base"
+)
+
+const (
+	fixtureExt       = ".http"
+	okPrefix         = "ok_"
+	errPrefix        = "err_"
+	futureUnixMarker = "${FUTURE_UNIX}"
+
+	// 回放场景下请求超时取较小值，超时用例的上游延迟需明显大于该值。
+	replayRequestTimeout = 500 * time.Millisecond
+	timeoutRequestLimit  = 50 * time.Millisecond
+	timeoutUpstreamDelay = 500 * time.Millisecond
+)
+
+// Options 表示契约测试的可选参数。
+// 字段含义：APIID 与 APIKey 为构造客户端使用的认证信息；UsedTolerance 为已用流量允许超出总量的比例，例如 0.1 表示 10%。
+type Options struct {
+	APIID         string
+	APIKey        string
+	UsedTolerance float64
+}
+
+// Fixture 表示一条录制的上游响应。
+// 字段含义：Name 为用例名；WantErr 为是否期望客户端返回错误；StatusCode、Header 与 Body 为回放内容。
+type Fixture struct {
+	Name       string
+	WantErr    bool
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Run 用于对指定类型的服务商回放目录下的全部录制响应，并额外执行一次上游超时用例。
+// 参数含义：t 为测试上下文；providerType 为已注册的服务商类型；dir 为录制文件目录；opts 为可选参数。
+// 返回值：无，断言失败时由 testing 框架终止对应子测试。
+func Run(t *testing.T, providerType string, dir string, opts Options) {
+	t.Helper()
+
+	fixtures := LoadFixtures(t, dir)
+	if len(fixtures) == 0 {
+		t.Fatalf("no fixtures found in %s", dir)
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			t.Parallel()
+
+			server, hits := NewReplayServer(fixture, 0)
+			defer server.Close()
+
+			info, err := newClientAndQuery(t, providerType, server.URL, replayRequestTimeout, opts)
+			if hits.Load() == 0 {
+				t.Fatalf("expected provider to call upstream")
+			}
+
+			if fixture.WantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", info)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("GetServiceInfo returned error: %v", err)
+			}
+			AssertInvariants(t, info, time.Now(), opts.UsedTolerance)
+		})
+	}
+
+	t.Run("err_timeout", func(t *testing.T) {
+		t.Parallel()
+
+		// 超时用例复用第一条成功录制，确保错误只来自上游延迟而不是响应内容。
+		fixture := fixtures[0]
+		for _, candidate := range fixtures {
+			if !candidate.WantErr {
+				fixture = candidate
+				break
+			}
+		}
+
+		server, _ := NewReplayServer(fixture, timeoutUpstreamDelay)
+		defer server.Close()
+
+		if _, err := newClientAndQuery(t, providerType, server.URL, timeoutRequestLimit, opts); err == nil {
+			t.Fatalf("expected timeout error")
+		}
+	})
+}
+
+// LoadFixtures 用于读取目录下全部 .http 录制文件，并按文件名前缀推断期望结果。
+// 参数含义：t 为测试上下文；dir 为录制文件目录。
+// 返回值：返回按文件名排序的录制响应列表。
+func LoadFixtures(t *testing.T, dir string) []Fixture {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+fixtureExt))
+	if err != nil {
+		t.Fatalf("failed to list fixtures: %v", err)
+	}
+
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), fixtureExt)
+
+		var wantErr bool
+		switch {
+		case strings.HasPrefix(name, okPrefix):
+		case strings.HasPrefix(name, errPrefix):
+			wantErr = true
+		default:
+			t.Fatalf("fixture %s must start with %s or %s", name, okPrefix, errPrefix)
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read fixture %s: %v", name, err)
+		}
+
+		fixture, err := parseFixture(raw)
+		if err != nil {
+			t.Fatalf("failed to parse fixture %s: %v", name, err)
+		}
+		fixture.Name = name
+		fixture.WantErr = wantErr
+
+		fixtures = append(fixtures, fixture)
+	}
+
+	return fixtures
+}
+
+// NewReplayServer 用于创建按录制内容应答的假上游服务。
+// 参数含义：fixture 为录制响应；delay 为每次应答前的等待时间，用于模拟上游超时。
+// 返回值：返回测试服务和请求计数器，调用方负责关闭服务。
+func NewReplayServer(fixture Fixture, delay time.Duration) (*httptest.Server, *atomic.Int64) {
+	hits := new(atomic.Int64)
+	body := bytes.ReplaceAll(fixture.Body, []byte(futureUnixMarker), []byte(strconv.FormatInt(time.Now().Add(30*24*time.Hour).Unix(), 10)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+
+		if delay > 0 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		for key, values := range fixture.Header {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+		w.WriteHeader(fixture.StatusCode)
+		_, _ = w.Write(body)
+	}))
+
+	return server, hits
+}
+
+// AssertInvariants 用于断言统一流量信息满足所有服务商都必须遵守的基本约束。
+// 参数含义：t 为测试上下文；info 为待校验的流量信息；now 为当前时间；usedTolerance 为已用流量允许超出总量的比例。
+// 返回值：无，约束不满足时终止当前测试。
+func AssertInvariants(t *testing.T, info *base.APIResponseInfo, now time.Time, usedTolerance float64) {
+	t.Helper()
+
+	if info == nil {
+		t.Fatalf("expected service info, got nil")
+	}
+
+	if info.Total <= 0 {
+		t.Fatalf("expected total > 0, got %d", info.Total)
+	}
+
+	if info.Upload < 0 || info.Download < 0 {
+		t.Fatalf("expected non-negative usage, got upload=%d download=%d", info.Upload, info.Download)
+	}
+
+	used := info.Upload + info.Download
+	if limit := float64(info.Total) * (1 + usedTolerance); float64(used) > limit {
+		t.Fatalf("expected used <= total with tolerance %.2f, got used=%d total=%d", usedTolerance, used, info.Total)
+	}
+
+	if info.ResetAt == 0 && info.ServiceExpireAt == 0 {
+		t.Fatalf("expected reset_at or service_expire_at to be set")
+	}
+
+	if info.ResetAt != 0 && info.ResetAt <= now.Unix() {
+		t.Fatalf("expected reset_at in the future, got %s", time.Unix(info.ResetAt, 0))
+	}
+
+	if info.ServiceExpireAt != 0 && info.ServiceExpireAt <= now.Unix() {
+		t.Fatalf("expected service_expire_at in the future, got %s", time.Unix(info.ServiceExpireAt, 0))
+	}
+}
+
+// newClientAndQuery 用于通过服务商注册表创建指向假上游的客户端，并查询一次流量信息。
+// 参数含义：t 为测试上下文；providerType 为服务商类型；baseURL 为假上游地址；timeout 为请求超时；opts 为可选参数。
+// 返回值：返回流量信息和查询错误。
+func newClientAndQuery(t *testing.T, providerType, baseURL string, timeout time.Duration, opts Options) (*base.APIResponseInfo, error) {
+	t.Helper()
+
+	client, err := provider.NewProvider(base.APIRequestInfo{
+		BaseURL:        baseURL,
+		APIID:          opts.APIID,
+		APIKey:         opts.APIKey,
+		ProviderType:   providerType,
+		RequestTimeout: timeout,
+	})
+	if err != nil {
+		t.Fatalf("failed to create provider %s: %v", providerType, err)
+	}
+
+	return client.GetServiceInfo(context.Background())
+}
+
+// parseFixture 用于把原始 HTTP 响应文本解析为录制响应。
+// 参数含义：raw 为录制文件内容，允许使用 \n 作为换行。
+// 返回值：返回解析后的录制响应和解析错误。
+func parseFixture(raw []byte) (Fixture, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), nil)
+	if err != nil {
+		return Fixture{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Fixture{}, err
+	}
+
+	// 回放时由测试服务重新计算长度，避免录制文件手工编辑后长度不一致。
+	resp.Header.Del("Content-Length")
+
+	return Fixture{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
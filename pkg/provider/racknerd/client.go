@@ -1,6 +1,7 @@
 package racknerd
 
 import (
+	"cmp"
 	"context"
 	"encoding/csv"
 	"errors"
@@ -28,22 +29,31 @@ type Client struct {
 	apiKey  string
 	apiHash string
 
+	countingMode   base.CountingMode
+	dataMultiplier float64
+
 	baseURL string
 	httpCli *http.Client
 }
 
 // New 用于根据配置创建 RackNerd API 客户端。
-// 参数含义：info 为调用接口所需的认证信息和请求超时配置。
-// 返回值：返回初始化完成的 RackNerd 客户端。
-func New(info base.APIRequestInfo) *Client {
+// 参数含义：info 为调用接口所需的认证信息、请求超时和出站网络配置。
+// 返回值：返回初始化完成的 RackNerd 客户端；出站网络配置非法时返回错误。
+func New(info base.APIRequestInfo) (*Client, error) {
+	httpCli, err := base.NewHTTPClient(info)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Client{
 		apiHash: info.APIID,
 		apiKey:  info.APIKey,
-		baseURL: "https://nerdvm.racknerd.com",
-		httpCli: &http.Client{
-			Timeout: info.RequestTimeout,
-		},
-	}
+		baseURL: cmp.Or(info.BaseURL, "https://nerdvm.racknerd.com"),
+		httpCli: httpCli,
+
+		countingMode:   info.CountingMode,
+		dataMultiplier: info.DataMultiplier,
+	}, nil
 }
 
 // GetServiceInfo 用于查询 RackNerd 当前服务的流量使用情况。
@@ -66,7 +76,7 @@ func (c *Client) GetServiceInfo(ctx context.Context) (*base.APIResponseInfo, err
 		return nil, err
 	}
 
-	info, err := parseServiceInfoResponse(string(body))
+	info, err := parseServiceInfoResponse(string(body), c.countingMode, c.dataMultiplier)
 	if err != nil {
 		return nil, err
 	}
@@ -74,27 +84,16 @@ func (c *Client) GetServiceInfo(ctx context.Context) (*base.APIResponseInfo, err
 	// RackNerd 流量每月 1 日固定重置（太平洋时区），API 不返回该字段，此处直接硬编码。
 	// 来源：https://lowendtalk.com/discussion/185395/racknerd-vps-bandwidth
 	now := time.Now()
-	info.Expire = nextResetUnix(now, 1, rackNerdResetLocation)
+	info.ResetAt = base.NextResetUnix(now, 1, rackNerdResetLocation)
+	info.ResetLocation = rackNerdResetLocation
 
 	return info, nil
 }
 
-// nextResetUnix 用于按指定时区计算每月重置日对应的下一次重置时间戳。
-// 参数含义：now 为当前时间；resetDay 为每月重置日；loc 为重置规则所依据的时区。
-// 返回值：返回下一次重置时间的 Unix 时间戳。
-func nextResetUnix(now time.Time, resetDay int, loc *time.Location) int64 {
-	current := now.In(loc)
-	thisMonth := time.Date(current.Year(), current.Month(), resetDay, 0, 0, 0, 0, loc)
-	if current.Before(thisMonth) {
-		return thisMonth.Unix()
-	}
-	return time.Date(current.Year(), current.Month()+1, resetDay, 0, 0, 0, 0, loc).Unix()
-}
-
 // parseServiceInfoResponse 用于解析 RackNerd 返回的原始流量响应。
-// 参数含义：raw 为 RackNerd 接口返回的原始文本。
+// 参数含义：raw 为 RackNerd 接口返回的原始文本；mode 为计费方向；multiplier 为已用流量的计费系数，不大于 0 表示不换算。
 // 返回值：返回统一格式的流量信息；若字段缺失、格式非法或数值异常则返回错误。
-func parseServiceInfoResponse(raw string) (*base.APIResponseInfo, error) {
+func parseServiceInfoResponse(raw string, mode base.CountingMode, multiplier float64) (*base.APIResponseInfo, error) {
 	// 上游返回的是类 CSV 文本，且部分场景下数值字段会被双引号包裹，这里统一按 CSV 解析以兼容两种格式。
 	reader := csv.NewReader(strings.NewReader(raw))
 	reader.FieldsPerRecord = -1
@@ -125,11 +124,12 @@ func parseServiceInfoResponse(raw string) (*base.APIResponseInfo, error) {
 		return nil, errors.New("failed to get service info, total or used is 0")
 	}
 
-	// RackNerd API 只返回总用量，不区分上传和下载，因此各取一半作为近似值。
+	// RackNerd API 只返回总用量，不区分上传和下载，按计费方向拆分，未配置时各取一半。
+	upload, download := base.BilledUsage(used, mode, multiplier)
+
 	return &base.APIResponseInfo{
-		Upload:   used / 2,
-		Download: used / 2,
+		Upload:   upload,
+		Download: download,
 		Total:    total,
-		Expire:   0,
 	}, nil
 }
@@ -0,0 +1,231 @@
+// Package region 用于根据节点名称与服务器地址推断节点所在地区，并生成对应的旗帜 emoji。
+package region
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// regionKeywords 为各地区的识别关键字，代码统一使用 ISO 3166-1 alpha-2。
+// ASCII 关键字按单词边界匹配，避免 "US" 命中 "Russia" 之类的误判；中文关键字按子串匹配。
+// 全大写的关键字为地区或机场代码，名称中需同为大写（如 "HK 01"），或为小写且紧邻 "-"、"_"、数字（如 "us-west-1"、"jp01"），
+// 避免 "My VPS"、"ca" 之类的普通单词被识别为地区；其余地名不区分大小写。
+// 两位代码本身只收录不易与普通单词混淆的地区，例如不收录 IN、ID。
+var regionKeywords = map[string][]string{
+	"HK": {"HK", "HKG", "Hong Kong", "HongKong", "香港"},
+	"MO": {"MO", "Macau", "Macao", "澳门", "澳門"},
+	"TW": {"TW", "TPE", "Taiwan", "台湾", "臺灣", "台北"},
+	"JP": {"JP", "Japan", "Tokyo", "Osaka", "NRT", "日本", "东京", "東京", "大阪"},
+	"KR": {"KR", "Korea", "Seoul", "ICN", "韩国", "韓國", "首尔"},
+	"SG": {"SG", "SIN", "Singapore", "新加坡", "狮城"},
+	"US": {"US", "USA", "United States", "America", "Los Angeles", "San Jose", "Seattle", "LAX", "SJC", "美国", "美國", "洛杉矶", "圣何塞", "西雅图"},
+	"CA": {"CA", "Canada", "Toronto", "加拿大"},
+	"GB": {"UK", "GB", "United Kingdom", "Britain", "London", "英国", "英國", "伦敦"},
+	"DE": {"DE", "Germany", "Frankfurt", "FRA", "德国", "德國", "法兰克福"},
+	"FR": {"FR", "France", "Paris", "法国", "法國", "巴黎"},
+	"NL": {"NL", "Netherlands", "Amsterdam", "荷兰", "荷蘭", "阿姆斯特丹"},
+	"RU": {"RU", "Russia", "Moscow", "俄罗斯", "俄羅斯", "莫斯科"},
+	"TR": {"TR", "Turkey", "Istanbul", "土耳其"},
+	"IN": {"India", "Mumbai", "印度"},
+	"ID": {"Indonesia", "Jakarta", "印尼", "印度尼西亚"},
+	"MY": {"MY", "Malaysia", "马来西亚", "馬來西亞"},
+	"TH": {"Thailand", "Bangkok", "泰国", "泰國"},
+	"VN": {"VN", "Vietnam", "越南"},
+	"PH": {"PH", "Philippines", "菲律宾", "菲律賓"},
+	"AU": {"AU", "Australia", "Sydney", "澳大利亚", "澳洲", "悉尼"},
+	"BR": {"BR", "Brazil", "巴西"},
+	"AR": {"AR", "Argentina", "阿根廷"},
+}
+
+// tldRegions 为可以直接对应到地区的国家顶级域名，只收录上表中的地区。
+var tldRegions = map[string]string{
+	"hk": "HK", "mo": "MO", "tw": "TW", "jp": "JP", "kr": "KR", "sg": "SG",
+	"us": "US", "ca": "CA", "uk": "GB", "de": "DE", "fr": "FR", "nl": "NL",
+	"ru": "RU", "tr": "TR", "in": "IN", "id": "ID", "my": "MY", "th": "TH",
+	"vn": "VN", "ph": "PH", "au": "AU", "br": "BR", "ar": "AR",
+}
+
+// Detect 用于推断节点所在地区，依次尝试名称中的旗帜 emoji、名称关键字与服务器地址的国家顶级域名。
+// 名称中同时出现多个地区时，以最靠前出现的为准；服务器地址为 IP 时不做归属地查询，只能依靠名称识别。
+// 参数含义：name 为节点名称；server 为节点服务器地址。
+// 返回值：返回大写的两位地区代码，无法识别时返回空串。
+func Detect(name string, server string) string {
+	if code := detectFlag(name); code != "" {
+		return code
+	}
+
+	if code := detectKeyword(name); code != "" {
+		return code
+	}
+
+	return detectTLD(server)
+}
+
+// Flag 用于把两位地区代码转换为旗帜 emoji。
+// 参数含义：code 为两位地区代码，大小写均可。
+// 返回值：返回旗帜 emoji，代码非法时返回空串。
+func Flag(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+
+	code = strings.ToUpper(code)
+	var builder strings.Builder
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+		builder.WriteRune(0x1F1E6 + r - 'A')
+	}
+
+	return builder.String()
+}
+
+// HasLeadingFlag 用于判断名称是否已以旗帜 emoji 开头，开头的空白不计入。
+// 参数含义：name 为节点名称。
+// 返回值：名称开头为一对区域指示符时返回 true。
+func HasLeadingFlag(name string) bool {
+	runes := []rune(strings.TrimSpace(name))
+	return len(runes) >= 2 && isRegionalIndicator(runes[0]) && isRegionalIndicator(runes[1])
+}
+
+// detectFlag 用于识别名称中第一个旗帜 emoji 对应的地区代码。
+func detectFlag(name string) string {
+	runes := []rune(name)
+	for i := 0; i+1 < len(runes); i++ {
+		if isRegionalIndicator(runes[i]) && isRegionalIndicator(runes[i+1]) {
+			return string([]rune{'A' + runes[i] - 0x1F1E6, 'A' + runes[i+1] - 0x1F1E6})
+		}
+	}
+
+	return ""
+}
+
+// isRegionalIndicator 用于判断字符是否为组成旗帜 emoji 的区域指示符。
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// detectKeyword 用于按关键字识别名称中最靠前出现的地区。
+func detectKeyword(name string) string {
+	lowerName := strings.ToLower(name)
+	best, bestIndex := "", -1
+	for code, keywords := range regionKeywords {
+		for _, keyword := range keywords {
+			var index int
+			if isCode(keyword) {
+				index = indexCode(name, keyword)
+			} else {
+				index = indexKeyword(lowerName, strings.ToLower(keyword))
+			}
+			if index < 0 {
+				continue
+			}
+			// 位置相同时取地区代码较小者，保证 map 遍历顺序不影响结果。
+			if bestIndex < 0 || index < bestIndex || (index == bestIndex && code < best) {
+				best, bestIndex = code, index
+			}
+		}
+	}
+
+	return best
+}
+
+// indexKeyword 用于查找关键字在名称中的位置，ASCII 关键字要求前后不是字母。
+// 参数含义：name 与 keyword 均为小写字符串。
+// 返回值：返回第一个满足条件的字节位置，未找到时返回 -1。
+func indexKeyword(name string, keyword string) int {
+	if !isASCII(keyword) {
+		return strings.Index(name, keyword)
+	}
+
+	for offset := 0; offset < len(name); {
+		index := strings.Index(name[offset:], keyword)
+		if index < 0 {
+			return -1
+		}
+		start := offset + index
+		end := start + len(keyword)
+
+		before, _ := utf8.DecodeLastRuneInString(name[:start])
+		after, _ := utf8.DecodeRuneInString(name[end:])
+		if (start == 0 || !isASCIILetter(before)) && (end == len(name) || !isASCIILetter(after)) {
+			return start
+		}
+		offset = start + 1
+	}
+
+	return -1
+}
+
+// indexCode 用于查找地区或机场代码在名称中的位置，名称中的代码需为全大写，或为小写且紧邻 "-"、"_" 或数字。
+// 参数含义：name 为原始名称；code 为全大写的代码。
+// 返回值：返回第一个满足条件的字节位置，未找到时返回 -1。
+func indexCode(name string, code string) int {
+	if index := indexKeyword(name, code); index >= 0 {
+		return index
+	}
+
+	lowerCode := strings.ToLower(code)
+	for offset := 0; offset < len(name); {
+		index := indexKeyword(name[offset:], lowerCode)
+		if index < 0 {
+			return -1
+		}
+		start := offset + index
+		end := start + len(lowerCode)
+
+		before, _ := utf8.DecodeLastRuneInString(name[:start])
+		after, _ := utf8.DecodeRuneInString(name[end:])
+		if isHostnameDelimiter(before) || isHostnameDelimiter(after) {
+			return start
+		}
+		offset = start + 1
+	}
+
+	return -1
+}
+
+// isCode 用于判断关键字是否为全大写的地区或机场代码。
+func isCode(keyword string) bool {
+	for i := 0; i < len(keyword); i++ {
+		if keyword[i] < 'A' || keyword[i] > 'Z' {
+			return false
+		}
+	}
+
+	return keyword != ""
+}
+
+// isHostnameDelimiter 用于判断字符是否为主机名风格命名中常见的分隔符或数字。
+func isHostnameDelimiter(r rune) bool {
+	return r == '-' || r == '_' || (r >= '0' && r <= '9')
+}
+
+// detectTLD 用于按服务器地址的国家顶级域名识别地区，IP 地址没有顶级域名，不做识别。
+func detectTLD(server string) string {
+	server = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(server)), ".")
+	index := strings.LastIndex(server, ".")
+	if index < 0 {
+		return ""
+	}
+
+	return tldRegions[server[index+1:]]
+}
+
+// isASCII 用于判断字符串是否只包含 ASCII 字符。
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isASCIILetter 用于判断字符是否为 ASCII 字母。
+func isASCIILetter(r rune) bool {
+	return r < utf8.RuneSelf && unicode.IsLetter(r)
+}
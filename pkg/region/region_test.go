@@ -0,0 +1,68 @@
+package region
+
+import "testing"
+
+// TestDetect_按旗帜关键字与顶级域名识别地区 用于验证地区识别的优先级与单词边界匹配。
+// 参数含义：t 为测试上下文，用于驱动断言和失败输出。
+// 返回值：无，测试失败时由 testing 框架终止当前用例。
+func TestDetect_按旗帜关键字与顶级域名识别地区(t *testing.T) {
+	cases := []struct {
+		name   string
+		server string
+		want   string
+	}{
+		{name: "🇯🇵 Tokyo 01", server: "hk.example.com", want: "JP"},
+		{name: "香港 IPLC 02", server: "1.2.3.4", want: "HK"},
+		{name: "us-west-1", server: "", want: "US"},
+		{name: "Russia 01", server: "", want: "RU"},
+		{name: "Status", server: "", want: ""},
+		{name: "美国 中转 香港", server: "", want: "US"},
+		{name: "node-01", server: "edge.example.sg", want: "SG"},
+		{name: "node-02", server: "10.0.0.1", want: ""},
+		{name: "London Premium", server: "", want: "GB"},
+		{name: "My VPS", server: "", want: ""},
+		{name: "ca node", server: "", want: ""},
+		{name: "MY 01", server: "", want: "MY"},
+		{name: "jp01", server: "", want: "JP"},
+		{name: "Fra Angelico", server: "", want: ""},
+		{name: "FRA-01", server: "", want: "DE"},
+		{name: "node-03", server: "2001:db8::1", want: ""},
+	}
+
+	for _, tc := range cases {
+		if got := Detect(tc.name, tc.server); got != tc.want {
+			t.Fatalf("Detect(%q, %q) = %q, want %q", tc.name, tc.server, got, tc.want)
+		}
+	}
+}
+
+// TestHasLeadingFlag_识别名称开头的旗帜 用于验证只有名称开头的旗帜 emoji 会被识别。
+// 参数含义：t 为测试上下文，用于驱动断言和失败输出。
+// 返回值：无，测试失败时由 testing 框架终止当前用例。
+func TestHasLeadingFlag_识别名称开头的旗帜(t *testing.T) {
+	cases := map[string]bool{
+		"🇭🇰 HK 01":  true,
+		" 🇯🇵 Tokyo": true,
+		"HK 🇭🇰":     false,
+		"香港":        false,
+	}
+
+	for name, want := range cases {
+		if got := HasLeadingFlag(name); got != want {
+			t.Fatalf("HasLeadingFlag(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// TestFlag_生成旗帜emoji 用于验证地区代码会转换为对应旗帜，非法代码返回空串。
+// 参数含义：t 为测试上下文，用于驱动断言和失败输出。
+// 返回值：无，测试失败时由 testing 框架终止当前用例。
+func TestFlag_生成旗帜emoji(t *testing.T) {
+	if got := Flag("hk"); got != "🇭🇰" {
+		t.Fatalf("expected 🇭🇰, got %q", got)
+	}
+
+	if got := Flag("1A"); got != "" {
+		t.Fatalf("expected empty flag for invalid code, got %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+// Package urlsign 用于生成与校验带过期时间的 HMAC 签名订阅地址。
+// 签名覆盖路由路径与过期时间，地址中的其他查询参数（如 target）不参与签名，可由使用者自行调整。
+package urlsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// ExpiresParam 为过期时间的查询参数名，取值为 Unix 秒级时间戳。
+	ExpiresParam = "exp"
+	// SignatureParam 为签名的查询参数名。
+	SignatureParam = "sig"
+)
+
+var (
+	// ErrExpired 表示签名地址已过期。
+	ErrExpired = errors.New("signature expired")
+	// ErrInvalid 表示签名缺失、格式错误或与任一密钥都不匹配。
+	ErrInvalid = errors.New("signature invalid")
+)
+
+// Sign 用于计算路径与过期时间的签名。
+// 参数含义：secret 为签名密钥；path 为路由路径；expires 为过期时间的 Unix 秒级时间戳。
+// 返回值：返回 URL 安全的 base64 签名。
+func Sign(secret, path string, expires int64) string {
+	return base64.RawURLEncoding.EncodeToString(mac(secret, path, expires))
+}
+
+// Query 用于生成携带过期时间与签名的查询参数。
+// 参数含义：secret 为签名密钥；path 为路由路径；expiresAt 为过期时间。
+// 返回值：返回编码后的查询字符串，不含前导问号。
+func Query(secret, path string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	values := url.Values{}
+	values.Set(ExpiresParam, strconv.FormatInt(expires, 10))
+	values.Set(SignatureParam, Sign(secret, path, expires))
+
+	return values.Encode()
+}
+
+// Verify 用于校验签名是否由任一密钥生成且尚未过期，密钥轮换期间新旧密钥可以同时生效。
+// 参数含义：secrets 为当前有效的全部密钥；path 为路由路径；expires 与 signature 为请求中的原始参数；now 为当前时间。
+// 返回值：校验通过时返回 nil，过期时返回 ErrExpired，其余情况返回 ErrInvalid。
+func Verify(secrets []string, path, expires, signature string, now time.Time) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalid
+	}
+
+	// 先确认签名有效再判断过期，避免篡改过的过期时间被报告为过期。
+	matched := false
+	for _, secret := range secrets {
+		if hmac.Equal(decoded, mac(secret, path, expiresAt)) {
+			matched = true
+		}
+	}
+	if !matched {
+		return ErrInvalid
+	}
+	if now.Unix() >= expiresAt {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// mac 用于计算签名原文的 HMAC-SHA256，原文由路径与过期时间以换行连接。
+// 参数含义：secret 为签名密钥；path 为路由路径；expires 为过期时间戳。
+// 返回值：返回原始签名字节。
+func mac(secret, path string, expires int64) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(path + "\n" + strconv.FormatInt(expires, 10)))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,42 @@
+package urlsign
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestVerify_AcceptsRotatedSecrets 用于验证任一有效密钥生成的签名都能通过校验，未知密钥、篡改路径或过期时间会被拒绝。
+// 参数含义：t 为测试上下文。
+// 返回值：无。
+func TestVerify_AcceptsRotatedSecrets(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	query, err := url.ParseQuery(Query("old-secret", "/client-a", now.Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+	exp, sig := query.Get(ExpiresParam), query.Get(SignatureParam)
+
+	secrets := []string{"new-secret", "old-secret"}
+	if err := Verify(secrets, "/client-a", exp, sig, now); err != nil {
+		t.Fatalf("expected rotated secret to verify, got: %v", err)
+	}
+	if err := Verify([]string{"new-secret"}, "/client-a", exp, sig, now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected retired secret to be rejected, got: %v", err)
+	}
+	if err := Verify(secrets, "/client-b", exp, sig, now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected other path to be rejected, got: %v", err)
+	}
+	if err := Verify(secrets, "/client-a", "1800000000", sig, now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected tampered expiry to be rejected, got: %v", err)
+	}
+	if err := Verify(secrets, "/client-a", exp, "!!", now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected malformed signature to be rejected, got: %v", err)
+	}
+	if err := Verify(secrets, "/client-a", exp, sig, now.Add(2*time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected expired signature to be rejected, got: %v", err)
+	}
+}